@@ -1,12 +1,105 @@
 package cmd
 
 import (
-	"github.com/daniel-cohen/secretsfetcher/secrets/aws"
+	"time"
 )
 
 // Config - config vars for the application
 type config struct {
 	LogLevel string
 
-	Aws *aws.AWSConfig
+	Output *outputConfig
+
+	Watch *watchConfig
+
+	Hooks *hooksConfig
+
+	Serve *serveConfig
+
+	Metrics *metricsConfig
+}
+
+// metricsConfig - where the prometheus metrics are exposed
+type metricsConfig struct {
+	// the address /metrics (and the /healthz and /readyz probes with --watch) is served on in the long running modes (--watch and serve). Empty disables it
+	Listen string
+
+	// with --watch, a separate address for the /healthz and /readyz probes. Empty serves them on the metrics address
+	ProbeListen string
+
+	// a file the metrics are written to at the end of one-shot runs, for the node exporter's textfile collector
+	Textfile string
+}
+
+// serveConfig - the serve command, serving the secrets on demand
+type serveConfig struct {
+	// a loopback host:port or a unix domain socket (unix:///path/to/socket)
+	Listen string
+
+	// how long fetched secrets are served before they're fetched again
+	TTL time.Duration
+
+	// the bearer token requests must have. Required unless listening on a unix socket.
+	// Set it with APP_SERVE_TOKEN or read it from the token file
+	Token     string
+	TokenFile string
+
+	SocketMode string // octal. E.g: "0660"
+}
+
+// hooksConfig - what runs after --watch rewrote changed secrets
+type hooksConfig struct {
+	// signal the process in the pid file, or all the processes with the name
+	PidFile     string
+	ProcessName string
+	Signal      string // E.g: HUP
+
+	// a shell command, getting the changed secret names in SECRETSFETCHER_CHANGED
+	Command string
+
+	// a local url the changed secret names are POSTed to
+	URL string
+
+	// the timeout of the command and url hooks
+	Timeout time.Duration
+}
+
+// watchConfig - the sidecar mode which keeps refreshing the secrets
+type watchConfig struct {
+	Enabled  bool
+	Interval time.Duration
+	Jitter   float64 // a fraction of the interval
+
+	// the readiness probe fails once the last successful refresh is older than this. 0 defaults to 3 intervals
+	Staleness time.Duration
+}
+
+// outputConfig - how and where the secrets are written
+type outputConfig struct {
+	// files (default), dotenv, template or kubernetes
+	Format string
+
+	// dotenv format: the env file name in the output folder and the variable naming
+	EnvFile      string
+	EnvPrefix    string
+	EnvUppercase bool
+	EnvSlash     string
+	EnvJSONKeys  bool
+
+	// kubernetes format: the generated kubernetes secrets file ("-" for stdout) and metadata
+	KubernetesFile      string
+	KubernetesName      string
+	KubernetesNamespace string
+	KubernetesLabels    map[string]string
+	KubernetesType      string
+	KubernetesPerSecret bool
+	KubernetesEncoding  string // yaml or json
+
+	// the mode and owner of the written secret files and folders
+	FileMode string // octal. E.g: "0640"
+	DirMode  string
+
+	// -1 keeps the user running the process
+	UID int
+	GID int
 }
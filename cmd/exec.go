@@ -0,0 +1,113 @@
+//go:build !windows
+
+package cmd
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"syscall"
+
+	"github.com/daniel-cohen/secretsfetcher/metrics"
+	"github.com/daniel-cohen/secretsfetcher/secrets"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+)
+
+var execCmd = &cobra.Command{
+	Use:   "exec [flags] -- command [args...]",
+	Short: "fetches secrets and runs a command with them as environment variables",
+	Long: `Fetches secrets from the manifest's provider (or --provider) and replaces this process with the command, passing the secrets as environment variables.
+The secrets are never written to disk. As the command replaces this process, it receives all signals directly.`,
+	Example: "  secretsfetcher exec -m manifest.yaml -- ./server --port 8080",
+	Args:    cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+
+		// Init logging:
+		zl := initLog(cfg.LogLevel, consoleLogging)
+		defer zl.Sync() // flushes buffer, if any
+
+		manifestFile, err := cmd.Flags().GetString("manifest")
+		if err != nil {
+			zl.Fatal("failed to get the manifest flag")
+		}
+
+		mergeOpts := secrets.MergeEnvOptions{}
+		if mergeOpts.Scrub, err = cmd.Flags().GetBool("scrubenv"); err != nil {
+			zl.Fatal("failed to get the scrubenv flag")
+		}
+		if mergeOpts.Keep, err = cmd.Flags().GetStringSlice("keepenv"); err != nil {
+			zl.Fatal("failed to get the keepenv flag")
+		}
+		if mergeOpts.Override, err = cmd.Flags().GetBool("override"); err != nil {
+			zl.Fatal("failed to get the override flag")
+		}
+
+		// Resolve the command before fetching anything:
+		path, err := exec.LookPath(args[0])
+		if err != nil {
+			zl.Fatal("command not found", zap.String("command", args[0]), zap.Error(err))
+		}
+
+		providerName, err := cmd.Flags().GetString("provider")
+		if err != nil {
+			zl.Fatal("failed to get the provider flag")
+		}
+
+		fetch, err := newProviderFetch(context.Background(), providerName, manifestFile, zl)
+		if err != nil {
+			zl.Fatal("failed to setup the secrets provider", zap.Error(err))
+		}
+
+		// Cancelled on SIGINT/SIGTERM or when the global timeout expires:
+		ctx, cancel := newCommandContext(fetch.Timeout)
+		secretRes, err := fetch.Fetcher.Fetch(ctx)
+		cancel()
+		if fetch.Done != nil {
+			fetch.Done()
+		}
+		if err != nil {
+			metrics.RefreshFailed()
+			writeMetricsTextfile(zl)
+			zl.Fatal("failed to fetch secrets", zap.Error(err))
+		}
+
+		envVars, err := secrets.EnvVars(secretRes, secrets.EnvKeyOptions{
+			Prefix:           cfg.Output.EnvPrefix,
+			Uppercase:        cfg.Output.EnvUppercase,
+			SlashReplacement: cfg.Output.EnvSlash,
+			JSONKeys:         cfg.Output.EnvJSONKeys,
+		})
+		if err != nil {
+			zl.Fatal("failed to map secrets to environment variables", zap.Error(err))
+		}
+
+		env, err := secrets.MergeEnv(os.Environ(), envVars, mergeOpts)
+		if err != nil {
+			zl.Fatal("failed to set the secrets environment variables", zap.Error(err))
+		}
+
+		keys := make([]string, 0, len(envVars))
+		for _, v := range envVars {
+			keys = append(keys, v.Key)
+		}
+		zl.Info("running command with secrets", zap.String("command", path), zap.Strings("variables", keys))
+		zl.Sync()
+
+		// Only returns on failure:
+		err = syscall.Exec(path, args, env)
+		zl.Fatal("failed to run command", zap.String("command", path), zap.Error(err))
+	},
+}
+
+func init() {
+	addFetchFlags(execCmd.Flags())
+	addEnvKeyFlags(execCmd.Flags())
+
+	execCmd.Flags().Bool("scrubenv", false, "run the command with only the secrets (and the --keepenv variables) in its environment")
+	execCmd.Flags().StringSlice("keepenv", []string{}, "variables kept with --scrubenv. Example: --keepenv=PATH,HOME")
+	execCmd.Flags().Bool("override", false, "let secrets replace existing environment variables with the same name instead of failing")
+
+	fetchCmds = append(fetchCmds, execCmd)
+	rootCmd.AddCommand(execCmd)
+}
@@ -0,0 +1,111 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/daniel-cohen/secretsfetcher/secrets"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"go.uber.org/zap"
+)
+
+var fetchCmd = &cobra.Command{
+	Use:   "fetch",
+	Short: "fetches secrets from the provider selected by the manifest",
+	Long: `Fetches the secrets in a manifest from the provider in its provider field (aws if unset), and writes them like the provider's command.
+Objects with their own provider (or aws region and roleArn) are fetched from each provider concurrently, and written together.
+The default provider's flags are used as is, the other providers' flags are prefixed with their name. E.g: --vault-address.
+Without a manifest, --provider selects the provider whose manifestless mode (E.g: listing secrets by tags) is used.`,
+	Example: "  secretsfetcher fetch -m manifest.yaml -o /secrets\n  secretsfetcher fetch --provider gcp --gcp-project my-project --gcp-labels app=my-app -o /secrets",
+	Run: func(cmd *cobra.Command, args []string) {
+		providerName, _ := cmd.Flags().GetString("provider")
+		runProviderFetch(cmd, providerName)
+	},
+}
+
+// runProviderFetch - fetches and writes the secrets of the provider (the manifest's if empty), once or with --watch
+func runProviderFetch(cmd *cobra.Command, providerName string) {
+
+	// Init logging:
+	zl := initLog(cfg.LogLevel, consoleLogging)
+	defer zl.Sync() // flushes buffer, if any
+
+	outputFolder, err := cmd.Flags().GetString("output")
+	if err != nil {
+		zl.Fatal("failed to get the output flag")
+	}
+
+	manifestFile, err := cmd.Flags().GetString("manifest")
+	if err != nil {
+		zl.Fatal("failed to get the manifest flag")
+	}
+
+	fetch, err := newProviderFetch(context.Background(), providerName, manifestFile, zl)
+	if err != nil {
+		zl.Fatal("failed to setup the secrets provider", zap.Error(err))
+	}
+
+	sw, err := newSecretWriter(outputFolder, fetch.PathTranslation, fetch.Templates, zl)
+	if err != nil {
+		zl.Fatal("invalid output config", zap.Error(err))
+	}
+
+	err = runFetcher(fetch.Fetcher, sw, fetch.Timeout, fetch.Required, zl)
+	if fetch.Done != nil {
+		fetch.Done()
+	}
+	if err != nil {
+		zl.Fatal("failed to fetch and write secrets", zap.Error(err))
+	}
+}
+
+// addEnvKeyFlags - the flags naming environment variables after secrets
+func addEnvKeyFlags(flags *pflag.FlagSet) {
+	flags.String("envprefix", "", "a prefix for the env variable names")
+	flags.Bool("envuppercase", true, "uppercase the env variable names")
+	flags.String("envslash", "_", "what slashes in the secret names are replaced with in the env variable names. Example: --envslash=__")
+	flags.Bool("envjsonkeys", false, "a variable per top level field of json secrets")
+}
+
+// addOutputFlags - the output, watch, hooks and metrics flags, shared by the commands writing the secrets
+func addOutputFlags(flags *pflag.FlagSet) {
+	flags.StringP("output", "o", "", "output folder. Will default to the current working folder")
+
+	flags.Bool("watch", false, "keep running and rewrite the secrets when they change (sidecar mode)")
+	flags.Duration("interval", secrets.DefaultWatchInterval, "how often secrets are checked for changes with --watch")
+	flags.Float64("jitter", secrets.DefaultWatchJitter, "randomizes each --watch interval by up to +/- this fraction of it")
+	flags.String("signal-pidfile", "", "with --watch, signal the process in this pid file after secrets changed")
+	flags.String("signal-process", "", "with --watch, signal the processes with this name after secrets changed")
+	flags.String("signal", "HUP", "the signal sent by --signal-pidfile and --signal-process")
+	flags.String("hook-command", "", "with --watch, a shell command to run after secrets changed (gets their names in SECRETSFETCHER_CHANGED)")
+	flags.String("hook-url", "", "with --watch, a local url the changed secret names are POSTed to")
+	flags.Duration("hook-timeout", secrets.DefaultHookTimeout, "the timeout of --hook-command and --hook-url")
+	flags.String("metricsaddr", "", "with --watch, the address to serve prometheus metrics (/metrics) and the health probes (/healthz, /readyz, unless --probeaddr is set) on. Example: --metricsaddr=:9090")
+	flags.String("probeaddr", "", "with --watch, the address to serve the health probes (/healthz, /readyz) on, without the metrics. Example: --probeaddr=:8081")
+	flags.Duration("staleness", 0, "with --watch, /readyz fails once the last successful refresh is older than this (default 3 intervals)")
+	flags.String("metricsfile", "", "a file to write prometheus metrics to at the end of the run, for the node exporter's textfile collector")
+
+	flags.String("format", outputFormatFiles, "the output format: files (a file per secret), dotenv (a single env file), template (the manifest templates) or kubernetes (kubernetes secret manifests, each limited to 1MiB of raw secret data)")
+	flags.String("envfile", secrets.DefaultEnvFile, "the env file name in the output folder with --format=dotenv")
+	addEnvKeyFlags(flags)
+	flags.String("k8sfile", secrets.DefaultKubernetesFile, "the kubernetes secrets file in the output folder with --format=kubernetes. - writes to stdout (and the logs to stderr)")
+	flags.String("k8sname", "", "the kubernetes secret name (or name prefix with --k8spersecret)")
+	flags.String("k8snamespace", "", "the kubernetes secret namespace")
+	flags.StringToString("k8slabels", map[string]string{}, "the kubernetes secret labels. Example: --k8slabels=app=my-app,team=platform")
+	flags.String("k8stype", secrets.DefaultKubernetesSecretType, "the kubernetes secret type")
+	flags.Bool("k8spersecret", false, "a kubernetes secret per fetched secret instead of a single one")
+	flags.String("k8sencoding", secrets.KubernetesEncodingYAML, "the kubernetes secrets encoding: yaml or json")
+	flags.String("filemode", fmt.Sprintf("%04o", secrets.DefaultFileMode), "the octal mode of the written secret files")
+	flags.String("dirmode", fmt.Sprintf("%04o", secrets.DefaultDirMode), "the octal mode of the output folder (created if missing) and the folders inside it")
+	flags.Int("uid", secrets.KeepOwner, "the owner uid of the written files. -1 keeps the current user")
+	flags.Int("gid", secrets.KeepOwner, "the owner gid of the written files. -1 keeps the current group")
+}
+
+func init() {
+	addFetchFlags(fetchCmd.Flags())
+	addOutputFlags(fetchCmd.Flags())
+
+	fetchCmds = append(fetchCmds, fetchCmd)
+	rootCmd.AddCommand(fetchCmd)
+}
@@ -0,0 +1,37 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/daniel-cohen/secretsfetcher/secrets"
+)
+
+// newChangeHooks - the hooks to run after --watch rewrote changed secrets
+func newChangeHooks(hooksCfg *hooksConfig) ([]secrets.ChangeHook, error) {
+	var hooks []secrets.ChangeHook
+
+	if hooksCfg.PidFile != "" && hooksCfg.ProcessName != "" {
+		return nil, fmt.Errorf("only one of a pid file and a process name can be signalled")
+	}
+
+	if hooksCfg.PidFile != "" || hooksCfg.ProcessName != "" {
+		sig, err := secrets.ParseSignal(hooksCfg.Signal)
+		if err != nil {
+			return nil, err
+		}
+		hooks = append(hooks, &secrets.SignalHook{PidFile: hooksCfg.PidFile, ProcessName: hooksCfg.ProcessName, Signal: sig})
+	}
+
+	if hooksCfg.Command != "" {
+		hooks = append(hooks, &secrets.CommandHook{Command: hooksCfg.Command, Timeout: hooksCfg.Timeout})
+	}
+
+	if hooksCfg.URL != "" {
+		if err := secrets.CheckLocalURL(hooksCfg.URL); err != nil {
+			return nil, err
+		}
+		hooks = append(hooks, &secrets.HTTPHook{URL: hooksCfg.URL, Timeout: hooksCfg.Timeout})
+	}
+
+	return hooks, nil
+}
@@ -0,0 +1,69 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/daniel-cohen/secretsfetcher/metrics"
+	"github.com/daniel-cohen/secretsfetcher/secrets"
+	"go.uber.org/zap"
+)
+
+// startStatusServer - serves /metrics on the metrics address, and the /healthz and /readyz probes (if a status is set)
+// on the probe address, until the context is cancelled. The probes are served with the metrics without a probe address.
+// Does nothing without addresses
+func startStatusServer(ctx context.Context, metricsAddress string, probeAddress string, status *secrets.Status, zl *zap.Logger) {
+	if probeAddress == "" {
+		probeAddress = metricsAddress
+	}
+
+	if metricsAddress != "" {
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", metrics.Handler())
+		if status != nil && probeAddress == metricsAddress {
+			handleProbes(mux, status)
+		}
+		serveHTTP(ctx, metricsAddress, mux, zl)
+	}
+
+	if status != nil && probeAddress != "" && probeAddress != metricsAddress {
+		mux := http.NewServeMux()
+		handleProbes(mux, status)
+		serveHTTP(ctx, probeAddress, mux, zl)
+	}
+}
+
+// handleProbes - the /healthz and /readyz probes of the status
+func handleProbes(mux *http.ServeMux, status *secrets.Status) {
+	mux.HandleFunc("/healthz", status.ServeLiveness)
+	mux.HandleFunc("/readyz", status.ServeReadiness)
+}
+
+// serveHTTP - serves the handler on the address in the background until the context is cancelled
+func serveHTTP(ctx context.Context, address string, handler http.Handler, zl *zap.Logger) {
+	server := &http.Server{Addr: address, Handler: handler}
+
+	go func() {
+		<-ctx.Done()
+		server.Close()
+	}()
+
+	go func() {
+		zl.Info("serving metrics and probes", zap.String("address", address))
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			zl.Fatal("failed to serve metrics and probes", zap.String("address", address), zap.Error(err))
+		}
+	}()
+}
+
+// writeMetricsTextfile - writes the metrics for the textfile collector, if a file is set
+func writeMetricsTextfile(zl *zap.Logger) {
+	if cfg.Metrics.Textfile == "" {
+		return
+	}
+
+	if err := metrics.WriteTextfile(cfg.Metrics.Textfile); err != nil {
+		zl.Error("failed to write the metrics textfile", zap.String("file_path", cfg.Metrics.Textfile), zap.Error(err))
+	}
+}
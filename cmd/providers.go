@@ -0,0 +1,212 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/daniel-cohen/secretsfetcher/secrets"
+	_ "github.com/daniel-cohen/secretsfetcher/secrets/providers"
+	"github.com/mitchellh/mapstructure"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+)
+
+// defaultProvider - the provider of manifests without a provider field, and of the manifestless modes unless --provider is set
+const defaultProvider = "aws"
+
+// providerFlags - the flags of each provider config key (E.g: vault.address), across the commands having them
+var providerFlags = map[string][]*pflag.Flag{}
+
+// decodeMap - decodes the settings into the struct like viper.Unmarshal
+func decodeMap(settings map[string]interface{}, out interface{}) error {
+	decoder, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
+		Result:           out,
+		WeaklyTypedInput: true,
+		DecodeHook: mapstructure.ComposeDecodeHookFunc(
+			mapstructure.StringToTimeDurationHookFunc(),
+			mapstructure.StringToSliceHookFunc(","),
+		),
+	})
+	if err != nil {
+		return err
+	}
+	return decoder.Decode(settings)
+}
+
+// providerConfig - decodes the provider's config section (E.g: Vault:), with its env vars and flags applied
+func providerConfig(p secrets.Provider) secrets.Decoder {
+	return func(out interface{}) error {
+		settings, _ := viper.AllSettings()[p.Name()].(map[string]interface{})
+		if settings == nil {
+			settings = map[string]interface{}{}
+		}
+
+		// the provider's failure policy (E.g: APP_VAULT_FAILUREPOLICY) defaults to the shared one:
+		if policy, _ := settings["failurepolicy"].(string); policy == "" {
+			settings["failurepolicy"] = viper.GetString("FailurePolicy")
+		}
+		return decodeMap(settings, out)
+	}
+}
+
+// loadManifest - reads the manifest file as a viper config file
+func loadManifest(manifestFile string, zl *zap.Logger) (map[string]interface{}, error) {
+	v := viper.New()
+	v.SetConfigFile(manifestFile)
+	if err := v.ReadInConfig(); err != nil {
+		return nil, fmt.Errorf("failed to load manifest file %s: %w", manifestFile, err)
+	}
+	zl.Info("Read manifest file", zap.String("manifestPath", manifestFile))
+
+	return v.AllSettings(), nil
+}
+
+// newProviderFetch - the fetch of the manifest by its objects' (or its own) provider field, or of the named provider's manifestless mode.
+// The provider name (if set) must match the manifest's. A manifest with objects of several providers is fetched from all of them concurrently
+func newProviderFetch(ctx context.Context, providerName string, manifestFile string, zl *zap.Logger) (*secrets.ProviderFetch, error) {
+	if manifestFile == "" {
+		zl.Info("no manifest set")
+		if providerName == "" {
+			providerName = defaultProvider
+		}
+		p, err := secrets.LookupProvider(providerName)
+		if err != nil {
+			return nil, err
+		}
+		zl.Info("fetching secrets", zap.String("provider", p.Name()))
+
+		return p.NewFetch(ctx, providerConfig(p), nil, zl)
+	}
+
+	settings, err := loadManifest(manifestFile, zl)
+	if err != nil {
+		return nil, err
+	}
+
+	defaultManifestProvider := providerName
+	if defaultManifestProvider == "" {
+		defaultManifestProvider = defaultProvider
+	}
+	groups, err := secrets.SplitManifest(settings, defaultManifestProvider)
+	if err != nil {
+		return nil, fmt.Errorf("invalid manifest %s: %w", manifestFile, err)
+	}
+
+	names := make([]string, 0, len(groups))
+	fetches := make([]*secrets.ProviderFetch, 0, len(groups))
+	for _, g := range groups {
+		if providerName != "" && providerName != g.Provider {
+			return nil, fmt.Errorf("the manifest has objects of the %s provider, not only %s", g.Provider, providerName)
+		}
+
+		p, err := secrets.LookupProvider(g.Provider)
+		if err != nil {
+			return nil, err
+		}
+		zl.Info("fetching secrets", zap.String("provider", p.Name()), zap.String("group", g.Name()))
+
+		manifest := g.Manifest
+		fetch, err := p.NewFetch(ctx, providerConfig(p), func(out interface{}) error {
+			return decodeMap(manifest, out)
+		}, zl.With(zap.String("group", g.Name())))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", g.Name(), err)
+		}
+
+		names = append(names, g.Name())
+		fetches = append(fetches, fetch)
+	}
+
+	return secrets.CombineProviderFetches(names, fetches, zl)
+}
+
+// addProviderFlags - adds the provider's flags with the prefix (E.g: vault-), bound to its config in initConfig
+func addProviderFlags(flags *pflag.FlagSet, p secrets.Provider, prefix string) {
+	providerFlagSet := pflag.NewFlagSet(p.Name(), pflag.ContinueOnError)
+	fields := p.AddFlags(providerFlagSet)
+
+	providerFlagSet.VisitAll(func(f *pflag.Flag) {
+		if field, ok := fields[f.Name]; ok {
+			key := p.Name() + "." + field
+			providerFlags[key] = append(providerFlags[key], f)
+		}
+		if prefix != "" {
+			f.Name = prefix + f.Name
+			f.Shorthand = ""
+			f.Usage = strings.ReplaceAll(f.Usage, "--", "--"+prefix)
+		}
+		flags.AddFlag(f)
+	})
+}
+
+// addFetchFlags - the manifest and provider flags of the commands fetching from any provider:
+// the default provider's flags as is, and the others' prefixed with their name. E.g: --vault-address
+func addFetchFlags(flags *pflag.FlagSet) {
+	flags.StringP("manifest", "m", "", "secrets manifest file. Its provider field (or its objects') selects the provider")
+	flags.String("provider", "", fmt.Sprintf("the provider of manifests without a provider field and of the manifestless modes (default %q)", defaultProvider))
+	addFailurePolicyFlag(flags)
+
+	for _, p := range secrets.Providers() {
+		prefix := p.Name() + "-"
+		if p.Name() == defaultProvider {
+			prefix = ""
+		}
+		addProviderFlags(flags, p, prefix)
+	}
+}
+
+// addFailurePolicyFlag - the failure policy of all the providers
+func addFailurePolicyFlag(flags *pflag.FlagSet) {
+	flags.String("failurepolicy", string(secrets.DefaultFailurePolicy), "how to handle required secrets which failed to be fetched: failfast, failatend or besteffort")
+}
+
+// setProviderConfig - the defaults of the providers' config, and their flags bound to it (the changed flag of the command being run)
+func setProviderConfig() {
+	for _, p := range secrets.Providers() {
+		for field, value := range p.ConfigDefaults() {
+			viper.SetDefault(p.Name()+"."+field, value)
+		}
+	}
+
+	for key, flags := range providerFlags {
+		flag := flags[0]
+		for _, f := range flags {
+			if f.Changed {
+				flag = f
+				break
+			}
+		}
+		viper.BindPFlag(key, flag)
+	}
+}
+
+// newProviderCmd - the command fetching the provider's secrets, with its flags as is
+func newProviderCmd(p secrets.Provider) *cobra.Command {
+	usage := p.Usage()
+	cmd := &cobra.Command{
+		Use:     p.Name(),
+		Short:   usage.Short,
+		Long:    usage.Long,
+		Example: usage.Example,
+		Run: func(cmd *cobra.Command, args []string) {
+			runProviderFetch(cmd, p.Name())
+		},
+	}
+
+	cmd.Flags().StringP("manifest", "m", "", "secrets manifest file")
+	addFailurePolicyFlag(cmd.Flags())
+	addProviderFlags(cmd.Flags(), p, "")
+	addOutputFlags(cmd.Flags())
+	return cmd
+}
+
+func init() {
+	for _, p := range secrets.Providers() {
+		cmd := newProviderCmd(p)
+		fetchCmds = append(fetchCmds, cmd)
+		rootCmd.AddCommand(cmd)
+	}
+}
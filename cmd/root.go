@@ -5,8 +5,9 @@ import (
 	"os"
 	"strings"
 
-	"github.com/daniel-cohen/secretsfetcher/secrets/aws"
+	"github.com/daniel-cohen/secretsfetcher/secrets"
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 	"github.com/spf13/viper"
 )
 
@@ -16,6 +17,9 @@ var (
 	cfgFile        string
 	cfg            config
 	consoleLogging bool
+
+	// fetchCmds - the commands sharing the fetch flags bound to the config
+	fetchCmds []*cobra.Command
 )
 
 //
@@ -58,6 +62,22 @@ func init() {
 
 }
 
+// fetchFlag - the named flag of the command being run (the only one which can be changed), defaulting to the first fetch command's flag
+func fetchFlag(name string) *pflag.Flag {
+	var flag *pflag.Flag
+	for _, c := range fetchCmds {
+		if f := c.Flags().Lookup(name); f != nil {
+			if f.Changed {
+				return f
+			}
+			if flag == nil {
+				flag = f
+			}
+		}
+	}
+	return flag
+}
+
 // initConfig reads in config file and ENV variables if set.
 func initConfig() {
 	replacer := strings.NewReplacer(".", "_")
@@ -73,28 +93,187 @@ func initConfig() {
 
 	///-----------------------------------------------------------------
 
-	//TODO: See if I can refactor this into the aws.go command:
-	if awsCmd.Flags().Lookup("tagkeys") != nil {
-		viper.BindPFlag("Aws.TagKeyFilters", awsCmd.Flags().Lookup("tagkeys"))
+	if fetchFlag("failurepolicy") != nil {
+		viper.BindPFlag("FailurePolicy", fetchFlag("failurepolicy"))
+	}
+
+	if fetchFlag("watch") != nil {
+		viper.BindPFlag("Watch.Enabled", fetchFlag("watch"))
+	}
+
+	if fetchFlag("interval") != nil {
+		viper.BindPFlag("Watch.Interval", fetchFlag("interval"))
+	}
+
+	if fetchFlag("jitter") != nil {
+		viper.BindPFlag("Watch.Jitter", fetchFlag("jitter"))
+	}
+
+	if fetchFlag("staleness") != nil {
+		viper.BindPFlag("Watch.Staleness", fetchFlag("staleness"))
+	}
+
+	if fetchFlag("signal-pidfile") != nil {
+		viper.BindPFlag("Hooks.PidFile", fetchFlag("signal-pidfile"))
+	}
+
+	if fetchFlag("signal-process") != nil {
+		viper.BindPFlag("Hooks.ProcessName", fetchFlag("signal-process"))
+	}
+
+	if fetchFlag("signal") != nil {
+		viper.BindPFlag("Hooks.Signal", fetchFlag("signal"))
+	}
+
+	if fetchFlag("hook-command") != nil {
+		viper.BindPFlag("Hooks.Command", fetchFlag("hook-command"))
+	}
+
+	if fetchFlag("hook-url") != nil {
+		viper.BindPFlag("Hooks.URL", fetchFlag("hook-url"))
+	}
+
+	if fetchFlag("hook-timeout") != nil {
+		viper.BindPFlag("Hooks.Timeout", fetchFlag("hook-timeout"))
+	}
+
+	if fetchFlag("format") != nil {
+		viper.BindPFlag("Output.Format", fetchFlag("format"))
 	}
 
-	if awsCmd.Flags().Lookup("tagvalues") != nil {
-		viper.BindPFlag("Aws.TagValueFilters", awsCmd.Flags().Lookup("tagvalues"))
+	if fetchFlag("envfile") != nil {
+		viper.BindPFlag("Output.EnvFile", fetchFlag("envfile"))
 	}
 
-	if awsCmd.Flags().Lookup("prefix") != nil {
-		viper.BindPFlag("Aws.PrefixFilter", awsCmd.Flags().Lookup("prefix"))
+	if fetchFlag("envprefix") != nil {
+		viper.BindPFlag("Output.EnvPrefix", fetchFlag("envprefix"))
+	}
+
+	if fetchFlag("envuppercase") != nil {
+		viper.BindPFlag("Output.EnvUppercase", fetchFlag("envuppercase"))
+	}
+
+	if fetchFlag("envslash") != nil {
+		viper.BindPFlag("Output.EnvSlash", fetchFlag("envslash"))
+	}
+
+	if fetchFlag("envjsonkeys") != nil {
+		viper.BindPFlag("Output.EnvJSONKeys", fetchFlag("envjsonkeys"))
+	}
+
+	if fetchFlag("k8sfile") != nil {
+		viper.BindPFlag("Output.KubernetesFile", fetchFlag("k8sfile"))
+	}
+
+	if fetchFlag("k8sname") != nil {
+		viper.BindPFlag("Output.KubernetesName", fetchFlag("k8sname"))
+	}
+
+	if fetchFlag("k8snamespace") != nil {
+		viper.BindPFlag("Output.KubernetesNamespace", fetchFlag("k8snamespace"))
+	}
+
+	if fetchFlag("k8slabels") != nil {
+		viper.BindPFlag("Output.KubernetesLabels", fetchFlag("k8slabels"))
+	}
+
+	if fetchFlag("k8stype") != nil {
+		viper.BindPFlag("Output.KubernetesType", fetchFlag("k8stype"))
+	}
+
+	if fetchFlag("k8spersecret") != nil {
+		viper.BindPFlag("Output.KubernetesPerSecret", fetchFlag("k8spersecret"))
+	}
+
+	if fetchFlag("k8sencoding") != nil {
+		viper.BindPFlag("Output.KubernetesEncoding", fetchFlag("k8sencoding"))
+	}
+
+	if fetchFlag("filemode") != nil {
+		viper.BindPFlag("Output.FileMode", fetchFlag("filemode"))
+	}
+
+	if fetchFlag("dirmode") != nil {
+		viper.BindPFlag("Output.DirMode", fetchFlag("dirmode"))
+	}
+
+	if fetchFlag("uid") != nil {
+		viper.BindPFlag("Output.UID", fetchFlag("uid"))
+	}
+
+	if fetchFlag("gid") != nil {
+		viper.BindPFlag("Output.GID", fetchFlag("gid"))
+	}
+
+	if fetchFlag("listen") != nil {
+		viper.BindPFlag("Serve.Listen", fetchFlag("listen"))
+	}
+
+	if fetchFlag("ttl") != nil {
+		viper.BindPFlag("Serve.TTL", fetchFlag("ttl"))
+	}
+
+	if fetchFlag("tokenfile") != nil {
+		viper.BindPFlag("Serve.TokenFile", fetchFlag("tokenfile"))
+	}
+
+	if fetchFlag("socketmode") != nil {
+		viper.BindPFlag("Serve.SocketMode", fetchFlag("socketmode"))
+	}
+
+	if fetchFlag("metricsaddr") != nil {
+		viper.BindPFlag("Metrics.Listen", fetchFlag("metricsaddr"))
+	}
+
+	if fetchFlag("probeaddr") != nil {
+		viper.BindPFlag("Metrics.ProbeListen", fetchFlag("probeaddr"))
+	}
+
+	if fetchFlag("metricsfile") != nil {
+		viper.BindPFlag("Metrics.Textfile", fetchFlag("metricsfile"))
 	}
 
 	///-----------------------------------------------------------------
 
 	// Set specific (even if empty) defaults so we can load them from ENV even if the config is not loaded:
-	viper.SetDefault("Aws.PrefixFilter", "")
-	viper.SetDefault("Aws.TagKeyFilters", []string{})
-	viper.SetDefault("Aws.TagValueFilters", []string{})
-	viper.SetDefault("Aws.PathTranslation", aws.DefaultPathTranslation)
-	viper.SetDefault("Aws.Region", "")
-	viper.SetDefault("Aws.TagFilter", map[string]string{})
+	viper.SetDefault("FailurePolicy", string(secrets.DefaultFailurePolicy))
+	viper.SetDefault("Watch.Enabled", false)
+	viper.SetDefault("Watch.Interval", secrets.DefaultWatchInterval)
+	viper.SetDefault("Watch.Jitter", secrets.DefaultWatchJitter)
+	viper.SetDefault("Watch.Staleness", 0)
+	viper.SetDefault("Hooks.PidFile", "")
+	viper.SetDefault("Hooks.ProcessName", "")
+	viper.SetDefault("Hooks.Signal", "HUP")
+	viper.SetDefault("Hooks.Command", "")
+	viper.SetDefault("Hooks.URL", "")
+	viper.SetDefault("Hooks.Timeout", secrets.DefaultHookTimeout)
+	viper.SetDefault("Output.Format", outputFormatFiles)
+	viper.SetDefault("Output.EnvFile", secrets.DefaultEnvFile)
+	viper.SetDefault("Output.EnvPrefix", "")
+	viper.SetDefault("Output.EnvUppercase", true)
+	viper.SetDefault("Output.EnvSlash", "_")
+	viper.SetDefault("Output.EnvJSONKeys", false)
+	viper.SetDefault("Output.KubernetesFile", secrets.DefaultKubernetesFile)
+	viper.SetDefault("Output.KubernetesName", "")
+	viper.SetDefault("Output.KubernetesNamespace", "")
+	viper.SetDefault("Output.KubernetesLabels", map[string]string{})
+	viper.SetDefault("Output.KubernetesType", secrets.DefaultKubernetesSecretType)
+	viper.SetDefault("Output.KubernetesPerSecret", false)
+	viper.SetDefault("Output.KubernetesEncoding", secrets.KubernetesEncodingYAML)
+	viper.SetDefault("Output.FileMode", fmt.Sprintf("%04o", secrets.DefaultFileMode))
+	viper.SetDefault("Output.DirMode", fmt.Sprintf("%04o", secrets.DefaultDirMode))
+	viper.SetDefault("Output.UID", secrets.KeepOwner)
+	viper.SetDefault("Output.GID", secrets.KeepOwner)
+	viper.SetDefault("Serve.Listen", defaultServeListen)
+	viper.SetDefault("Serve.TTL", secrets.DefaultCacheTTL)
+	viper.SetDefault("Serve.Token", "")
+	viper.SetDefault("Serve.TokenFile", "")
+	viper.SetDefault("Serve.SocketMode", defaultSocketMode)
+	viper.SetDefault("Metrics.Listen", "")
+	viper.SetDefault("Metrics.ProbeListen", "")
+	viper.SetDefault("Metrics.Textfile", "")
+
+	setProviderConfig()
 
 	viper.AutomaticEnv()
 
@@ -105,7 +284,11 @@ func initConfig() {
 
 	// If a config file is found, read it in.
 	if err := viper.ReadInConfig(); err == nil {
-		fmt.Println("Read config file:", viper.ConfigFileUsed())
+		out := os.Stdout
+		if manifestToStdout() {
+			out = os.Stderr
+		}
+		fmt.Fprintln(out, "Read config file:", viper.ConfigFileUsed())
 	}
 
 	//Put all the config in a common struct
@@ -0,0 +1,71 @@
+package cmd
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/daniel-cohen/secretsfetcher/metrics"
+	"github.com/daniel-cohen/secretsfetcher/secrets"
+	"go.uber.org/zap"
+)
+
+// the default staleness of the readiness probe, in watch intervals
+const defaultStalenessIntervals = 3
+
+// runFetcher - fetches and writes the secrets once, or with --watch keeps rewriting them when they change until SIGINT/SIGTERM.
+// The timeout applies to each fetch and write. required are the names of the secrets /readyz waits for (all of them if empty)
+func runFetcher(sf secrets.SecretsFetcher, sw secrets.SecretWriter, timeout time.Duration, required []string, zl *zap.Logger) error {
+	if cfg.Watch.Enabled {
+		if cfg.Watch.Interval <= 0 {
+			return fmt.Errorf("the watch interval must be positive: %s", cfg.Watch.Interval)
+		}
+
+		hooks, err := newChangeHooks(cfg.Hooks)
+		if err != nil {
+			return fmt.Errorf("invalid hooks config: %w", err)
+		}
+
+		ctx, cancel := newCommandContext(0)
+		defer cancel()
+
+		// ready once every required secret was written, as long as the refreshes keep succeeding:
+		staleness := cfg.Watch.Staleness
+		if staleness == 0 {
+			staleness = defaultStalenessIntervals * cfg.Watch.Interval
+		}
+		status := secrets.NewStatus(required, staleness)
+
+		startStatusServer(ctx, cfg.Metrics.Listen, cfg.Metrics.ProbeListen, status, zl)
+
+		watcher := secrets.NewWatcher(sf, sw, cfg.Watch.Interval, zl).
+			WithJitter(cfg.Watch.Jitter).
+			WithRefreshTimeout(timeout).
+			WithHooks(hooks...).
+			WithStatus(status)
+
+		zl.Info("watching secrets", zap.Duration("interval", cfg.Watch.Interval), zap.Float64("jitter", cfg.Watch.Jitter))
+		return watcher.Run(ctx)
+	}
+
+	// Cancelled on SIGINT/SIGTERM or when the timeout expires:
+	ctx, cancel := newCommandContext(timeout)
+	defer cancel()
+
+	secretRes, err := sf.Fetch(ctx)
+	if err != nil {
+		metrics.RefreshFailed()
+		writeMetricsTextfile(zl)
+		return fmt.Errorf("failed to fetch secrets: %w", err)
+	}
+
+	if err := sw.WriteSecrets(ctx, secretRes); err != nil {
+		metrics.RefreshFailed()
+		writeMetricsTextfile(zl)
+		return fmt.Errorf("failed to write secrets: %w", err)
+	}
+
+	metrics.AddSecretsWritten(len(secretRes))
+	metrics.RefreshSucceeded(time.Now())
+	writeMetricsTextfile(zl)
+	return nil
+}
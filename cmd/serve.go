@@ -0,0 +1,116 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/daniel-cohen/secretsfetcher/secrets"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+)
+
+const (
+	defaultServeListen = "127.0.0.1:8200"
+	defaultSocketMode  = "0660"
+
+	// how long in flight requests are waited for on SIGINT/SIGTERM
+	serveShutdownTimeout = 30 * time.Second
+)
+
+var serveCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "serves secrets on demand over a unix socket or localhost http",
+	Long: `Fetches secrets from the manifest's provider (or --provider) on demand and serves them as json, keeping them in memory for --ttl.
+Endpoints:
+  GET  /v1/secrets         the names and versions of all the secrets
+  GET  /v1/secrets/<name>  a secret (by alias or name) with its content and version
+  POST /v1/refresh         fetches the secrets now, returning the changed ones
+Requests must have an "Authorization: Bearer <token>" header when a token is set.`,
+	Example: "  APP_SERVE_TOKEN=s3cr3t secretsfetcher serve -m manifest.yaml --listen 127.0.0.1:8200",
+	Run: func(cmd *cobra.Command, args []string) {
+
+		// Init logging:
+		zl := initLog(cfg.LogLevel, consoleLogging)
+		defer zl.Sync() // flushes buffer, if any
+
+		manifestFile, err := cmd.Flags().GetString("manifest")
+		if err != nil {
+			zl.Fatal("failed to get the manifest flag")
+		}
+
+		token := cfg.Serve.Token
+		if cfg.Serve.TokenFile != "" {
+			b, err := os.ReadFile(cfg.Serve.TokenFile)
+			if err != nil {
+				zl.Fatal("failed to read the token file", zap.String("file_path", cfg.Serve.TokenFile), zap.Error(err))
+			}
+			token = strings.TrimSpace(string(b))
+		}
+		if token == "" && !secrets.IsUnixSocket(cfg.Serve.Listen) {
+			zl.Fatal("a token is required when serving over http. Set APP_SERVE_TOKEN or --tokenfile")
+		}
+
+		socketMode, err := secrets.ParseFileMode(cfg.Serve.SocketMode)
+		if err != nil {
+			zl.Fatal("invalid socket mode", zap.Error(err))
+		}
+
+		providerName, err := cmd.Flags().GetString("provider")
+		if err != nil {
+			zl.Fatal("failed to get the provider flag")
+		}
+
+		// Runs until SIGINT/SIGTERM. The global timeout applies to each fetch:
+		ctx, cancel := newCommandContext(0)
+		defer cancel()
+
+		fetch, err := newProviderFetch(ctx, providerName, manifestFile, zl)
+		if err != nil {
+			zl.Fatal("failed to setup the secrets provider", zap.Error(err))
+		}
+
+		startStatusServer(ctx, cfg.Metrics.Listen, "", nil, zl)
+
+		cache := secrets.NewSecretsCache(fetch.Fetcher, cfg.Serve.TTL, zl).WithFetchTimeout(fetch.Timeout)
+
+		l, err := secrets.ListenLocal(cfg.Serve.Listen, socketMode)
+		if err != nil {
+			zl.Fatal("failed to listen", zap.String("listen", cfg.Serve.Listen), zap.Error(err))
+		}
+
+		server := &http.Server{Handler: secrets.NewSecretsServer(cache, token, zl)}
+		go func() {
+			<-ctx.Done()
+			shutdownCtx, cancelShutdown := context.WithTimeout(context.Background(), serveShutdownTimeout)
+			defer cancelShutdown()
+			server.Shutdown(shutdownCtx)
+		}()
+
+		zl.Info("serving secrets", zap.String("listen", cfg.Serve.Listen), zap.Duration("ttl", cfg.Serve.TTL))
+		if err := server.Serve(l); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			zl.Fatal("failed to serve secrets", zap.Error(err))
+		}
+
+		if fetch.Done != nil {
+			fetch.Done()
+		}
+		zl.Info("stopped serving secrets")
+	},
+}
+
+func init() {
+	addFetchFlags(serveCmd.Flags())
+
+	serveCmd.Flags().String("listen", defaultServeListen, "a loopback host:port or a unix domain socket (unix:///path/to/socket) to serve the secrets on")
+	serveCmd.Flags().Duration("ttl", secrets.DefaultCacheTTL, "how long fetched secrets are served before they're fetched again")
+	serveCmd.Flags().String("tokenfile", "", "a file with the bearer token requests must have (or set APP_SERVE_TOKEN)")
+	serveCmd.Flags().String("socketmode", defaultSocketMode, "the mode of the unix domain socket")
+	serveCmd.Flags().String("metricsaddr", "", "the address to serve prometheus metrics on (/metrics). Example: --metricsaddr=:9090")
+
+	fetchCmds = append(fetchCmds, serveCmd)
+	rootCmd.AddCommand(serveCmd)
+}
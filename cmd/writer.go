@@ -0,0 +1,77 @@
+package cmd
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/daniel-cohen/secretsfetcher/secrets"
+	"go.uber.org/zap"
+)
+
+const (
+	outputFormatFiles      = "files"
+	outputFormatDotenv     = "dotenv"
+	outputFormatTemplate   = "template"
+	outputFormatKubernetes = "kubernetes"
+)
+
+// newSecretWriter - the secret writer for the configured output format
+func newSecretWriter(outputFolder string, pathTranslationChar string, templates []*secrets.TemplateSpec, zl *zap.Logger) (secrets.SecretWriter, error) {
+	fileMode, err := secrets.ParseFileMode(cfg.Output.FileMode)
+	if err != nil {
+		return nil, err
+	}
+
+	dirMode, err := secrets.ParseFileMode(cfg.Output.DirMode)
+	if err != nil {
+		return nil, err
+	}
+
+	switch cfg.Output.Format {
+	case "", outputFormatFiles:
+		return secrets.NewFileSecretWriter(outputFolder, pathTranslationChar, zl).
+			StopOnError().
+			WithFileMode(fileMode).
+			WithDirMode(dirMode).
+			WithOwner(cfg.Output.UID, cfg.Output.GID), nil
+	case outputFormatDotenv:
+		keyOptions := secrets.EnvKeyOptions{
+			Prefix:           cfg.Output.EnvPrefix,
+			Uppercase:        cfg.Output.EnvUppercase,
+			SlashReplacement: cfg.Output.EnvSlash,
+			JSONKeys:         cfg.Output.EnvJSONKeys,
+		}
+		return secrets.NewDotenvSecretWriter(filepath.Join(outputFolder, cfg.Output.EnvFile), keyOptions, zl).
+			WithFileMode(fileMode).
+			WithDirMode(dirMode).
+			WithOwner(cfg.Output.UID, cfg.Output.GID), nil
+	case outputFormatTemplate:
+		if len(templates) == 0 {
+			return nil, fmt.Errorf("the %s output format requires a manifest with templates", outputFormatTemplate)
+		}
+		return secrets.NewTemplateSecretWriter(outputFolder, templates, zl).
+			WithFileMode(fileMode).
+			WithDirMode(dirMode).
+			WithOwner(cfg.Output.UID, cfg.Output.GID), nil
+	case outputFormatKubernetes:
+		outputFile := cfg.Output.KubernetesFile
+		if outputFile != "-" && !filepath.IsAbs(outputFile) {
+			outputFile = filepath.Join(outputFolder, outputFile)
+		}
+
+		opts := secrets.KubernetesSecretOptions{
+			Name:      cfg.Output.KubernetesName,
+			Namespace: cfg.Output.KubernetesNamespace,
+			Labels:    cfg.Output.KubernetesLabels,
+			Type:      cfg.Output.KubernetesType,
+			PerSecret: cfg.Output.KubernetesPerSecret,
+			Encoding:  cfg.Output.KubernetesEncoding,
+		}
+		return secrets.NewKubernetesSecretWriter(outputFile, pathTranslationChar, opts, zl).
+			WithFileMode(fileMode).
+			WithOwner(cfg.Output.UID, cfg.Output.GID), nil
+	default:
+		return nil, fmt.Errorf("unsupported output format %q: expected %s, %s, %s or %s",
+			cfg.Output.Format, outputFormatFiles, outputFormatDotenv, outputFormatTemplate, outputFormatKubernetes)
+	}
+}
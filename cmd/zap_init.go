@@ -3,10 +3,24 @@ package cmd
 import (
 	"log"
 
+	"github.com/spf13/viper"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
 )
 
+// logOutput - stderr when the kubernetes manifest is written to stdout (so it can be piped to kubectl), stdout otherwise
+func logOutput() string {
+	if manifestToStdout() {
+		return "stderr"
+	}
+	return "stdout"
+}
+
+// manifestToStdout - whether the kubernetes secrets are written to stdout (--format=kubernetes --k8sfile=-)
+func manifestToStdout() bool {
+	return viper.GetString("Output.Format") == outputFormatKubernetes && viper.GetString("Output.KubernetesFile") == "-"
+}
+
 func initLog(logLevel string, console bool) *zap.Logger {
 	level := zapcore.InfoLevel
 	if err := level.Set(logLevel); err != nil {
@@ -17,8 +31,8 @@ func initLog(logLevel string, console bool) *zap.Logger {
 
 		Encoding:         "json",
 		Level:            zap.NewAtomicLevelAt(level),
-		OutputPaths:      []string{"stdout"},
-		ErrorOutputPaths: []string{"stdout"},
+		OutputPaths:      []string{logOutput()},
+		ErrorOutputPaths: []string{logOutput()},
 		EncoderConfig: zapcore.EncoderConfig{
 			MessageKey: "message",
 
@@ -0,0 +1,129 @@
+package metrics
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	dto "github.com/prometheus/client_model/go"
+)
+
+const namespace = "secretsfetcher"
+
+// the error codes of failed provider calls without an api error code
+const (
+	ErrorCodeTimeout  = "Timeout"
+	ErrorCodeCanceled = "Canceled"
+	ErrorCodeUnknown  = "Unknown"
+)
+
+var (
+	// Registry - all the secretsfetcher metrics, and the go and process metrics
+	Registry = prometheus.NewRegistry()
+
+	providerCallDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "provider_call_duration_seconds",
+		Help:      "The latency of each provider call attempt.",
+		Buckets:   []float64{.01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10, 30},
+	}, []string{"provider", "operation"})
+
+	providerErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "provider_errors_total",
+		Help:      "Failed provider call attempts by error code.",
+	}, []string{"provider", "operation", "code"})
+
+	providerRetries = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "provider_retries_total",
+		Help:      "Retried provider calls.",
+	}, []string{"provider", "operation"})
+
+	secretsWritten = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "secrets_written_total",
+		Help:      "Secrets written to the output.",
+	})
+
+	refreshes = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "refreshes_total",
+		Help:      "Secret refreshes (fetching and writing or caching the secrets) by result.",
+	}, []string{"result"})
+
+	lastSuccessfulRefresh = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "last_successful_refresh_timestamp_seconds",
+		Help:      "The unix time of the last successful refresh.",
+	})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		providerCallDuration,
+		providerErrors,
+		providerRetries,
+		secretsWritten,
+		refreshes,
+		lastSuccessfulRefresh,
+	)
+}
+
+// ObserveProviderCall - records the latency of a provider call attempt, and its error code if it failed
+func ObserveProviderCall(provider string, operation string, duration time.Duration, errorCode string) {
+	providerCallDuration.WithLabelValues(provider, operation).Observe(duration.Seconds())
+	if errorCode != "" {
+		providerErrors.WithLabelValues(provider, operation, errorCode).Inc()
+	}
+}
+
+// AddProviderRetry - counts a retried provider call
+func AddProviderRetry(provider string, operation string) {
+	providerRetries.WithLabelValues(provider, operation).Inc()
+}
+
+// AddSecretsWritten - counts the written secrets
+func AddSecretsWritten(count int) {
+	secretsWritten.Add(float64(count))
+}
+
+// RefreshSucceeded - counts a successful refresh and sets the last successful refresh time
+func RefreshSucceeded(t time.Time) {
+	refreshes.WithLabelValues("success").Inc()
+	lastSuccessfulRefresh.Set(float64(t.Unix()))
+}
+
+// RefreshFailed - counts a failed refresh
+func RefreshFailed() {
+	refreshes.WithLabelValues("failure").Inc()
+}
+
+// Handler - serves the metrics in the prometheus exposition format
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{})
+}
+
+// WriteTextfile - writes the metrics (without the go and process ones) for the node exporter's textfile collector.
+// The file is replaced atomically
+func WriteTextfile(path string) error {
+	return prometheus.WriteToTextfile(path, prometheus.GathererFunc(func() ([]*dto.MetricFamily, error) {
+		mfs, err := Registry.Gather()
+		if err != nil {
+			return nil, err
+		}
+
+		res := mfs[:0]
+		for _, mf := range mfs {
+			if strings.HasPrefix(mf.GetName(), namespace+"_") {
+				res = append(res, mf)
+			}
+		}
+		return res, nil
+	}))
+}
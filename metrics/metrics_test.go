@@ -0,0 +1,56 @@
+package metrics_test
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/daniel-cohen/secretsfetcher/metrics"
+)
+
+var _ = Describe("Metrics", func() {
+	BeforeEach(func() {
+		metrics.ObserveProviderCall("secretsmanager", "GetSecretValue", 20*time.Millisecond, "")
+		metrics.ObserveProviderCall("secretsmanager", "GetSecretValue", time.Second, "ThrottlingException")
+		metrics.AddProviderRetry("secretsmanager", "GetSecretValue")
+		metrics.AddSecretsWritten(3)
+		metrics.RefreshSucceeded(time.Unix(1700000000, 0))
+	})
+
+	It("serves the metrics", func() {
+		rec := httptest.NewRecorder()
+		metrics.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+
+		body := rec.Body.String()
+		Expect(body).To(ContainSubstring(`secretsfetcher_provider_call_duration_seconds_count{operation="GetSecretValue",provider="secretsmanager"}`))
+		Expect(body).To(ContainSubstring(`secretsfetcher_provider_errors_total{code="ThrottlingException",operation="GetSecretValue",provider="secretsmanager"}`))
+		Expect(body).To(ContainSubstring(`secretsfetcher_provider_retries_total{operation="GetSecretValue",provider="secretsmanager"}`))
+		Expect(body).To(ContainSubstring("secretsfetcher_last_successful_refresh_timestamp_seconds 1.7e+09"))
+		Expect(body).To(ContainSubstring("go_goroutines"))
+	})
+
+	It("writes only the secretsfetcher metrics to the textfile", func() {
+		tmpFolder, err := ioutil.TempDir("", "secretsfetcher")
+		Expect(err).NotTo(HaveOccurred())
+		defer os.RemoveAll(tmpFolder)
+
+		path := filepath.Join(tmpFolder, "secretsfetcher.prom")
+		Expect(metrics.WriteTextfile(path)).To(Succeed())
+
+		b, err := ioutil.ReadFile(path)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(b)).To(ContainSubstring("secretsfetcher_secrets_written_total"))
+		Expect(string(b)).NotTo(ContainSubstring("go_goroutines"))
+	})
+
+	It("fails to write the textfile to a missing folder", func() {
+		err := metrics.WriteTextfile(filepath.Join("/nonexistent", "secretsfetcher.prom"))
+		Expect(errors.Is(err, os.ErrNotExist)).To(BeTrue())
+	})
+})
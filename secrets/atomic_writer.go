@@ -0,0 +1,158 @@
+package secrets
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Like the kubernetes AtomicWriter, the output folder looks like:
+//
+//	..2021_08_01_10_00_00.123456789/  - the current generation holding the actual files
+//	..data -> ..2021_08_01_10_00_00.123456789
+//	my_secret -> ..data/my_secret
+//
+// Every write creates a new generation and swaps the ..data symlink atomically,
+// so readers see either the full old set of secrets or the full new one.
+const (
+	dataDirName          = "..data"
+	newDataDirName       = "..data_tmp"
+	generationPrefix     = ".."
+	generationTimeFormat = "2006_01_02_15_04_05."
+)
+
+// newGeneration - creates a new hidden timestamped folder to write the secrets to
+func newGeneration(outputFolder string) (string, error) {
+	return os.MkdirTemp(outputFolder, generationPrefix+time.Now().UTC().Format(generationTimeFormat))
+}
+
+// currentGeneration - the generation folder ..data points to, empty if there's none
+func currentGeneration(outputFolder string) string {
+	target, err := os.Readlink(filepath.Join(outputFolder, dataDirName))
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(outputFolder, target)
+}
+
+// isGeneration - true for hidden timestamped generation folders (and not the ..data symlinks)
+func isGeneration(name string) bool {
+	return strings.HasPrefix(name, generationPrefix) && name != dataDirName && name != newDataDirName
+}
+
+// swapDataDir - atomically points the ..data symlink to the given generation
+func swapDataDir(outputFolder string, generationDir string) error {
+	newDataDir := filepath.Join(outputFolder, newDataDirName)
+	os.Remove(newDataDir) // left over from a failed run
+
+	if err := os.Symlink(filepath.Base(generationDir), newDataDir); err != nil {
+		return fmt.Errorf("failed to create the %s symlink: %w", newDataDirName, err)
+	}
+
+	if err := os.Rename(newDataDir, filepath.Join(outputFolder, dataDirName)); err != nil {
+		os.Remove(newDataDir)
+		return fmt.Errorf("failed to swap the %s symlink: %w", dataDirName, err)
+	}
+
+	return nil
+}
+
+// updateVisiblePaths - links each top level name to ..data, and removes links which are no longer written
+func updateVisiblePaths(outputFolder string, names map[string]bool) error {
+	for name := range names {
+		target := filepath.Join(dataDirName, name)
+		visiblePath := filepath.Join(outputFolder, name)
+
+		if current, err := os.Readlink(visiblePath); err == nil && current == target {
+			continue
+		}
+
+		// create the link next to it and rename it over the existing path:
+		tmpPath := filepath.Join(outputFolder, generationPrefix+name+"_tmp")
+		os.Remove(tmpPath)
+		if err := os.Symlink(target, tmpPath); err != nil {
+			return fmt.Errorf("failed to link %s: %w", name, err)
+		}
+		if err := os.Rename(tmpPath, visiblePath); err != nil {
+			os.Remove(tmpPath)
+			return fmt.Errorf("failed to link %s: %w", name, err)
+		}
+	}
+
+	entries, err := os.ReadDir(outputFolder)
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		name := entry.Name()
+		if strings.HasPrefix(name, generationPrefix) || names[name] || entry.Type()&os.ModeSymlink == 0 {
+			continue
+		}
+
+		// only remove our own links:
+		if target, err := os.Readlink(filepath.Join(outputFolder, name)); err == nil && strings.HasPrefix(target, dataDirName+string(filepath.Separator)) {
+			if err := os.Remove(filepath.Join(outputFolder, name)); err != nil {
+				return fmt.Errorf("failed to remove the stale link %s: %w", name, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// cleanupGenerations - removes all the generations except the current one
+func cleanupGenerations(outputFolder string, currentGenerationDir string) error {
+	entries, err := os.ReadDir(outputFolder)
+	if err != nil {
+		return err
+	}
+
+	current := filepath.Base(currentGenerationDir)
+	for _, entry := range entries {
+		name := entry.Name()
+		if !entry.IsDir() || !isGeneration(name) || name == current {
+			continue
+		}
+
+		if err := os.RemoveAll(filepath.Join(outputFolder, name)); err != nil {
+			return fmt.Errorf("failed to remove the old generation %s: %w", name, err)
+		}
+	}
+
+	return nil
+}
+
+// topLevelName - the first path segment of a (safe) relative output file name
+func topLevelName(name string) string {
+	return strings.SplitN(filepath.ToSlash(filepath.Clean(name)), "/", 2)[0]
+}
+
+// writeFileAtomic - writes a single file next to its destination and renames it over it, so readers never see a partial file
+func writeFileAtomic(path string, content []byte, perms FilePermissions) error {
+	// CreateTemp only lets the process user read the file until its permissions are set:
+	f, err := os.CreateTemp(filepath.Dir(path), generationPrefix+filepath.Base(path)+"_tmp")
+	if err != nil {
+		return err
+	}
+	tmpPath := f.Name()
+
+	_, err = f.Write(content)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = setPermissions(tmpPath, perms)
+	}
+	if err == nil {
+		err = os.Rename(tmpPath, path)
+	}
+	if err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,18 @@
+package aws
+
+import (
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+)
+
+// assumeRole - replaces the config's credentials with the role's (cached and refreshed before they expire), assumed with the config's credentials.
+// An empty roleArn keeps the config as is
+func assumeRole(awsCfg aws.Config, roleArn string) aws.Config {
+	if roleArn == "" {
+		return awsCfg
+	}
+
+	awsCfg.Credentials = aws.NewCredentialsCache(stscreds.NewAssumeRoleProvider(sts.NewFromConfig(awsCfg), roleArn))
+	return awsCfg
+}
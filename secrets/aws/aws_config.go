@@ -1,11 +1,68 @@
 package aws
 
+import "time"
+
+const (
+	DefaultTimeout        = 5 * time.Minute
+	DefaultRequestTimeout = 30 * time.Second
+)
+
 type AWSConfig struct {
 	PrefixFilter string
 
 	TagKeyFilters   []string
 	TagValueFilters []string
 
+	// ssm parameter store path listing mode:
+	ParameterPath      string
+	ParameterRecursive bool
+
 	Region          string
 	PathTranslation string
+
+	// an optional role assumed for all the aws calls. E.g: a role in another account
+	RoleArn string
+
+	// failfast, failatend or besteffort. Empty uses the shared failure policy
+	FailurePolicy string
+
+	// the maximum number of secrets fetched in parallel
+	Concurrency int
+
+	// fetch secrets using BatchGetSecretValue (falls back to GetSecretValue if not permitted)
+	BatchFetch bool
+
+	// retry policy for secrets manager calls:
+	RetryMaxAttempts    int
+	RetryBaseBackoff    time.Duration
+	RetryMaxBackoff     time.Duration
+	RetryJitter         float64
+	RetryableErrorCodes []string
+
+	// the overall timeout for fetching and writing the secrets, and the timeout for each aws request attempt. 0 disables them
+	Timeout        time.Duration
+	RequestTimeout time.Duration
+
+	// client side rate limit (requests per second) for secrets manager calls. 0 disables the limit
+	RateLimit      float64
+	RateLimitBurst int
+}
+
+// RetryPolicy - the retry policy from the config. The jitter is clamped to [0, 1] so the backoff can't be negative
+func (c *AWSConfig) RetryPolicy() RetryPolicy {
+	jitter := c.RetryJitter
+	if jitter < 0 {
+		jitter = 0
+	}
+	if jitter > 1 {
+		jitter = 1
+	}
+
+	return RetryPolicy{
+		MaxAttempts:         c.RetryMaxAttempts,
+		BaseBackoff:         c.RetryBaseBackoff,
+		MaxBackoff:          c.RetryMaxBackoff,
+		Jitter:              jitter,
+		RetryableErrorCodes: c.RetryableErrorCodes,
+	}
 }
@@ -1,6 +1,7 @@
 package aws
 
 import (
+	"context"
 	"fmt"
 
 	"github.com/daniel-cohen/secretsfetcher/secrets"
@@ -8,41 +9,115 @@ import (
 )
 
 type ManifestSecretsFetcher struct {
-	// implements secrets.SecretsFetcher
-	zl       *zap.Logger
-	provider *AWSSecretsManagerProvider
+	// implements secrets.ChangesFetcher
+	zl          *zap.Logger
+	provider    *AWSSecretsManagerProvider
+	ssmProvider *AWSSSMParameterProvider
 
 	manifest *SecretManifest
+
+	// the secrets of each secrets manager object from the previous FetchChanges:
+	previous [][]*secrets.Secret
 }
 
-func NewManifestSecretFetcher(provider *AWSSecretsManagerProvider, manifest *SecretManifest, zl *zap.Logger) *ManifestSecretsFetcher {
+func NewManifestSecretFetcher(
+	provider *AWSSecretsManagerProvider,
+	ssmProvider *AWSSSMParameterProvider,
+	manifest *SecretManifest,
+	zl *zap.Logger) *ManifestSecretsFetcher {
 	return &ManifestSecretsFetcher{
-		provider: provider,
-		manifest: manifest,
-		zl:       zl,
+		provider:    provider,
+		ssmProvider: ssmProvider,
+		manifest:    manifest,
+		zl:          zl,
 	}
 }
 
-func (msf *ManifestSecretsFetcher) Fetch() ([]*secrets.Secret, error) {
-	secretRes, err := msf.provider.FetchSecrets(msf.manifest.SecretObjects)
-	if err != nil {
-		msf.zl.Error("failed to fetch secrets from aws secrets provider",
-			zap.Any("secretObjects", msf.manifest.SecretObjects),
-			zap.Error(err))
+func (msf *ManifestSecretsFetcher) Fetch(ctx context.Context) ([]*secrets.Secret, error) {
+	return msf.fetch(ctx, false)
+}
+
+// FetchChanges - only fetches the secrets manager objects whose version changed (using DescribeSecret). SSM parameters are always fetched
+func (msf *ManifestSecretsFetcher) FetchChanges(ctx context.Context) ([]*secrets.Secret, error) {
+	return msf.fetch(ctx, true)
+}
 
+func (msf *ManifestSecretsFetcher) fetch(ctx context.Context, changesOnly bool) ([]*secrets.Secret, error) {
+	if err := msf.manifest.Validate(); err != nil {
+		msf.zl.Error("invalid manifest", zap.Error(err))
 		return nil, err
 	}
 
+	// Split the objects by the store they are fetched from:
+	var secretObjs, parameterObjs []*AwsSecretObject
+	for _, obj := range msf.manifest.SecretObjects {
+		switch obj.ObjectType {
+		case "", ObjectTypeSecretsManager:
+			secretObjs = append(secretObjs, obj)
+		case ObjectTypeSSMParameter:
+			parameterObjs = append(parameterObjs, obj)
+		default:
+			msf.zl.Error("unsupported object type", zap.String("objectName", obj.ObjectName), zap.String("objectType", obj.ObjectType))
+			return nil, fmt.Errorf("unsupported objectType %q for object %s", obj.ObjectType, obj.ObjectName)
+		}
+	}
+
+	var secretRes []*secrets.Secret
+	if len(secretObjs) > 0 && changesOnly {
+		res, err := msf.provider.FetchChangedSecrets(ctx, secretObjs, msf.previous)
+		if err != nil {
+			msf.zl.Error("failed to fetch changed secrets from aws secrets provider",
+				zap.Any("secretObjects", secretObjs),
+				zap.Error(err))
+
+			return nil, err
+		}
+		msf.previous = res
+
+		for _, objSecrets := range res {
+			secretRes = append(secretRes, objSecrets...)
+		}
+	} else if len(secretObjs) > 0 {
+		res, err := msf.provider.FetchSecrets(ctx, secretObjs)
+		if err != nil {
+			msf.zl.Error("failed to fetch secrets from aws secrets provider",
+				zap.Any("secretObjects", secretObjs),
+				zap.Error(err))
+
+			return nil, err
+		}
+		secretRes = append(secretRes, res...)
+	}
+
+	if len(parameterObjs) > 0 {
+		if msf.ssmProvider == nil {
+			return nil, fmt.Errorf("manifest contains ssm parameters but no ssm parameter provider is set")
+		}
+
+		res, err := msf.ssmProvider.FetchParameters(ctx, parameterObjs)
+		if err != nil {
+			msf.zl.Error("failed to fetch parameters from aws ssm parameter provider",
+				zap.Any("secretObjects", parameterObjs),
+				zap.Error(err))
+
+			return nil, err
+		}
+		secretRes = append(secretRes, res...)
+	}
+
 	return secretRes, nil
 }
 
 type ListSecretFetcher struct {
-	// implements secrets.SecretsFetcher
+	// implements secrets.ChangesFetcher
 	zl              *zap.Logger
 	provider        *AWSSecretsManagerProvider
 	prefixFilter    string
 	tagKeyFilters   []string
 	tagValueFilters []string
+
+	// the secrets of each listed secret (by ARN) from the previous FetchChanges:
+	previous map[string][]*secrets.Secret
 }
 
 func NewListSecretFetcher(
@@ -60,13 +135,13 @@ func NewListSecretFetcher(
 	}
 }
 
-func (lsf *ListSecretFetcher) Fetch() ([]*secrets.Secret, error) {
+func (lsf *ListSecretFetcher) Fetch(ctx context.Context) ([]*secrets.Secret, error) {
 	if lsf.prefixFilter == "" {
 		lsf.zl.Error("prefix filter not set")
 		return nil, fmt.Errorf("prefix filter cannot be empty ")
 	}
 
-	secretRes, err := lsf.provider.FetchAllSecrets(lsf.prefixFilter, lsf.tagKeyFilters, lsf.tagValueFilters)
+	secretRes, err := lsf.provider.FetchAllSecrets(ctx, lsf.prefixFilter, lsf.tagKeyFilters, lsf.tagValueFilters)
 
 	if err != nil {
 		lsf.zl.Error("failed to fetch all secrets from aws secrets provider",
@@ -79,3 +154,67 @@ func (lsf *ListSecretFetcher) Fetch() ([]*secrets.Secret, error) {
 
 	return secretRes, nil
 }
+
+// FetchChanges - lists the secrets and only fetches the new ones or the ones whose current version changed
+func (lsf *ListSecretFetcher) FetchChanges(ctx context.Context) ([]*secrets.Secret, error) {
+	if lsf.prefixFilter == "" {
+		lsf.zl.Error("prefix filter not set")
+		return nil, fmt.Errorf("prefix filter cannot be empty ")
+	}
+
+	res, arns, err := lsf.provider.FetchAllChangedSecrets(ctx, lsf.prefixFilter, lsf.tagKeyFilters, lsf.tagValueFilters, lsf.previous)
+	if err != nil {
+		lsf.zl.Error("failed to fetch changed secrets from aws secrets provider",
+			zap.String("prefixFilter", lsf.prefixFilter),
+			zap.Strings("tagKeyFilters", lsf.tagKeyFilters),
+			zap.Strings("tagValueFilters", lsf.tagValueFilters),
+			zap.Error(err))
+		return nil, err
+	}
+	lsf.previous = res
+
+	var secretRes []*secrets.Secret
+	for _, arn := range arns {
+		secretRes = append(secretRes, res[arn]...)
+	}
+	return secretRes, nil
+}
+
+type ParameterPathSecretFetcher struct {
+	// implements secrets.SecretsFetcher
+	zl            *zap.Logger
+	provider      *AWSSSMParameterProvider
+	parameterPath string
+	recursive     bool
+}
+
+func NewParameterPathSecretFetcher(
+	provider *AWSSSMParameterProvider,
+	parameterPath string,
+	recursive bool,
+	zl *zap.Logger) *ParameterPathSecretFetcher {
+	return &ParameterPathSecretFetcher{
+		zl:            zl,
+		provider:      provider,
+		parameterPath: parameterPath,
+		recursive:     recursive,
+	}
+}
+
+func (psf *ParameterPathSecretFetcher) Fetch(ctx context.Context) ([]*secrets.Secret, error) {
+	if psf.parameterPath == "" {
+		psf.zl.Error("parameter path not set")
+		return nil, fmt.Errorf("parameter path cannot be empty ")
+	}
+
+	secretRes, err := psf.provider.FetchParametersByPath(ctx, psf.parameterPath, psf.recursive)
+	if err != nil {
+		psf.zl.Error("failed to fetch parameters by path from aws ssm parameter provider",
+			zap.String("parameterPath", psf.parameterPath),
+			zap.Bool("recursive", psf.recursive),
+			zap.Error(err))
+		return nil, err
+	}
+
+	return secretRes, nil
+}
@@ -1,9 +1,48 @@
 package aws
 
+import (
+	"fmt"
+
+	"github.com/daniel-cohen/secretsfetcher/secrets"
+)
+
+const (
+	ObjectTypeSecretsManager = "secretsmanager"
+	ObjectTypeSSMParameter   = "ssmparameter"
+)
+
 type AwsSecretObject struct {
-	ObjectName    string
-	ObjectVersion string
-	//ObjectType    string // - currently we will only support the secretsmanager object type
-	//objectAlias
+	ObjectName         string
+	ObjectVersion      string
+	ObjectType         string // secretsmanager (default if empty) or ssmparameter
+	ObjectAlias        string // optional output file name, defaults to the secret name
 	ObjectVersionLabel string // object version stage, default to latest if empty
+
+	// optional objects which fail to be fetched are skipped regardless of the failure policy
+	Optional bool
+
+	// optional overrides of the writer's file mode (octal. E.g: "0400") and owner
+	FileMode string
+	UID      *int
+	GID      *int
+
+	// optional json fields to extract into their own secrets (in addition to the whole secret)
+	JMESPath []*JMESPathEntry
+}
+
+// FilePermissions - the object's file mode and owner overrides, nil if none are set
+func (o *AwsSecretObject) FilePermissions() (*secrets.FilePermissions, error) {
+	perms, err := secrets.ObjectFilePermissions(o.FileMode, o.UID, o.GID)
+	if err != nil {
+		return nil, fmt.Errorf("object %s: %w", o.ObjectName, err)
+	}
+	return perms, nil
+}
+
+// outputOptions - sets the object's name, output name and file permissions on the fetched secret
+func (o *AwsSecretObject) outputOptions(secret *secrets.Secret) {
+	secret.Object = o.ObjectName
+	secret.Alias = o.ObjectAlias
+	// invalid modes are rejected when the manifest is validated:
+	secret.Permissions, _ = o.FilePermissions()
 }
@@ -0,0 +1,240 @@
+package aws
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager/types"
+	"github.com/aws/smithy-go"
+	"github.com/daniel-cohen/secretsfetcher/secrets"
+	"go.uber.org/zap"
+)
+
+const (
+	// BatchGetSecretValue accepts up to 20 secret ids (or results per page when using filters)
+	maxBatchSecrets = 20
+
+	DefaultBatchFetch = true
+)
+
+// WithBatch - fetch secrets using BatchGetSecretValue where possible.
+// If the api is not permitted we fall back to fetching the secrets one by one.
+func (p *AWSSecretsManagerProvider) WithBatch(batch bool) *AWSSecretsManagerProvider {
+	p.batch = batch
+	return p
+}
+
+func (p *AWSSecretsManagerProvider) batchEnabled() bool {
+	return p.batch && !p.batchNotPermitted.Load()
+}
+
+func (p *AWSSecretsManagerProvider) disableBatch(err error) {
+	p.zl.Warn("BatchGetSecretValue is not permitted, falling back to fetching secrets one by one", zap.Error(err))
+	p.batchNotPermitted.Store(true)
+}
+
+// isBatchNotPermitted - the caller isn't allowed to call BatchGetSecretValue (or the endpoint doesn't support it)
+func isBatchNotPermitted(err error) bool {
+	var ae smithy.APIError
+	if !errors.As(err, &ae) {
+		return false
+	}
+
+	switch ae.ErrorCode() {
+	case "AccessDeniedException", "UnrecognizedClientException", "UnknownOperationException", "InvalidAction":
+		return true
+	}
+	return false
+}
+
+// batchGetSecretValues - pages through BatchGetSecretValue for the given input
+func (p *AWSSecretsManagerProvider) batchGetSecretValues(ctx context.Context, input *secretsmanager.BatchGetSecretValueInput) ([]types.SecretValueEntry, []types.APIErrorType, error) {
+	var (
+		values    []types.SecretValueEntry
+		apiErrors []types.APIErrorType
+	)
+
+	// do while we have more secrets to page through:
+	for {
+		output, err := p.awsClient.BatchGetSecretValue(ctx, input)
+		if err != nil {
+			p.zl.Error("request to batch get secret values failed", zap.Strings("secretIds", input.SecretIdList), zap.Error(err))
+			return nil, nil, err
+		}
+
+		values = append(values, output.SecretValues...)
+		apiErrors = append(apiErrors, output.Errors...)
+
+		if output.NextToken == nil {
+			break
+		}
+
+		input.NextToken = output.NextToken
+	}
+
+	return values, apiErrors, nil
+}
+
+func secretFromValueEntry(entry *types.SecretValueEntry) (*secrets.Secret, error) {
+	if entry.Name == nil {
+		return nil, fmt.Errorf("recieved a secret value with an empty name")
+	}
+
+	secretString, err := decodeSecretValue(entry.SecretString, entry.SecretBinary)
+	if err != nil {
+		return nil, err
+	}
+
+	return &secrets.Secret{
+		Name:    *entry.Name,
+		Content: secretString,
+		Version: aws.ToString(entry.VersionId),
+	}, nil
+}
+
+// batchErrorToAPIError - converts an entry in the BatchGetSecretValue errors array so it's reported like any other aws error
+func batchErrorToAPIError(apiErr *types.APIErrorType) error {
+	return &smithy.GenericAPIError{
+		Code:    aws.ToString(apiErr.ErrorCode),
+		Message: aws.ToString(apiErr.Message),
+	}
+}
+
+// batchFetchSecrets - fetches all the objects which don't pin a version in batches, filling in their results.
+// The objects left without a result (E.g: partial ARNs, or all of them once batches aren't permitted) still need to be fetched one by one.
+func (p *AWSSecretsManagerProvider) batchFetchSecrets(ctx context.Context, secretObjs []*AwsSecretObject, results []fetchResult) {
+	var batchable []int
+	for i, secretObj := range secretObjs {
+		// BatchGetSecretValue only returns the current version:
+		if secretObj.ObjectVersion == "" && secretObj.ObjectVersionLabel == "" {
+			batchable = append(batchable, i)
+		}
+	}
+
+	for start := 0; start < len(batchable); start += maxBatchSecrets {
+		end := start + maxBatchSecrets
+		if end > len(batchable) {
+			end = len(batchable)
+		}
+		chunk := batchable[start:end]
+
+		if !p.batchEnabled() {
+			return
+		}
+
+		// the object name can be the secret name or ARN:
+		byId := map[string][]int{}
+		var secretIds []string
+		for _, i := range chunk {
+			name := secretObjs[i].ObjectName
+			if _, ok := byId[name]; !ok {
+				secretIds = append(secretIds, name)
+			}
+			byId[name] = append(byId[name], i)
+		}
+
+		values, apiErrors, err := p.batchGetSecretValues(ctx, &secretsmanager.BatchGetSecretValueInput{
+			SecretIdList: secretIds,
+		})
+		if err != nil {
+			if isBatchNotPermitted(err) {
+				p.disableBatch(err)
+			}
+			// We'll try these one by one:
+			continue
+		}
+
+		for j := range values {
+			entry := &values[j]
+			secret, err := secretFromValueEntry(entry)
+
+			indexes := append(byId[aws.ToString(entry.Name)], byId[aws.ToString(entry.ARN)]...)
+			for _, i := range indexes {
+				if results[i].done {
+					continue
+				}
+
+				if err != nil {
+					results[i] = fetchResult{err: err, done: true}
+					continue
+				}
+
+				objSecret := *secret
+				secretObjs[i].outputOptions(&objSecret)
+				res, err := p.withJMESPathSecrets(&objSecret, secretObjs[i])
+				results[i] = fetchResult{secrets: res, err: err, done: true}
+			}
+		}
+
+		for j := range apiErrors {
+			apiErr := &apiErrors[j]
+			p.zl.Error("failed to get seceret value",
+				zap.Stringp("secretId", apiErr.SecretId),
+				zap.Stringp("errorCode", apiErr.ErrorCode),
+				zap.Stringp("errorMessage", apiErr.Message),
+			)
+
+			for _, i := range byId[aws.ToString(apiErr.SecretId)] {
+				results[i] = fetchResult{err: batchErrorToAPIError(apiErr), done: true}
+			}
+		}
+	}
+}
+
+// batchFetchAllSecrets - fetches all the secrets matching the filters using BatchGetSecretValue
+func (p *AWSSecretsManagerProvider) batchFetchAllSecrets(ctx context.Context, secretNamePrefix string, tagKeyFilters []string, tagValueFilters []string) ([]*secrets.Secret, error) {
+	if strings.TrimSpace(secretNamePrefix) == "" {
+		return nil, fmt.Errorf("secretNamePrefix cannot be empty")
+	}
+
+	values, apiErrors, err := p.batchGetSecretValues(ctx, &secretsmanager.BatchGetSecretValueInput{
+		Filters:    listFilters(secretNamePrefix, tagKeyFilters, tagValueFilters),
+		MaxResults: aws.Int32(maxBatchSecrets),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	p.zl.Debug("batch fetched secrets", zap.Int("secretCount", len(values)), zap.Int("errorCount", len(apiErrors)))
+
+	var res []*secrets.Secret
+	fetchErrs := newFetchErrors(p.failurePolicy, p.zl)
+	for j := range apiErrors {
+		apiErr := &apiErrors[j]
+		p.zl.Error("failed to get seceret value",
+			zap.Stringp("secretId", apiErr.SecretId),
+			zap.Stringp("errorCode", apiErr.ErrorCode),
+			zap.Stringp("errorMessage", apiErr.Message),
+		)
+
+		if fetchErrs.add(&AwsSecretObject{ObjectName: aws.ToString(apiErr.SecretId)}, batchErrorToAPIError(apiErr)) {
+			return nil, fetchErrs.ErrorOrNil()
+		}
+	}
+
+	for j := range values {
+		secret, err := secretFromValueEntry(&values[j])
+		if err != nil {
+			if fetchErrs.add(&AwsSecretObject{ObjectName: aws.ToString(values[j].ARN)}, err) {
+				return nil, fetchErrs.ErrorOrNil()
+			}
+			continue
+		}
+
+		p.zl.Info("secret listed",
+			zap.Stringp("arn", values[j].ARN),
+			zap.Stringp("name", values[j].Name),
+		)
+		res = append(res, secret)
+	}
+
+	if err := fetchErrs.ErrorOrNil(); err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
@@ -5,6 +5,8 @@ import (
 	"encoding/base64"
 	"fmt"
 	"strings"
+	"sync/atomic"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/config"
@@ -15,33 +17,52 @@ import (
 
 	"github.com/aws/aws-sdk-go-v2/service/secretsmanager/types"
 	"go.uber.org/zap"
+	"golang.org/x/time/rate"
 )
 
 const (
 	defaultMaxResults = 50
+
+	DefaultConcurrency = 10
 )
 
 type secretsManagerAPI interface {
 	ListSecrets(ctx context.Context, params *secretsmanager.ListSecretsInput, optFns ...func(*secretsmanager.Options)) (*secretsmanager.ListSecretsOutput, error)
 	GetSecretValue(ctx context.Context, params *secretsmanager.GetSecretValueInput, optFns ...func(*secretsmanager.Options)) (*secretsmanager.GetSecretValueOutput, error)
+	BatchGetSecretValue(ctx context.Context, params *secretsmanager.BatchGetSecretValueInput, optFns ...func(*secretsmanager.Options)) (*secretsmanager.BatchGetSecretValueOutput, error)
+	DescribeSecret(ctx context.Context, params *secretsmanager.DescribeSecretInput, optFns ...func(*secretsmanager.Options)) (*secretsmanager.DescribeSecretOutput, error)
 }
 
 type AWSSecretsManagerProvider struct {
-	zl        *zap.Logger
-	awsClient secretsManagerAPI
-	region    string
+	zl            *zap.Logger
+	awsClient     secretsManagerAPI
+	region        string
+	failurePolicy FailurePolicy
+	concurrency   int
+
+	batch             bool
+	batchNotPermitted atomic.Bool
+
+	// the awsClient wrapped with retries and rate limiting:
+	retryClient *retryingSecretsManagerClient
 }
 
 func newAWSSecretsManagerProviderFromClient(awsClient secretsManagerAPI, region string, zl *zap.Logger) *AWSSecretsManagerProvider {
+	zl = zl.With(zap.String("secretsProvider", "aws_secrets_manger"))
+	retryClient := newRetryingSecretsManagerClient(awsClient, zl)
+
 	//Create a Secrets Manager client
 	return &AWSSecretsManagerProvider{
-		awsClient: awsClient,
-		zl:        zl.With(zap.String("secretsProvider", "aws_secrets_manger")),
-		region:    region,
+		awsClient:     retryClient,
+		retryClient:   retryClient,
+		zl:            zl,
+		region:        region,
+		failurePolicy: DefaultFailurePolicy,
+		concurrency:   DefaultConcurrency,
 	}
 }
 
-func NewAWSSecretsManagerProvider(region string, zl *zap.Logger) (*AWSSecretsManagerProvider, error) {
+func NewAWSSecretsManagerProvider(ctx context.Context, region string, roleArn string, zl *zap.Logger) (*AWSSecretsManagerProvider, error) {
 	//Create a Secrets Manager client
 	var (
 		awsCfg aws.Config
@@ -49,7 +70,11 @@ func NewAWSSecretsManagerProvider(region string, zl *zap.Logger) (*AWSSecretsMan
 	)
 
 	awsLogger := logging.NewAwsLogger(zl)
-	aswOptions := []func(*config.LoadOptions) error{config.WithLogger(awsLogger)}
+	aswOptions := []func(*config.LoadOptions) error{
+		config.WithLogger(awsLogger),
+		// retries are handled by our own retry policy:
+		config.WithRetryer(func() aws.Retryer { return aws.NopRetryer{} }),
+	}
 
 	// Enable aws debug logging:
 	if zl.Core().Enabled(zap.DebugLevel) {
@@ -60,12 +85,12 @@ func NewAWSSecretsManagerProvider(region string, zl *zap.Logger) (*AWSSecretsMan
 		aswOptions = append(aswOptions, config.WithRegion(region))
 	}
 
-	awsCfg, err = config.LoadDefaultConfig(context.Background(), aswOptions...)
+	awsCfg, err = config.LoadDefaultConfig(ctx, aswOptions...)
 	if err != nil {
 		return nil, err
 	}
 
-	svc := secretsmanager.NewFromConfig(awsCfg)
+	svc := secretsmanager.NewFromConfig(assumeRole(awsCfg, roleArn))
 
 	return newAWSSecretsManagerProviderFromClient(svc, region, zl), nil
 }
@@ -74,7 +99,56 @@ func (p *AWSSecretsManagerProvider) Region() string {
 	return p.region
 }
 
-func (p *AWSSecretsManagerProvider) getSecretValue(secretObj *AwsSecretObject) (*secrets.Secret, error) {
+// WithFailurePolicy - sets how secrets which failed to be fetched are handled
+func (p *AWSSecretsManagerProvider) WithFailurePolicy(policy FailurePolicy) *AWSSecretsManagerProvider {
+	p.failurePolicy = policy
+	return p
+}
+
+// WithRetryPolicy - sets how failed ListSecrets/GetSecretValue/BatchGetSecretValue calls are retried
+func (p *AWSSecretsManagerProvider) WithRetryPolicy(policy RetryPolicy) *AWSSecretsManagerProvider {
+	if policy.MaxAttempts < 1 {
+		policy.MaxAttempts = 1
+	}
+	p.retryClient.policy = policy
+	return p
+}
+
+// WithRateLimit - limits the aws calls (including retries) to requestsPerSecond with the given burst. 0 disables the limit
+func (p *AWSSecretsManagerProvider) WithRateLimit(requestsPerSecond float64, burst int) *AWSSecretsManagerProvider {
+	if requestsPerSecond <= 0 {
+		p.retryClient.limiter = rate.NewLimiter(rate.Inf, 0)
+		return p
+	}
+
+	if burst < 1 {
+		burst = 1
+	}
+	p.retryClient.limiter = rate.NewLimiter(rate.Limit(requestsPerSecond), burst)
+	return p
+}
+
+// RetryCounts - the number of retries per secret id (or operation name for list/batch calls)
+func (p *AWSSecretsManagerProvider) RetryCounts() map[string]int {
+	return p.retryClient.RetryCounts()
+}
+
+// WithRequestTimeout - sets a timeout for each aws call attempt. 0 disables it
+func (p *AWSSecretsManagerProvider) WithRequestTimeout(timeout time.Duration) *AWSSecretsManagerProvider {
+	p.retryClient.requestTimeout = timeout
+	return p
+}
+
+// WithConcurrency - sets the maximum number of secrets fetched in parallel. Values below 1 fetch serially.
+func (p *AWSSecretsManagerProvider) WithConcurrency(concurrency int) *AWSSecretsManagerProvider {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	p.concurrency = concurrency
+	return p
+}
+
+func (p *AWSSecretsManagerProvider) getSecretValue(ctx context.Context, secretObj *AwsSecretObject) (*secrets.Secret, error) {
 	input := &secretsmanager.GetSecretValueInput{
 		SecretId: aws.String(secretObj.ObjectName), // this can be the name or full ARN
 	}
@@ -93,7 +167,7 @@ func (p *AWSSecretsManagerProvider) getSecretValue(secretObj *AwsSecretObject) (
 		zap.String("objectVersionLabel", secretObj.ObjectVersionLabel),
 	}
 
-	result, err := p.awsClient.GetSecretValue(context.Background(), input)
+	result, err := p.awsClient.GetSecretValue(ctx, input)
 	if err != nil {
 		switch ae := err.(type) {
 		case smithy.APIError:
@@ -110,76 +184,125 @@ func (p *AWSSecretsManagerProvider) getSecretValue(secretObj *AwsSecretObject) (
 		return nil, err
 	}
 
-	// Decrypts secret using the associated KMS CMK.
-	// Depending on whether the secret is a string or binary, one of these fields will be populated.
-	var secretString string
-	if result.SecretString != nil {
-		secretString = *result.SecretString
+	secretString, err := decodeSecretValue(result.SecretString, result.SecretBinary)
+	if err != nil {
+		p.zl.With(logFields...).Error("Base64 Decode Error:", zap.Error(err))
+		return nil, err
+	}
 
-		p.zl.With(logFields...).Debug("successfully got secret string value",
-			zap.Stringp("secretArn", result.ARN),
-		)
+	p.zl.With(logFields...).Debug("successfully got secret value",
+		zap.Stringp("secretArn", result.ARN),
+	)
 
-	} else {
-		decodedBinarySecretBytes := make([]byte, base64.StdEncoding.DecodedLen(len(result.SecretBinary)))
-		len, err := base64.StdEncoding.Decode(decodedBinarySecretBytes, result.SecretBinary)
-		if err != nil {
-			p.zl.With(logFields...).Error("Base64 Decode Error:", zap.Error(err))
-			return nil, err
-		}
-		secretString = string(decodedBinarySecretBytes[:len])
+	secret := &secrets.Secret{
+		Name:    *result.Name,
+		Content: secretString,
+		Version: aws.ToString(result.VersionId),
+	}
+	secretObj.outputOptions(secret)
+	return secret, nil
+}
 
-		p.zl.With(logFields...).Debug("successfully got secret binary value",
-			zap.Stringp("secretArn", result.ARN),
-		)
+// decodeSecretValue - Decrypts secret using the associated KMS CMK.
+// Depending on whether the secret is a string or binary, one of these fields will be populated.
+func decodeSecretValue(secretString *string, secretBinary []byte) (string, error) {
+	if secretString != nil {
+		return *secretString, nil
 	}
 
-	return &secrets.Secret{
-		Name:    *result.Name,
-		Content: secretString,
-	}, nil
+	decodedBinarySecretBytes := make([]byte, base64.StdEncoding.DecodedLen(len(secretBinary)))
+	len, err := base64.StdEncoding.Decode(decodedBinarySecretBytes, secretBinary)
+	if err != nil {
+		return "", err
+	}
+	return string(decodedBinarySecretBytes[:len]), nil
 }
 
-func (p *AWSSecretsManagerProvider) FetchSecrets(secretObjs []*AwsSecretObject) ([]*secrets.Secret, error) {
-	var res []*secrets.Secret
-	// Get the values one by one:
-	for _, secretObj := range secretObjs {
-		if secret, err := p.getSecretValue(secretObj); err != nil {
-			// It will be logged and we'll continue to other secrets, we don't want to stop:
-			continue
-		} else {
-			res = append(res, secret)
-		}
+// fetchResult - the outcome of fetching a single secret object in a batch
+type fetchResult struct {
+	secrets []*secrets.Secret
+	err     error
+	done    bool
+}
+
+func (p *AWSSecretsManagerProvider) fetchSecret(ctx context.Context, secretObj *AwsSecretObject) ([]*secrets.Secret, error) {
+	secret, err := p.getSecretValue(ctx, secretObj)
+	if err != nil {
+		return nil, err
 	}
 
-	return res, nil
+	return p.withJMESPathSecrets(secret, secretObj)
 }
 
-func (p *AWSSecretsManagerProvider) FetchAllSecrets(secretNamePrefix string, tagKeyFilters []string, tagValueFilters []string) ([]*secrets.Secret, error) {
-	secretObjects, err := p.listSecrets(secretNamePrefix, tagKeyFilters, tagValueFilters)
+// withJMESPathSecrets - returns the secret followed by the fields extracted from it
+func (p *AWSSecretsManagerProvider) withJMESPathSecrets(secret *secrets.Secret, secretObj *AwsSecretObject) ([]*secrets.Secret, error) {
+	fields, err := extractJMESPathSecrets(secret, secretObj.JMESPath)
 	if err != nil {
+		p.zl.Error("failed to extract jmesPath fields", zap.String("objectName", secretObj.ObjectName), zap.Error(err))
 		return nil, err
 	}
 
-	p.zl.Debug("listed secrets", zap.Int("secretCount", len(secretObjects)))
+	return append([]*secrets.Secret{secret}, fields...), nil
+}
 
-	return p.FetchSecrets(secretObjects)
+// FetchSecrets - fetches the secrets in batches (when enabled) and the rest in parallel (up to the concurrency limit).
+// The result preserves the order of secretObjs.
+func (p *AWSSecretsManagerProvider) FetchSecrets(ctx context.Context, secretObjs []*AwsSecretObject) ([]*secrets.Secret, error) {
+	byObject, err := p.fetchSecretsByObject(ctx, secretObjs)
+	if err != nil {
+		return nil, err
+	}
 
+	var res []*secrets.Secret
+	for _, objSecrets := range byObject {
+		res = append(res, objSecrets...)
+	}
+	return res, nil
 }
 
-// We will fetch a list of ARNS and construct AwsSecretObject with the latest versions:
-// We can set a range of tag filters . E.g. app=api-verifier
-// SecretNamePrefix - is mandatory. E.:g secretNamePrefix= api-verifier/
-func (p *AWSSecretsManagerProvider) listSecrets(secretNamePrefix string, tagKeyFilters []string, tagValueFilters []string) ([]*AwsSecretObject, error) {
-	if strings.TrimSpace(secretNamePrefix) == "" {
-		return nil, fmt.Errorf("secretNamePrefix cannot be empty")
+// fetchSecretsByObject - fetches the secrets, returning the secrets of each object at its index (nil for skipped failures).
+// The objects which weren't fetched in batches are fetched one by one in parallel, and the failure policy applies to both
+func (p *AWSSecretsManagerProvider) fetchSecretsByObject(ctx context.Context, secretObjs []*AwsSecretObject) ([][]*secrets.Secret, error) {
+	results := make([]fetchResult, len(secretObjs))
+	if p.batchEnabled() {
+		p.batchFetchSecrets(ctx, secretObjs, results)
 	}
 
-	//var secretARNs []string
-	var nextToken *string
+	refs := make([]secrets.ObjectRef, len(secretObjs))
+	for i, secretObj := range secretObjs {
+		refs[i] = secrets.ObjectRef{Name: secretObj.ObjectName, Optional: secretObj.Optional}
+	}
 
-	var secretObjects []*AwsSecretObject
+	return secrets.FetchObjectSecrets(ctx, refs, p.concurrency, p.failurePolicy, func(ctx context.Context, i int) ([]*secrets.Secret, error) {
+		if results[i].done {
+			return results[i].secrets, results[i].err
+		}
+		return p.fetchSecret(ctx, secretObjs[i])
+	}, p.zl)
+}
+
+func (p *AWSSecretsManagerProvider) FetchAllSecrets(ctx context.Context, secretNamePrefix string, tagKeyFilters []string, tagValueFilters []string) ([]*secrets.Secret, error) {
+	if p.batchEnabled() {
+		res, err := p.batchFetchAllSecrets(ctx, secretNamePrefix, tagKeyFilters, tagValueFilters)
+		if !isBatchNotPermitted(err) {
+			return res, err
+		}
+		p.disableBatch(err)
+	}
+
+	secretObjects, _, err := p.listSecrets(ctx, secretNamePrefix, tagKeyFilters, tagValueFilters)
+	if err != nil {
+		return nil, err
+	}
+
+	p.zl.Debug("listed secrets", zap.Int("secretCount", len(secretObjects)))
+
+	return p.FetchSecrets(ctx, secretObjects)
 
+}
+
+// listFilters - the secret name prefix filter and tag filters shared by ListSecrets and BatchGetSecretValue
+func listFilters(secretNamePrefix string, tagKeyFilters []string, tagValueFilters []string) []types.Filter {
 	filters := []types.Filter{{Key: types.FilterNameStringTypeName, Values: []string{secretNamePrefix}}}
 
 	for _, v := range tagKeyFilters {
@@ -194,17 +317,37 @@ func (p *AWSSecretsManagerProvider) listSecrets(secretNamePrefix string, tagKeyF
 		)
 	}
 
+	return filters
+}
+
+// We will fetch a list of ARNS and construct AwsSecretObject with the latest versions:
+// We can set a range of tag filters . E.g. app=api-verifier
+// SecretNamePrefix - is mandatory. E.:g secretNamePrefix= api-verifier/
+// Also returns the current (AWSCURRENT) version id of each listed secret.
+func (p *AWSSecretsManagerProvider) listSecrets(ctx context.Context, secretNamePrefix string, tagKeyFilters []string, tagValueFilters []string) ([]*AwsSecretObject, []string, error) {
+	if strings.TrimSpace(secretNamePrefix) == "" {
+		return nil, nil, fmt.Errorf("secretNamePrefix cannot be empty")
+	}
+
+	//var secretARNs []string
+	var nextToken *string
+
+	var secretObjects []*AwsSecretObject
+	var versions []string
+
+	filters := listFilters(secretNamePrefix, tagKeyFilters, tagValueFilters)
+
 	// do while we have more secrets to page through:
 	for {
-		output, err := p.awsClient.ListSecrets(context.Background(), &secretsmanager.ListSecretsInput{
+		output, err := p.awsClient.ListSecrets(ctx, &secretsmanager.ListSecretsInput{
 			Filters:    filters,
-			MaxResults: defaultMaxResults,
+			MaxResults: aws.Int32(defaultMaxResults),
 			NextToken:  nextToken,
 		})
 
 		if err != nil {
 			p.zl.Error("request to list secretes failed", zap.Error(err))
-			return nil, err
+			return nil, nil, err
 		}
 
 		for _, secret := range output.SecretList {
@@ -215,12 +358,13 @@ func (p *AWSSecretsManagerProvider) listSecrets(secretNamePrefix string, tagKeyF
 			)
 
 			if secret.ARN == nil {
-				return nil, fmt.Errorf("recieved empty ARN")
+				return nil, nil, fmt.Errorf("recieved empty ARN")
 			}
 
 			secretObjects = append(secretObjects, &AwsSecretObject{
 				ObjectName: *secret.ARN,
 			})
+			versions = append(versions, versionWithStage(secret.SecretVersionsToStages, versionStageCurrent))
 		}
 
 		if output.NextToken == nil {
@@ -230,5 +374,5 @@ func (p *AWSSecretsManagerProvider) listSecrets(secretNamePrefix string, tagKeyF
 		nextToken = output.NextToken
 	}
 
-	return secretObjects, nil
+	return secretObjects, versions, nil
 }
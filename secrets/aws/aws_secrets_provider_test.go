@@ -4,28 +4,54 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/ginkgo/extensions/table"
 	. "github.com/onsi/gomega"
 
+	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
 	"github.com/aws/aws-sdk-go-v2/service/secretsmanager/types"
+	"github.com/aws/smithy-go"
+	"github.com/hashicorp/go-multierror"
 	"go.uber.org/zap/zaptest"
 )
 
 // Ref: https://aws.github.io/aws-sdk-go-v2/docs/unit-testing/
 type MockAwsSecret struct {
-	value string
-	tags  map[string]string
-	arn   string
+	value   string
+	tags    map[string]string
+	arn     string
+	version string // the AWSCURRENT version id
 }
 
 type mockSecretmanagerClient struct {
 	secretsManagerAPI
 
 	data map[string]*MockAwsSecret
+
+	// BatchGetSecretValue returns AccessDeniedException:
+	batchNotPermitted bool
+	batchCalls        int32
+
+	describeCalls int32
+
+	// the number of times a secret id (or "ListSecrets") is throttled before succeeding:
+	throttleMu sync.Mutex
+	throttles  map[string]int
+
+	// used to verify concurrent fetching:
+	delay          time.Duration
+	respectContext bool // return early with the context error while delaying
+	calls          int32
+	inFlight       int32
+	maxInFlight    int32
 }
 
 func doesMatchFilters(secretName string, secret *MockAwsSecret, filters []types.Filter) (bool, error) {
@@ -78,7 +104,22 @@ func doesMatchFilters(secretName string, secret *MockAwsSecret, filters []types.
 	return true, nil
 }
 
+func (m *mockSecretmanagerClient) throttle(key string) error {
+	m.throttleMu.Lock()
+	defer m.throttleMu.Unlock()
+
+	if m.throttles[key] > 0 {
+		m.throttles[key]--
+		return &smithy.GenericAPIError{Code: "ThrottlingException", Message: "Rate exceeded"}
+	}
+	return nil
+}
+
 func (m *mockSecretmanagerClient) ListSecrets(ctx context.Context, params *secretsmanager.ListSecretsInput, optFns ...func(*secretsmanager.Options)) (*secretsmanager.ListSecretsOutput, error) {
+	if err := m.throttle("ListSecrets"); err != nil {
+		return nil, err
+	}
+
 	var res []types.SecretListEntry
 
 	for k, v := range m.data {
@@ -89,8 +130,9 @@ func (m *mockSecretmanagerClient) ListSecrets(ctx context.Context, params *secre
 
 		if match {
 			res = append(res, types.SecretListEntry{
-				ARN:  &v.arn,
-				Name: &k,
+				ARN:                    &v.arn,
+				Name:                   &k,
+				SecretVersionsToStages: v.versionsToStages(),
 			})
 
 		}
@@ -103,6 +145,24 @@ func (m *mockSecretmanagerClient) ListSecrets(ctx context.Context, params *secre
 }
 
 func (m *mockSecretmanagerClient) GetSecretValue(ctx context.Context, params *secretsmanager.GetSecretValueInput, optFns ...func(*secretsmanager.Options)) (*secretsmanager.GetSecretValueOutput, error) {
+	atomic.AddInt32(&m.calls, 1)
+	inFlight := atomic.AddInt32(&m.inFlight, 1)
+	defer atomic.AddInt32(&m.inFlight, -1)
+	for {
+		max := atomic.LoadInt32(&m.maxInFlight)
+		if inFlight <= max || atomic.CompareAndSwapInt32(&m.maxInFlight, max, inFlight) {
+			break
+		}
+	}
+	if m.respectContext {
+		select {
+		case <-time.After(m.delay):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	} else {
+		time.Sleep(m.delay)
+	}
 
 	k := params.SecretId
 
@@ -110,19 +170,134 @@ func (m *mockSecretmanagerClient) GetSecretValue(ctx context.Context, params *se
 		return nil, errors.New("params.SecretId cannot be nil")
 	}
 
+	if err := m.throttle(*k); err != nil {
+		return nil, err
+	}
+
 	if v, ok := m.data[*k]; ok {
 		return &secretsmanager.GetSecretValueOutput{
 			// at his point return params.SecretId as the name
 			Name:         k,
 			SecretString: &v.value,
+			VersionId:    aws.String(v.version),
 		}, nil
 	}
 
-	return nil, errors.New("secret not found")
+	// look it up by ARN:
+	for name, v := range m.data {
+		if v.arn != "" && v.arn == *k {
+			secretName := name
+			return &secretsmanager.GetSecretValueOutput{
+				Name:         &secretName,
+				SecretString: &v.value,
+				VersionId:    aws.String(v.version),
+			}, nil
+		}
+	}
+
+	return nil, &smithy.GenericAPIError{Code: "ResourceNotFoundException", Message: "secret not found"}
 
 }
 
+func (m *mockSecretmanagerClient) BatchGetSecretValue(ctx context.Context, params *secretsmanager.BatchGetSecretValueInput, optFns ...func(*secretsmanager.Options)) (*secretsmanager.BatchGetSecretValueOutput, error) {
+	atomic.AddInt32(&m.batchCalls, 1)
+	if m.batchNotPermitted {
+		return nil, &smithy.GenericAPIError{Code: "AccessDeniedException", Message: "not authorized to perform secretsmanager:BatchGetSecretValue"}
+	}
+
+	if (len(params.SecretIdList) == 0) == (len(params.Filters) == 0) {
+		return nil, errors.New("either SecretIdList or Filters must be set")
+	}
+
+	entry := func(k string, v *MockAwsSecret) types.SecretValueEntry {
+		name, value, arn := k, v.value, v.arn
+		return types.SecretValueEntry{Name: &name, SecretString: &value, ARN: &arn, VersionId: aws.String(v.version)}
+	}
+
+	out := &secretsmanager.BatchGetSecretValueOutput{}
+	if len(params.SecretIdList) > 0 {
+		if len(params.SecretIdList) > maxBatchSecrets {
+			return nil, errors.New("too many secret ids")
+		}
+
+	ids:
+		for _, id := range params.SecretIdList {
+			for k, v := range m.data {
+				if k == id || (v.arn != "" && v.arn == id) {
+					out.SecretValues = append(out.SecretValues, entry(k, v))
+					continue ids
+				}
+			}
+
+			secretId := id
+			out.Errors = append(out.Errors, types.APIErrorType{
+				SecretId:  &secretId,
+				ErrorCode: aws.String("ResourceNotFoundException"),
+				Message:   aws.String("secret not found"),
+			})
+		}
+		return out, nil
+	}
+
+	var keys []string
+	for k, v := range m.data {
+		match, err := doesMatchFilters(k, v, params.Filters)
+		if err != nil {
+			return nil, err
+		}
+		if match {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	// page through the sorted keys:
+	start := 0
+	if params.NextToken != nil {
+		start, _ = strconv.Atoi(*params.NextToken)
+	}
+	end := start + int(aws.ToInt32(params.MaxResults))
+	if end >= len(keys) {
+		end = len(keys)
+	} else {
+		out.NextToken = aws.String(strconv.Itoa(end))
+	}
+
+	for _, k := range keys[start:end] {
+		out.SecretValues = append(out.SecretValues, entry(k, m.data[k]))
+	}
+	return out, nil
+}
+
+func (s *MockAwsSecret) versionsToStages() map[string][]string {
+	if s.version == "" {
+		return nil
+	}
+	return map[string][]string{s.version: {"AWSCURRENT"}}
+}
+
+func (m *mockSecretmanagerClient) DescribeSecret(ctx context.Context, params *secretsmanager.DescribeSecretInput, optFns ...func(*secretsmanager.Options)) (*secretsmanager.DescribeSecretOutput, error) {
+	atomic.AddInt32(&m.describeCalls, 1)
+	for name, v := range m.data {
+		if name == aws.ToString(params.SecretId) || (v.arn != "" && v.arn == aws.ToString(params.SecretId)) {
+			secretName := name
+			return &secretsmanager.DescribeSecretOutput{
+				Name:               &secretName,
+				ARN:                aws.String(v.arn),
+				VersionIdsToStages: v.versionsToStages(),
+			}, nil
+		}
+	}
+
+	return nil, &smithy.GenericAPIError{Code: "ResourceNotFoundException", Message: "secret not found"}
+}
+
 func CreateProvider(t GinkgoTInterface, secretData map[string]*MockAwsSecret) *AWSSecretsManagerProvider {
+	provider, _ := createProviderWithMock(t, secretData)
+	return provider
+}
+
+func createProviderWithMock(t GinkgoTInterface, secretData map[string]*MockAwsSecret) (*AWSSecretsManagerProvider, *mockSecretmanagerClient) {
 	t.Helper()
 	zl := zaptest.NewLogger(t)
 	mockClient := &mockSecretmanagerClient{
@@ -130,7 +305,7 @@ func CreateProvider(t GinkgoTInterface, secretData map[string]*MockAwsSecret) *A
 	}
 
 	provider := newAWSSecretsManagerProviderFromClient(mockClient, "fake_region", zl)
-	return provider
+	return provider, mockClient
 }
 
 var _ = Describe("Listing secrets", func() {
@@ -156,7 +331,8 @@ var _ = Describe("Listing secrets", func() {
 			expectError bool,
 			expectedArns []string) {
 
-			sos, err := provider.listSecrets(
+			sos, _, err := provider.listSecrets(
+				context.Background(),
 				prefix,
 				keyFilters,
 				valueFilters,
@@ -208,6 +384,7 @@ var _ = Describe(`Fetch secret value test`, func() {
 			expectSecretValue string,
 			expectError bool) {
 			s, err := provider.getSecretValue(
+				context.Background(),
 				&AwsSecretObject{
 					ObjectName: secreObejectName,
 				},
@@ -230,3 +407,235 @@ var _ = Describe(`Fetch secret value test`, func() {
 		Entry("Get missing key", "missingkey", "", true),
 	)
 })
+
+var _ = Describe("Fetch secrets failure policy", func() {
+	var (
+		mockDataStore map[string]*MockAwsSecret = map[string]*MockAwsSecret{
+			"secret1": {value: "value1"},
+			"secret2": {value: "value2"},
+		}
+
+		secretObjs = []*AwsSecretObject{
+			{ObjectName: "missing1"},
+			{ObjectName: "secret1"},
+			{ObjectName: "missing2"},
+			{ObjectName: "missing-optional", Optional: true},
+			{ObjectName: "secret2"},
+		}
+	)
+
+	DescribeTable("apply the failure policy",
+		func(policy FailurePolicy, expectedFailedObjects []string, expectedNames []string) {
+			provider := CreateProvider(GinkgoT(), mockDataStore).WithFailurePolicy(policy)
+
+			res, err := provider.FetchSecrets(context.Background(), secretObjs)
+			if len(expectedFailedObjects) > 0 {
+				Expect(err).To(HaveOccurred())
+				Expect(res).To(BeNil())
+
+				merr, ok := err.(*multierror.Error)
+				Expect(ok).To(BeTrue())
+
+				var failed []string
+				for _, e := range merr.Errors {
+					var fe *ObjectFetchError
+					Expect(errors.As(e, &fe)).To(BeTrue())
+					Expect(fe.ErrorCode).To(Equal("ResourceNotFoundException"))
+					failed = append(failed, fe.ObjectName)
+				}
+				Expect(failed).To(Equal(expectedFailedObjects))
+				return
+			}
+
+			Expect(err).NotTo(HaveOccurred())
+			var names []string
+			for _, s := range res {
+				names = append(names, s.Name)
+			}
+			Expect(names).To(Equal(expectedNames))
+		},
+		Entry("fail fast stops on the first failure", FailurePolicyFailFast, []string{"missing1"}, nil),
+		Entry("fail at end reports all required failures", FailurePolicyFailAtEnd, []string{"missing1", "missing2"}, nil),
+		Entry("best effort returns the fetched secrets", FailurePolicyBestEffort, nil, []string{"secret1", "secret2"}),
+	)
+
+	It("succeeds when only optional secrets are missing", func() {
+		provider := CreateProvider(GinkgoT(), mockDataStore).WithFailurePolicy(FailurePolicyFailFast)
+
+		res, err := provider.FetchSecrets(context.Background(), []*AwsSecretObject{{ObjectName: "secret1"}, {ObjectName: "missing", Optional: true}})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(res).To(HaveLen(1))
+	})
+
+	DescribeTable("parse failure policies",
+		func(s string, expected FailurePolicy, expectError bool) {
+			p, err := ParseFailurePolicy(s)
+			if expectError {
+				Expect(err).To(HaveOccurred())
+				return
+			}
+			Expect(err).NotTo(HaveOccurred())
+			Expect(p).To(Equal(expected))
+		},
+		Entry("default", "", DefaultFailurePolicy, false),
+		Entry("fail fast", "failfast", FailurePolicyFailFast, false),
+		Entry("best effort", "besteffort", FailurePolicyBestEffort, false),
+		Entry("unsupported", "sometimes", FailurePolicy(""), true),
+	)
+})
+
+var _ = Describe("Fetch secrets concurrently", func() {
+	const secretCount = 40
+
+	var (
+		mockDataStore = map[string]*MockAwsSecret{}
+		secretObjs    []*AwsSecretObject
+	)
+
+	for i := 0; i < secretCount; i++ {
+		name := fmt.Sprintf("secret%02d", i)
+		mockDataStore[name] = &MockAwsSecret{value: "value-" + name}
+		secretObjs = append(secretObjs, &AwsSecretObject{ObjectName: name})
+	}
+
+	DescribeTable("bounded by the concurrency limit",
+		func(concurrency int) {
+			provider, mockClient := createProviderWithMock(GinkgoT(), mockDataStore)
+			mockClient.delay = 5 * time.Millisecond
+			provider.WithConcurrency(concurrency)
+
+			res, err := provider.FetchSecrets(context.Background(), secretObjs)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(res).To(HaveLen(secretCount))
+
+			// the output order matches the input order:
+			for i, s := range res {
+				Expect(s.Name).To(Equal(secretObjs[i].ObjectName))
+				Expect(s.Content).To(Equal("value-" + secretObjs[i].ObjectName))
+			}
+
+			Expect(atomic.LoadInt32(&mockClient.calls)).To(BeEquivalentTo(secretCount))
+			Expect(atomic.LoadInt32(&mockClient.maxInFlight)).To(BeNumerically("<=", concurrency))
+			if concurrency > 1 {
+				Expect(atomic.LoadInt32(&mockClient.maxInFlight)).To(BeNumerically(">", 1))
+			}
+		},
+		Entry("serial", 1),
+		Entry("4 workers", 4),
+		Entry("more workers than secrets", secretCount*2),
+	)
+
+	It("stops fetching after a failure with the fail fast policy", func() {
+		provider, mockClient := createProviderWithMock(GinkgoT(), mockDataStore)
+		mockClient.delay = 5 * time.Millisecond
+		provider.WithConcurrency(2).WithFailurePolicy(FailurePolicyFailFast)
+
+		objs := append([]*AwsSecretObject{{ObjectName: "missing"}}, secretObjs...)
+		res, err := provider.FetchSecrets(context.Background(), objs)
+		Expect(err).To(HaveOccurred())
+		Expect(res).To(BeNil())
+		Expect(atomic.LoadInt32(&mockClient.calls)).To(BeNumerically("<", len(objs)))
+		Expect(atomic.LoadInt32(&mockClient.inFlight)).To(BeZero())
+	})
+
+	It("reports every failure in order with the fail at end policy", func() {
+		provider, _ := createProviderWithMock(GinkgoT(), mockDataStore)
+		provider.WithConcurrency(8).WithFailurePolicy(FailurePolicyFailAtEnd)
+
+		objs := append([]*AwsSecretObject{}, secretObjs...)
+		objs = append(objs, &AwsSecretObject{ObjectName: "missing1"}, &AwsSecretObject{ObjectName: "missing2"})
+		_, err := provider.FetchSecrets(context.Background(), objs)
+		Expect(err).To(HaveOccurred())
+
+		merr := err.(*multierror.Error)
+		Expect(merr.Errors).To(HaveLen(2))
+		Expect(merr.Errors[0].(*ObjectFetchError).ObjectName).To(Equal("missing1"))
+		Expect(merr.Errors[1].(*ObjectFetchError).ObjectName).To(Equal("missing2"))
+	})
+})
+
+var _ = Describe("Batch fetching secrets", func() {
+	var mockDataStore = map[string]*MockAwsSecret{}
+	for i := 0; i < 45; i++ {
+		name := fmt.Sprintf("batch/secret%02d", i)
+		mockDataStore[name] = &MockAwsSecret{value: "value-" + name, arn: "arn:" + name}
+	}
+	mockDataStore["json"] = &MockAwsSecret{value: `{"user": "user-1"}`, arn: "arn:json"}
+
+	It("fetches manifest objects in batches by name or ARN", func() {
+		provider, mockClient := createProviderWithMock(GinkgoT(), mockDataStore)
+		provider.WithBatch(true)
+
+		var objs []*AwsSecretObject
+		for i := 0; i < 30; i++ {
+			name := fmt.Sprintf("batch/secret%02d", i)
+			if i%2 == 0 {
+				name = "arn:" + name
+			}
+			objs = append(objs, &AwsSecretObject{ObjectName: name})
+		}
+		objs = append(objs, &AwsSecretObject{ObjectName: "json", ObjectAlias: "whole", FileMode: "0400", JMESPath: []*JMESPathEntry{{Path: "user", ObjectAlias: "user"}}})
+
+		res, err := provider.FetchSecrets(context.Background(), objs)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(res).To(HaveLen(32))
+		for i := 0; i < 30; i++ {
+			Expect(res[i].Name).To(Equal(fmt.Sprintf("batch/secret%02d", i)))
+			Expect(res[i].Permissions).To(BeNil())
+		}
+		Expect(res[30].Alias).To(Equal("whole"))
+		Expect(res[31].Content).To(Equal("user-1"))
+
+		// the extracted fields get the object's file mode too:
+		Expect(res[30].Permissions.Mode).To(BeEquivalentTo(0400))
+		Expect(res[31].Permissions).To(Equal(res[30].Permissions))
+
+		Expect(atomic.LoadInt32(&mockClient.batchCalls)).To(BeEquivalentTo(2))
+		Expect(atomic.LoadInt32(&mockClient.calls)).To(BeZero())
+	})
+
+	It("fetches pinned versions one by one and reports batch errors", func() {
+		provider, mockClient := createProviderWithMock(GinkgoT(), mockDataStore)
+		provider.WithBatch(true)
+
+		_, err := provider.FetchSecrets(context.Background(), []*AwsSecretObject{
+			{ObjectName: "batch/secret01"},
+			{ObjectName: "batch/secret02", ObjectVersionLabel: "AWSCURRENT"},
+			{ObjectName: "missing"},
+		})
+		Expect(err).To(HaveOccurred())
+
+		merr := err.(*multierror.Error)
+		Expect(merr.Errors).To(HaveLen(1))
+		Expect(merr.Errors[0].(*ObjectFetchError).ObjectName).To(Equal("missing"))
+		Expect(merr.Errors[0].(*ObjectFetchError).ErrorCode).To(Equal("ResourceNotFoundException"))
+
+		Expect(atomic.LoadInt32(&mockClient.batchCalls)).To(BeEquivalentTo(1))
+		Expect(atomic.LoadInt32(&mockClient.calls)).To(BeEquivalentTo(1))
+	})
+
+	It("pages through filtered batches in list mode", func() {
+		provider, mockClient := createProviderWithMock(GinkgoT(), mockDataStore)
+		provider.WithBatch(true)
+
+		res, err := provider.FetchAllSecrets(context.Background(), "batch/", nil, nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(res).To(HaveLen(45))
+		Expect(atomic.LoadInt32(&mockClient.batchCalls)).To(BeEquivalentTo(3))
+		Expect(atomic.LoadInt32(&mockClient.calls)).To(BeZero())
+	})
+
+	It("falls back to one by one fetching when batching is not permitted", func() {
+		provider, mockClient := createProviderWithMock(GinkgoT(), mockDataStore)
+		mockClient.batchNotPermitted = true
+		provider.WithBatch(true)
+
+		res, err := provider.FetchAllSecrets(context.Background(), "batch/", nil, nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(res).To(HaveLen(45))
+
+		// we only try batching once:
+		Expect(atomic.LoadInt32(&mockClient.batchCalls)).To(BeEquivalentTo(1))
+		Expect(atomic.LoadInt32(&mockClient.calls)).To(BeEquivalentTo(45))
+	})
+})
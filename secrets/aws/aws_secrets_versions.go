@@ -0,0 +1,136 @@
+package aws
+
+import (
+	"context"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+	"github.com/daniel-cohen/secretsfetcher/secrets"
+	"go.uber.org/zap"
+)
+
+// versionStageCurrent - the stage secrets manager returns when no version or stage is requested
+const versionStageCurrent = "AWSCURRENT"
+
+// versionWithStage - the version id labeled with the stage, empty if none is
+func versionWithStage(versionsToStages map[string][]string, stage string) string {
+	for version, stages := range versionsToStages {
+		for _, s := range stages {
+			if s == stage {
+				return version
+			}
+		}
+	}
+	return ""
+}
+
+// currentVersions - the version id each object currently resolves to, using DescribeSecret (without fetching the values).
+// Empty for objects whose version couldn't be described, so they are fetched again.
+func (p *AWSSecretsManagerProvider) currentVersions(ctx context.Context, secretObjs []*AwsSecretObject) []string {
+	versions := make([]string, len(secretObjs))
+	sem := make(chan struct{}, p.concurrency)
+
+	var wg sync.WaitGroup
+	for i, obj := range secretObjs {
+		// pinned versions never change:
+		if obj.ObjectVersion != "" {
+			versions[i] = obj.ObjectVersion
+			continue
+		}
+
+		wg.Add(1)
+		go func(i int, obj *AwsSecretObject) {
+			defer wg.Done()
+			select {
+			case sem <- struct{}{}:
+				defer func() { <-sem }()
+			case <-ctx.Done():
+				return
+			}
+
+			output, err := p.awsClient.DescribeSecret(ctx, &secretsmanager.DescribeSecretInput{SecretId: aws.String(obj.ObjectName)})
+			if err != nil {
+				p.zl.Warn("failed to describe secret", zap.String("objectName", obj.ObjectName), zap.Error(err))
+				return
+			}
+
+			stage := obj.ObjectVersionLabel
+			if stage == "" {
+				stage = versionStageCurrent
+			}
+			versions[i] = versionWithStage(output.VersionIdsToStages, stage)
+		}(i, obj)
+	}
+	wg.Wait()
+
+	return versions
+}
+
+// FetchChangedSecrets - like FetchSecrets, but only fetches the objects whose version changed since the previous fetch.
+// previous holds the secrets of each object from the previous fetch (as returned by this function), and may be nil.
+func (p *AWSSecretsManagerProvider) FetchChangedSecrets(ctx context.Context, secretObjs []*AwsSecretObject, previous [][]*secrets.Secret) ([][]*secrets.Secret, error) {
+	return p.fetchChangedSecrets(ctx, secretObjs, p.currentVersions(ctx, secretObjs), previous)
+}
+
+// fetchChangedSecrets - fetches the objects without previous secrets, or whose version differs from the previous secrets version
+func (p *AWSSecretsManagerProvider) fetchChangedSecrets(ctx context.Context, secretObjs []*AwsSecretObject, versions []string, previous [][]*secrets.Secret) ([][]*secrets.Secret, error) {
+	res := make([][]*secrets.Secret, len(secretObjs))
+
+	var changedObjs []*AwsSecretObject
+	var changedIndexes []int
+	for i, obj := range secretObjs {
+		if i < len(previous) && len(previous[i]) > 0 && versions[i] != "" && versions[i] == previous[i][0].Version {
+			res[i] = previous[i]
+			continue
+		}
+
+		changedObjs = append(changedObjs, obj)
+		changedIndexes = append(changedIndexes, i)
+	}
+
+	p.zl.Debug("fetching changed secrets",
+		zap.Int("secretCount", len(secretObjs)),
+		zap.Int("changedCount", len(changedObjs)),
+	)
+	if len(changedObjs) == 0 {
+		return res, nil
+	}
+
+	changed, err := p.fetchSecretsByObject(ctx, changedObjs)
+	if err != nil {
+		return nil, err
+	}
+
+	for j, i := range changedIndexes {
+		res[i] = changed[j]
+	}
+	return res, nil
+}
+
+// FetchAllChangedSecrets - lists the secrets matching the filters, and only fetches the new ones or the ones whose current version changed.
+// previous holds the secrets of each listed secret (by ARN) from the previous fetch (as returned by this function), and may be nil.
+func (p *AWSSecretsManagerProvider) FetchAllChangedSecrets(ctx context.Context, secretNamePrefix string, tagKeyFilters []string, tagValueFilters []string, previous map[string][]*secrets.Secret) (map[string][]*secrets.Secret, []string, error) {
+	secretObjs, versions, err := p.listSecrets(ctx, secretNamePrefix, tagKeyFilters, tagValueFilters)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	arns := make([]string, len(secretObjs))
+	prev := make([][]*secrets.Secret, len(secretObjs))
+	for i, obj := range secretObjs {
+		arns[i] = obj.ObjectName
+		prev[i] = previous[obj.ObjectName]
+	}
+
+	byObject, err := p.fetchChangedSecrets(ctx, secretObjs, versions, prev)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	res := map[string][]*secrets.Secret{}
+	for i, arn := range arns {
+		res[arn] = byObject[i]
+	}
+	return res, arns, nil
+}
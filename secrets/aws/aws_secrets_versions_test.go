@@ -0,0 +1,82 @@
+package aws
+
+import (
+	"context"
+	"sync/atomic"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Fetching changed secrets", func() {
+	var (
+		mockDataStore map[string]*MockAwsSecret
+		provider      *AWSSecretsManagerProvider
+		mockClient    *mockSecretmanagerClient
+	)
+
+	BeforeEach(func() {
+		mockDataStore = map[string]*MockAwsSecret{
+			"app/secret1": {value: "value1", arn: "arn:app/secret1", version: "v1"},
+			"app/secret2": {value: "value2", arn: "arn:app/secret2", version: "v1"},
+		}
+		provider, mockClient = createProviderWithMock(GinkgoT(), mockDataStore)
+		provider.WithBatch(false)
+	})
+
+	It("only fetches the objects whose version changed", func() {
+		objs := []*AwsSecretObject{{ObjectName: "app/secret1"}, {ObjectName: "app/secret2"}}
+
+		res, err := provider.FetchChangedSecrets(context.Background(), objs, nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(res[0][0].Version).To(Equal("v1"))
+		Expect(atomic.LoadInt32(&mockClient.calls)).To(BeEquivalentTo(2))
+
+		// nothing changed:
+		res, err = provider.FetchChangedSecrets(context.Background(), objs, res)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(res[1][0].Content).To(Equal("value2"))
+		Expect(atomic.LoadInt32(&mockClient.calls)).To(BeEquivalentTo(2))
+
+		// rotate secret2:
+		mockDataStore["app/secret2"].value = "rotated"
+		mockDataStore["app/secret2"].version = "v2"
+		res, err = provider.FetchChangedSecrets(context.Background(), objs, res)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(res[0][0].Content).To(Equal("value1"))
+		Expect(res[1][0].Content).To(Equal("rotated"))
+		Expect(atomic.LoadInt32(&mockClient.calls)).To(BeEquivalentTo(3))
+		Expect(atomic.LoadInt32(&mockClient.describeCalls)).To(BeEquivalentTo(6))
+	})
+
+	It("does not describe objects pinned to a version", func() {
+		objs := []*AwsSecretObject{{ObjectName: "app/secret1", ObjectVersion: "v1"}}
+
+		res, err := provider.FetchChangedSecrets(context.Background(), objs, nil)
+		Expect(err).NotTo(HaveOccurred())
+		_, err = provider.FetchChangedSecrets(context.Background(), objs, res)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(atomic.LoadInt32(&mockClient.calls)).To(BeEquivalentTo(1))
+		Expect(atomic.LoadInt32(&mockClient.describeCalls)).To(BeZero())
+	})
+
+	It("fetches new and changed secrets in list mode using the listed versions", func() {
+		res, arns, err := provider.FetchAllChangedSecrets(context.Background(), "app/", nil, nil, nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(arns).To(ConsistOf("arn:app/secret1", "arn:app/secret2"))
+		Expect(atomic.LoadInt32(&mockClient.calls)).To(BeEquivalentTo(2))
+
+		mockDataStore["app/secret3"] = &MockAwsSecret{value: "value3", arn: "arn:app/secret3", version: "v1"}
+		mockDataStore["app/secret1"].value = "rotated"
+		mockDataStore["app/secret1"].version = "v2"
+
+		res, arns, err = provider.FetchAllChangedSecrets(context.Background(), "app/", nil, nil, res)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(arns).To(HaveLen(3))
+		Expect(res["arn:app/secret1"][0].Content).To(Equal("rotated"))
+		Expect(res["arn:app/secret3"][0].Content).To(Equal("value3"))
+		Expect(atomic.LoadInt32(&mockClient.calls)).To(BeEquivalentTo(4))
+		Expect(atomic.LoadInt32(&mockClient.describeCalls)).To(BeZero())
+	})
+})
@@ -0,0 +1,309 @@
+package aws
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	"github.com/aws/aws-sdk-go-v2/service/ssm/types"
+	"github.com/aws/smithy-go"
+	"github.com/daniel-cohen/secretsfetcher/logging"
+	"github.com/daniel-cohen/secretsfetcher/metrics"
+	"github.com/daniel-cohen/secretsfetcher/secrets"
+	"go.uber.org/zap"
+)
+
+const (
+	// GetParameters accepts up to 10 names per call
+	maxParametersPerRequest = 10
+	// GetParametersByPath accepts up to 10 results per page
+	defaultMaxParametersByPathResults = 10
+)
+
+type ssmAPI interface {
+	GetParameters(ctx context.Context, params *ssm.GetParametersInput, optFns ...func(*ssm.Options)) (*ssm.GetParametersOutput, error)
+	GetParametersByPath(ctx context.Context, params *ssm.GetParametersByPathInput, optFns ...func(*ssm.Options)) (*ssm.GetParametersByPathOutput, error)
+}
+
+type AWSSSMParameterProvider struct {
+	zl            *zap.Logger
+	awsClient     ssmAPI
+	region        string
+	failurePolicy FailurePolicy
+
+	// a timeout for each aws call. 0 disables it
+	requestTimeout time.Duration
+}
+
+func newAWSSSMParameterProviderFromClient(awsClient ssmAPI, region string, zl *zap.Logger) *AWSSSMParameterProvider {
+	return &AWSSSMParameterProvider{
+		awsClient:     awsClient,
+		zl:            zl.With(zap.String("secretsProvider", "aws_ssm_parameter_store")),
+		region:        region,
+		failurePolicy: DefaultFailurePolicy,
+	}
+}
+
+func NewAWSSSMParameterProvider(ctx context.Context, region string, roleArn string, zl *zap.Logger) (*AWSSSMParameterProvider, error) {
+	awsLogger := logging.NewAwsLogger(zl)
+	aswOptions := []func(*config.LoadOptions) error{config.WithLogger(awsLogger)}
+
+	// Enable aws debug logging:
+	if zl.Core().Enabled(zap.DebugLevel) {
+		aswOptions = append(aswOptions, config.WithClientLogMode(aws.LogRetries|aws.LogRequest))
+	}
+
+	if region != "" {
+		aswOptions = append(aswOptions, config.WithRegion(region))
+	}
+
+	awsCfg, err := config.LoadDefaultConfig(ctx, aswOptions...)
+	if err != nil {
+		return nil, err
+	}
+
+	svc := ssm.NewFromConfig(assumeRole(awsCfg, roleArn))
+
+	return newAWSSSMParameterProviderFromClient(svc, region, zl), nil
+}
+
+func (p *AWSSSMParameterProvider) Region() string {
+	return p.region
+}
+
+// WithRequestTimeout - sets a timeout for each aws call. 0 disables it
+func (p *AWSSSMParameterProvider) WithRequestTimeout(timeout time.Duration) *AWSSSMParameterProvider {
+	p.requestTimeout = timeout
+	return p
+}
+
+// WithFailurePolicy - sets how parameters which failed to be fetched are handled
+func (p *AWSSSMParameterProvider) WithFailurePolicy(policy FailurePolicy) *AWSSSMParameterProvider {
+	p.failurePolicy = policy
+	return p
+}
+
+// parameterName - builds the GetParameters name with an optional selector ("name:version" or "name:label")
+func parameterName(secretObj *AwsSecretObject) (string, error) {
+	if secretObj.ObjectVersion != "" && secretObj.ObjectVersionLabel != "" {
+		return "", fmt.Errorf("ssm parameter %s cannot have both objectVersion and objectVersionLabel set", secretObj.ObjectName)
+	}
+
+	if secretObj.ObjectVersion != "" {
+		return secretObj.ObjectName + ":" + secretObj.ObjectVersion, nil
+	}
+
+	if secretObj.ObjectVersionLabel != "" {
+		return secretObj.ObjectName + ":" + secretObj.ObjectVersionLabel, nil
+	}
+
+	return secretObj.ObjectName, nil
+}
+
+func (p *AWSSSMParameterProvider) getParameters(ctx context.Context, names []string) (*ssm.GetParametersOutput, error) {
+	ctx, cancel := withRequestTimeout(ctx, p.requestTimeout)
+	defer cancel()
+
+	start := time.Now()
+	result, err := p.awsClient.GetParameters(ctx, &ssm.GetParametersInput{
+		Names:          names,
+		WithDecryption: aws.Bool(true),
+	})
+	metrics.ObserveProviderCall(ObjectTypeSSMParameter, "GetParameters", time.Since(start), errorCode(err))
+	if err != nil {
+		switch ae := err.(type) {
+		case smithy.APIError:
+			p.zl.Error("failed to get parameters",
+				zap.Strings("names", names),
+				zap.String("errorCode", ae.ErrorCode()),
+				zap.String("errorFault", ae.ErrorFault().String()),
+				zap.Error(err))
+
+		default:
+			p.zl.Error("failed to get parameters", zap.Strings("names", names), zap.Error(err))
+		}
+
+		return nil, err
+	}
+
+	for _, invalid := range result.InvalidParameters {
+		p.zl.Error("invalid or missing parameter", zap.String("name", invalid))
+	}
+
+	for _, param := range result.Parameters {
+		if param.Name == nil || param.Value == nil {
+			return nil, fmt.Errorf("recieved a parameter with an empty name or value")
+		}
+
+		p.zl.Debug("successfully got parameter value",
+			zap.Stringp("name", param.Name),
+			zap.Int64("version", param.Version),
+			zap.Stringp("parameterArn", param.ARN),
+		)
+	}
+
+	return result, nil
+}
+
+// requestedObject - the manifest object a returned parameter was requested for, by its name or ARN (with the selector)
+func requestedObject(objsByName map[string]*AwsSecretObject, param types.Parameter) (*AwsSecretObject, bool) {
+	selector := aws.ToString(param.Selector)
+	for _, name := range []*string{param.Name, param.ARN} {
+		if name == nil {
+			continue
+		}
+		if obj, ok := objsByName[*name+selector]; ok {
+			return obj, true
+		}
+	}
+	return nil, false
+}
+
+func (p *AWSSSMParameterProvider) FetchParameters(ctx context.Context, secretObjs []*AwsSecretObject) ([]*secrets.Secret, error) {
+	var names []string
+	// the requested name (including the selector) to the manifest object:
+	objsByName := map[string]*AwsSecretObject{}
+	fetchErrs := newFetchErrors(p.failurePolicy, p.zl)
+	for _, secretObj := range secretObjs {
+		name, err := parameterName(secretObj)
+		if err != nil {
+			p.zl.Error("invalid parameter object", zap.Error(err))
+			if fetchErrs.add(secretObj, err) {
+				return nil, fetchErrs.ErrorOrNil()
+			}
+			continue
+		}
+		names = append(names, name)
+		objsByName[name] = secretObj
+	}
+
+	var res []*secrets.Secret
+	// Get the values in batches:
+	for start := 0; start < len(names); start += maxParametersPerRequest {
+		end := start + maxParametersPerRequest
+		if end > len(names) {
+			end = len(names)
+		}
+
+		output, err := p.getParameters(ctx, names[start:end])
+		if err != nil {
+			// the whole batch failed:
+			for _, name := range names[start:end] {
+				if fetchErrs.add(objsByName[name], err) {
+					return nil, fetchErrs.ErrorOrNil()
+				}
+			}
+			continue
+		}
+
+		for _, invalid := range output.InvalidParameters {
+			secretObj, ok := objsByName[invalid]
+			if !ok {
+				secretObj = &AwsSecretObject{ObjectName: invalid}
+			}
+
+			if fetchErrs.add(secretObj, fmt.Errorf("invalid or missing parameter %s", invalid)) {
+				return nil, fetchErrs.ErrorOrNil()
+			}
+		}
+
+		for _, param := range output.Parameters {
+			secret := &secrets.Secret{
+				Name:    *param.Name,
+				Content: *param.Value,
+				Version: strconv.FormatInt(param.Version, 10),
+			}
+
+			// parameters requested by ARN are returned with their name:
+			secretObj, ok := requestedObject(objsByName, param)
+			if !ok {
+				err := fmt.Errorf("parameter %s was returned but not requested", *param.Name)
+				p.zl.Error("unexpected parameter", zap.String("parameterName", *param.Name), zap.Error(err))
+				if fetchErrs.add(&AwsSecretObject{ObjectName: *param.Name}, err) {
+					return nil, fetchErrs.ErrorOrNil()
+				}
+				continue
+			}
+			secretObj.outputOptions(secret)
+
+			fields, err := extractJMESPathSecrets(secret, secretObj.JMESPath)
+			if err != nil {
+				p.zl.Error("failed to extract jmesPath fields", zap.String("objectName", secretObj.ObjectName), zap.Error(err))
+				if fetchErrs.add(secretObj, err) {
+					return nil, fetchErrs.ErrorOrNil()
+				}
+				continue
+			}
+
+			res = append(res, secret)
+			res = append(res, fields...)
+		}
+	}
+
+	if err := fetchErrs.ErrorOrNil(); err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+// FetchParametersByPath - fetches all the parameters under a path hierarchy. E.g: path=/my-app/
+func (p *AWSSSMParameterProvider) FetchParametersByPath(ctx context.Context, parameterPath string, recursive bool) ([]*secrets.Secret, error) {
+	if strings.TrimSpace(parameterPath) == "" {
+		return nil, fmt.Errorf("parameterPath cannot be empty")
+	}
+
+	var nextToken *string
+	var res []*secrets.Secret
+
+	// do while we have more parameters to page through:
+	for {
+		reqCtx, cancel := withRequestTimeout(ctx, p.requestTimeout)
+		start := time.Now()
+		output, err := p.awsClient.GetParametersByPath(reqCtx, &ssm.GetParametersByPathInput{
+			Path:           aws.String(parameterPath),
+			Recursive:      aws.Bool(recursive),
+			WithDecryption: aws.Bool(true),
+			MaxResults:     aws.Int32(defaultMaxParametersByPathResults),
+			NextToken:      nextToken,
+		})
+		metrics.ObserveProviderCall(ObjectTypeSSMParameter, "GetParametersByPath", time.Since(start), errorCode(err))
+		cancel()
+
+		if err != nil {
+			p.zl.Error("request to get parameters by path failed", zap.String("path", parameterPath), zap.Error(err))
+			return nil, err
+		}
+
+		for _, param := range output.Parameters {
+			if param.Name == nil || param.Value == nil {
+				return nil, fmt.Errorf("recieved a parameter with an empty name or value")
+			}
+
+			p.zl.Info("parameter listed",
+				zap.Stringp("arn", param.ARN),
+				zap.Stringp("name", param.Name),
+				zap.Int64("version", param.Version),
+			)
+
+			res = append(res, &secrets.Secret{
+				Name:    *param.Name,
+				Content: *param.Value,
+				Version: strconv.FormatInt(param.Version, 10),
+			})
+		}
+
+		if output.NextToken == nil {
+			break
+		}
+
+		nextToken = output.NextToken
+	}
+
+	return res, nil
+}
@@ -0,0 +1,240 @@
+package aws
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	"github.com/aws/aws-sdk-go-v2/service/ssm/types"
+	"go.uber.org/zap/zaptest"
+)
+
+type MockSSMParameter struct {
+	// value per version, the last one is the latest
+	versions []string
+	labels   map[string]int
+}
+
+// the ARN of a parameter name without its leading slash
+const mockParameterARNPrefix = "arn:aws:ssm:fake_region:123456789012:parameter/"
+
+type mockSSMClient struct {
+	ssmAPI
+
+	data map[string]*MockSSMParameter
+}
+
+func (m *mockSSMClient) lookup(name string) (*types.Parameter, bool) {
+	arn := strings.HasPrefix(name, mockParameterARNPrefix)
+	name = strings.TrimPrefix(name, mockParameterARNPrefix)
+
+	selector := ""
+	if i := strings.LastIndex(name, ":"); i > 0 {
+		name, selector = name[:i], name[i+1:]
+	}
+
+	p, ok := m.data[name]
+	if !ok && arn {
+		name = "/" + name
+		p, ok = m.data[name]
+	}
+	if !ok {
+		return nil, false
+	}
+
+	version := len(p.versions)
+	if selector != "" {
+		if v, ok := p.labels[selector]; ok {
+			version = v
+		} else {
+			version = 0
+			for _, c := range selector {
+				if c < '0' || c > '9' {
+					return nil, false
+				}
+				version = version*10 + int(c-'0')
+			}
+		}
+	}
+
+	if version < 1 || version > len(p.versions) {
+		return nil, false
+	}
+
+	paramName := name
+	paramARN := mockParameterARNPrefix + strings.TrimPrefix(name, "/")
+	param := &types.Parameter{
+		Name:    &paramName,
+		ARN:     &paramARN,
+		Value:   &p.versions[version-1],
+		Version: int64(version),
+	}
+	if selector != "" {
+		paramSelector := ":" + selector
+		param.Selector = &paramSelector
+	}
+	return param, true
+}
+
+func (m *mockSSMClient) GetParameters(ctx context.Context, params *ssm.GetParametersInput, optFns ...func(*ssm.Options)) (*ssm.GetParametersOutput, error) {
+	if len(params.Names) > maxParametersPerRequest {
+		return nil, errors.New("too many names")
+	}
+
+	out := &ssm.GetParametersOutput{}
+	for _, n := range params.Names {
+		if p, ok := m.lookup(n); ok {
+			out.Parameters = append(out.Parameters, *p)
+		} else {
+			out.InvalidParameters = append(out.InvalidParameters, n)
+		}
+	}
+	return out, nil
+}
+
+func (m *mockSSMClient) GetParametersByPath(ctx context.Context, params *ssm.GetParametersByPathInput, optFns ...func(*ssm.Options)) (*ssm.GetParametersByPathOutput, error) {
+	out := &ssm.GetParametersByPathOutput{}
+	prefix := strings.TrimSuffix(*params.Path, "/") + "/"
+	for k := range m.data {
+		if !strings.HasPrefix(k, prefix) {
+			continue
+		}
+		if !aws.ToBool(params.Recursive) && strings.Contains(k[len(prefix):], "/") {
+			continue
+		}
+		p, _ := m.lookup(k)
+		out.Parameters = append(out.Parameters, *p)
+	}
+
+	// No paging at this point
+	return out, nil
+}
+
+// unexpectedParameterSSMClient - also returns a parameter which wasn't requested
+type unexpectedParameterSSMClient struct {
+	mockSSMClient
+}
+
+func (m *unexpectedParameterSSMClient) GetParameters(ctx context.Context, params *ssm.GetParametersInput, optFns ...func(*ssm.Options)) (*ssm.GetParametersOutput, error) {
+	out, err := m.mockSSMClient.GetParameters(ctx, params, optFns...)
+	if err != nil {
+		return nil, err
+	}
+	p, _ := m.lookup("/app/api/key")
+	out.Parameters = append(out.Parameters, *p)
+	return out, nil
+}
+
+func CreateSSMProvider(t GinkgoTInterface, data map[string]*MockSSMParameter) *AWSSSMParameterProvider {
+	t.Helper()
+	zl := zaptest.NewLogger(t)
+	return newAWSSSMParameterProviderFromClient(&mockSSMClient{data: data}, "fake_region", zl)
+}
+
+var _ = Describe("Fetching ssm parameters", func() {
+	var (
+		provider      *AWSSSMParameterProvider
+		mockDataStore = map[string]*MockSSMParameter{
+			"plain":           {versions: []string{"v1", "v2", "v3"}, labels: map[string]int{"stable": 2}},
+			"/app/db/user":    {versions: []string{"user"}},
+			"/app/db/pass":    {versions: []string{"pass"}},
+			"/app/api/key":    {versions: []string{"key"}},
+			"/app/nested/a/b": {versions: []string{"deep"}},
+		}
+	)
+
+	BeforeEach(func() {
+		provider = CreateSSMProvider(GinkgoT(), mockDataStore)
+	})
+
+	DescribeTable("by name with selectors",
+		func(obj *AwsSecretObject, expectError bool, expectValues []string) {
+			res, err := provider.FetchParameters(context.Background(), []*AwsSecretObject{obj})
+			if expectError {
+				Expect(err).To(HaveOccurred())
+				Expect(res).To(BeNil())
+				return
+			}
+			Expect(err).NotTo(HaveOccurred())
+
+			var values []string
+			for _, s := range res {
+				Expect(s.Name).To(Equal(obj.ObjectName))
+				values = append(values, s.Content)
+			}
+			Expect(values).To(Equal(expectValues))
+		},
+		Entry("latest", &AwsSecretObject{ObjectName: "plain"}, false, []string{"v3"}),
+		Entry("by version", &AwsSecretObject{ObjectName: "plain", ObjectVersion: "1"}, false, []string{"v1"}),
+		Entry("by label", &AwsSecretObject{ObjectName: "plain", ObjectVersionLabel: "stable"}, false, []string{"v2"}),
+		Entry("missing version", &AwsSecretObject{ObjectName: "plain", ObjectVersion: "9"}, true, nil),
+		Entry("missing parameter", &AwsSecretObject{ObjectName: "missing"}, true, nil),
+		Entry("missing optional parameter", &AwsSecretObject{ObjectName: "missing", Optional: true}, false, nil),
+		Entry("both version and label", &AwsSecretObject{ObjectName: "plain", ObjectVersion: "1", ObjectVersionLabel: "stable"}, true, nil),
+	)
+
+	It("applies the object's options to parameters requested by ARN", func() {
+		uid := 1000
+		obj := &AwsSecretObject{
+			ObjectName:  mockParameterARNPrefix + "app/db/user",
+			ObjectAlias: "db-user",
+			FileMode:    "0400",
+			UID:         &uid,
+		}
+
+		res, err := provider.FetchParameters(context.Background(), []*AwsSecretObject{obj})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(res).To(HaveLen(1))
+		Expect(res[0].Name).To(Equal("/app/db/user"))
+		Expect(res[0].Alias).To(Equal("db-user"))
+		Expect(res[0].Permissions).NotTo(BeNil())
+		Expect(res[0].Permissions.Mode).To(BeEquivalentTo(0400))
+		Expect(res[0].Permissions.UID).To(Equal(1000))
+	})
+
+	It("fails on a parameter which wasn't requested", func() {
+		client := &unexpectedParameterSSMClient{mockSSMClient: mockSSMClient{data: mockDataStore}}
+		provider = newAWSSSMParameterProviderFromClient(client, "fake_region", zaptest.NewLogger(GinkgoT()))
+
+		_, err := provider.FetchParameters(context.Background(), []*AwsSecretObject{{ObjectName: "plain"}})
+		Expect(err).To(MatchError(ContainSubstring("parameter /app/api/key was returned but not requested")))
+	})
+
+	It("batches more than the per request limit", func() {
+		var objs []*AwsSecretObject
+		for i := 0; i < maxParametersPerRequest+3; i++ {
+			objs = append(objs, &AwsSecretObject{ObjectName: "/app/db/user"})
+		}
+
+		res, err := provider.FetchParameters(context.Background(), objs)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(res).To(HaveLen(maxParametersPerRequest + 3))
+	})
+
+	DescribeTable("by path",
+		func(path string, recursive bool, expectError bool, expectNames []string) {
+			res, err := provider.FetchParametersByPath(context.Background(), path, recursive)
+			if expectError {
+				Expect(err).To(HaveOccurred())
+				return
+			}
+
+			Expect(err).NotTo(HaveOccurred())
+			var names []string
+			for _, s := range res {
+				names = append(names, s.Name)
+			}
+			Expect(names).To(ConsistOf(expectNames))
+		},
+		Entry("empty path", "", false, true, nil),
+		Entry("single level", "/app/db", false, false, []string{"/app/db/user", "/app/db/pass"}),
+		Entry("non recursive skips nested", "/app", false, false, []string{}),
+		Entry("recursive", "/app/", true, false, []string{"/app/db/user", "/app/db/pass", "/app/api/key", "/app/nested/a/b"}),
+	)
+})
@@ -0,0 +1,73 @@
+package aws
+
+import (
+	"github.com/daniel-cohen/secretsfetcher/secrets"
+	"github.com/hashicorp/go-multierror"
+	"go.uber.org/zap"
+)
+
+// FailurePolicy - how to handle required objects which failed to be fetched, shared by all the providers
+type FailurePolicy = secrets.FailurePolicy
+
+const (
+	FailurePolicyFailFast   = secrets.FailurePolicyFailFast
+	FailurePolicyFailAtEnd  = secrets.FailurePolicyFailAtEnd
+	FailurePolicyBestEffort = secrets.FailurePolicyBestEffort
+
+	DefaultFailurePolicy = secrets.DefaultFailurePolicy
+)
+
+// ParseFailurePolicy - an empty string returns the default policy
+func ParseFailurePolicy(s string) (FailurePolicy, error) {
+	return secrets.ParseFailurePolicy(s)
+}
+
+// ObjectFetchError - a failure to fetch a single manifest/listed object, with the aws error code if available
+type ObjectFetchError = secrets.ObjectFetchError
+
+// fetchErrors - collects object failures according to the failure policy
+type fetchErrors struct {
+	zl     *zap.Logger
+	policy FailurePolicy
+	result *multierror.Error
+}
+
+func newFetchErrors(policy FailurePolicy, zl *zap.Logger) *fetchErrors {
+	return &fetchErrors{
+		zl:     zl,
+		policy: policy,
+	}
+}
+
+// add - records a failed object. Returns true if fetching should stop.
+func (fe *fetchErrors) add(secretObj *AwsSecretObject, err error) bool {
+	objErr := secrets.NewObjectFetchError(secretObj.ObjectName, err)
+
+	if secretObj.Optional {
+		fe.zl.Warn("skipping optional object which failed to be fetched",
+			zap.String("objectName", secretObj.ObjectName),
+			zap.String("errorCode", objErr.ErrorCode),
+			zap.Error(err))
+		return false
+	}
+
+	switch fe.policy {
+	case FailurePolicyBestEffort:
+		fe.zl.Warn("skipping object which failed to be fetched",
+			zap.String("objectName", secretObj.ObjectName),
+			zap.String("errorCode", objErr.ErrorCode),
+			zap.Error(err))
+		return false
+	case FailurePolicyFailFast:
+		fe.result = multierror.Append(fe.result, objErr)
+		return true
+	default:
+		fe.result = multierror.Append(fe.result, objErr)
+		return false
+	}
+}
+
+// ErrorOrNil - returns an error listing all the failed required objects
+func (fe *fetchErrors) ErrorOrNil() error {
+	return fe.result.ErrorOrNil()
+}
@@ -0,0 +1,72 @@
+package aws
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/daniel-cohen/secretsfetcher/secrets"
+	"github.com/jmespath/go-jmespath"
+)
+
+// JMESPathEntry - extracts a single field from a json secret value into its own secret.
+// Ref: https://github.com/aws/secrets-store-csi-driver-provider-aws#extracting-key-value-pairs-from-json-formatted-secrets
+type JMESPathEntry struct {
+	Path        string // the jmespath expression. E.g: username or db.host
+	ObjectAlias string // the name of the extracted secret
+}
+
+// extractJMESPathSecrets - returns a secret per jmesPath entry extracted from the json secret content.
+func extractJMESPathSecrets(secret *secrets.Secret, entries []*JMESPathEntry) ([]*secrets.Secret, error) {
+	if len(entries) == 0 {
+		return nil, nil
+	}
+
+	var data interface{}
+	if err := json.Unmarshal([]byte(secret.Content), &data); err != nil {
+		return nil, fmt.Errorf("secret %s has jmesPath entries but its value is not valid json: %w", secret.Name, err)
+	}
+
+	var res []*secrets.Secret
+	for _, entry := range entries {
+		if entry.Path == "" || entry.ObjectAlias == "" {
+			return nil, fmt.Errorf("secret %s has a jmesPath entry with an empty path or objectAlias", secret.Name)
+		}
+
+		expr, err := jmespath.Compile(entry.Path)
+		if err != nil {
+			return nil, fmt.Errorf("invalid jmesPath %q for secret %s: %w", entry.Path, secret.Name, err)
+		}
+
+		value, err := expr.Search(data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to evaluate jmesPath %q for secret %s: %w", entry.Path, secret.Name, err)
+		}
+
+		var content string
+		switch v := value.(type) {
+		case nil:
+			return nil, fmt.Errorf("jmesPath %q not found in secret %s", entry.Path, secret.Name)
+		case string:
+			content = v
+		default:
+			// numbers, bools, objects and arrays are written as json:
+			b, err := json.Marshal(v)
+			if err != nil {
+				return nil, fmt.Errorf("failed to marshal jmesPath %q value for secret %s: %w", entry.Path, secret.Name, err)
+			}
+			content = string(b)
+		}
+
+		res = append(res, &secrets.Secret{
+			Name:    entry.ObjectAlias,
+			Content: content,
+			Alias:   entry.ObjectAlias,
+			Version: secret.Version,
+			Object:  secret.Object,
+			// the extracted fields are written with the same permissions as the whole secret:
+			Permissions: secret.Permissions,
+		})
+	}
+
+	return res, nil
+}
@@ -0,0 +1,62 @@
+package aws
+
+import (
+	"context"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+
+	"github.com/daniel-cohen/secretsfetcher/secrets"
+)
+
+var _ = Describe("Extracting jmesPath fields", func() {
+	const jsonSecret = "{\r\n\t\"user\": \"user-1\",\r\n\t\"password\": \"password-1\",\r\n\t\"host\": \"database.example.com\",\r\n\t\"port\": 5432,\r\n\t\"db\": {\"name\": \"orders\"}\r\n}"
+
+	DescribeTable("extract fields",
+		func(content string, entries []*JMESPathEntry, expectError bool, expected map[string]string) {
+			res, err := extractJMESPathSecrets(&secrets.Secret{Name: "some/secret/name", Content: content}, entries)
+			if expectError {
+				Expect(err).To(HaveOccurred())
+				Expect(res).To(BeNil())
+				return
+			}
+
+			Expect(err).NotTo(HaveOccurred())
+			got := map[string]string{}
+			for _, s := range res {
+				got[s.Name] = s.Content
+			}
+			Expect(got).To(Equal(expected))
+		},
+		Entry("no entries", "not json", nil, false, map[string]string{}),
+		Entry("string fields", jsonSecret,
+			[]*JMESPathEntry{{Path: "user", ObjectAlias: "dbuser"}, {Path: "password", ObjectAlias: "dbpass"}},
+			false, map[string]string{"dbuser": "user-1", "dbpass": "password-1"}),
+		Entry("nested field", jsonSecret, []*JMESPathEntry{{Path: "db.name", ObjectAlias: "dbname"}}, false, map[string]string{"dbname": "orders"}),
+		Entry("number field", jsonSecret, []*JMESPathEntry{{Path: "port", ObjectAlias: "dbport"}}, false, map[string]string{"dbport": "5432"}),
+		Entry("object field", jsonSecret, []*JMESPathEntry{{Path: "db", ObjectAlias: "db"}}, false, map[string]string{"db": `{"name":"orders"}`}),
+		Entry("missing path", jsonSecret, []*JMESPathEntry{{Path: "missing", ObjectAlias: "x"}}, true, nil),
+		Entry("invalid expression", jsonSecret, []*JMESPathEntry{{Path: "[[", ObjectAlias: "x"}}, true, nil),
+		Entry("missing alias", jsonSecret, []*JMESPathEntry{{Path: "user"}}, true, nil),
+		Entry("not json", "plain text", []*JMESPathEntry{{Path: "user", ObjectAlias: "x"}}, true, nil),
+	)
+
+	It("emits the whole secret and its fields from the provider", func() {
+		provider := CreateProvider(GinkgoT(), map[string]*MockAwsSecret{
+			"some/secret/name": {value: jsonSecret},
+		})
+
+		res, err := provider.FetchSecrets(context.Background(), []*AwsSecretObject{{
+			ObjectName: "some/secret/name",
+			JMESPath:   []*JMESPathEntry{{Path: "host", ObjectAlias: "dbhost"}},
+		}})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(res).To(HaveLen(2))
+		Expect(res[0].Name).To(Equal("some/secret/name"))
+		Expect(res[1].Name).To(Equal("dbhost"))
+		Expect(res[1].Content).To(Equal("database.example.com"))
+		// the fields are reported under the object they were extracted from:
+		Expect(res[0].Object).To(Equal("some/secret/name"))
+		Expect(res[1].Object).To(Equal("some/secret/name"))
+	})
+})
@@ -0,0 +1,176 @@
+package aws
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/daniel-cohen/secretsfetcher/secrets"
+	"github.com/spf13/pflag"
+	"go.uber.org/zap"
+)
+
+const ProviderName = "aws"
+
+// registration - the aws provider of the secrets registry
+type registration struct {
+	// implements secrets.Provider
+}
+
+func init() {
+	secrets.RegisterProvider(registration{})
+}
+
+func (registration) Name() string {
+	return ProviderName
+}
+
+func (registration) Usage() secrets.ProviderUsage {
+	return secrets.ProviderUsage{
+		Short: "fetched secretes from aws secrets manager and ssm parameter store",
+	}
+}
+
+func (registration) ConfigDefaults() map[string]interface{} {
+	return map[string]interface{}{
+		"PrefixFilter":        "",
+		"TagKeyFilters":       []string{},
+		"TagValueFilters":     []string{},
+		"PathTranslation":     DefaultPathTranslation,
+		"Region":              "",
+		"RoleArn":             "",
+		"ParameterPath":       "",
+		"ParameterRecursive":  false,
+		"FailurePolicy":       "",
+		"Concurrency":         DefaultConcurrency,
+		"BatchFetch":          DefaultBatchFetch,
+		"RetryMaxAttempts":    DefaultRetryPolicy.MaxAttempts,
+		"RetryBaseBackoff":    DefaultRetryPolicy.BaseBackoff,
+		"RetryMaxBackoff":     DefaultRetryPolicy.MaxBackoff,
+		"RetryJitter":         DefaultRetryPolicy.Jitter,
+		"RetryableErrorCodes": DefaultRetryPolicy.RetryableErrorCodes,
+		"Timeout":             DefaultTimeout,
+		"RequestTimeout":      DefaultRequestTimeout,
+		"RateLimit":           0,
+		"RateLimitBurst":      1,
+		"TagFilter":           map[string]string{},
+	}
+}
+
+func (registration) AddFlags(flags *pflag.FlagSet) map[string]string {
+	flags.StringSlice("tagkeys", []string{}, "an array of tag key prefixes of filters to find secerts by. Example: --tagkeys=app,secret-type")
+	flags.StringSlice("tagvalues", []string{}, "an array of tag value prefixes of filters to find secerts by. Example: --tagvalues=my-app-name,b44c6886-96c4-4b4d-b267-30d7c5787b1a")
+
+	flags.String("prefix", "", "a prefix for all secrets to fetch")
+
+	flags.String("parameterpath", "", "an ssm parameter store path to fetch all parameters from. Example: --parameterpath=/my-app/")
+	flags.Bool("recursive", false, "fetch all parameters nested under the parameter path")
+
+	flags.String("rolearn", "", "a role assumed for all the aws calls. E.g: a role in another account")
+
+	flags.Int("concurrency", DefaultConcurrency, "the maximum number of secrets fetched in parallel")
+	flags.Bool("batch", DefaultBatchFetch, "fetch secrets using BatchGetSecretValue (falls back to GetSecretValue if not permitted)")
+	flags.Int("maxattempts", DefaultRetryPolicy.MaxAttempts, "the maximum attempts (including the first one) for throttled or failed secrets manager calls")
+	flags.Float64("ratelimit", 0, "a client side limit of secrets manager requests per second. 0 disables the limit")
+	flags.Duration("timeout", DefaultTimeout, "the overall timeout for fetching and writing the secrets. 0 disables the timeout")
+	flags.Duration("requesttimeout", DefaultRequestTimeout, "the timeout for each aws request attempt. 0 disables the timeout")
+
+	return map[string]string{
+		"tagkeys":        "TagKeyFilters",
+		"tagvalues":      "TagValueFilters",
+		"prefix":         "PrefixFilter",
+		"parameterpath":  "ParameterPath",
+		"recursive":      "ParameterRecursive",
+		"rolearn":        "RoleArn",
+		"concurrency":    "Concurrency",
+		"batch":          "BatchFetch",
+		"maxattempts":    "RetryMaxAttempts",
+		"ratelimit":      "RateLimit",
+		"timeout":        "Timeout",
+		"requesttimeout": "RequestTimeout",
+	}
+}
+
+// NewFetch - the fetcher for the manifest, or the prefix/tag filters or parameter path mode
+func (registration) NewFetch(ctx context.Context, config secrets.Decoder, manifest secrets.Decoder, zl *zap.Logger) (*secrets.ProviderFetch, error) {
+	cfg := &AWSConfig{}
+	if err := config(cfg); err != nil {
+		return nil, fmt.Errorf("invalid aws config: %w", err)
+	}
+
+	region := cfg.Region
+	roleArn := cfg.RoleArn
+	pathTranslation := cfg.PathTranslation
+
+	var manifestCfg *SecretManifest
+	if manifest != nil {
+		manifestCfg = &SecretManifest{}
+		if err := manifest(manifestCfg); err != nil {
+			return nil, fmt.Errorf("unable to decode the manifest: %w", err)
+		}
+		zl.Info("Loaded manifest config", zap.Any("manifestCfg", manifestCfg))
+
+		// the manifest will take precedence over the region and role in the main config
+		if manifestCfg.Region != "" {
+			region = manifestCfg.Region
+		}
+		if manifestCfg.RoleArn != "" {
+			roleArn = manifestCfg.RoleArn
+		}
+		if manifestCfg.PathTranslation != "" {
+			pathTranslation = manifestCfg.PathTranslation
+		}
+	} else {
+		if cfg.PrefixFilter == "" && cfg.ParameterPath == "" {
+			return nil, fmt.Errorf("no manifest and neither aws prefix filter nor parameter path set")
+		}
+		if cfg.PrefixFilter != "" && cfg.ParameterPath != "" {
+			return nil, fmt.Errorf("aws prefix filter and parameter path cannot be set together")
+		}
+	}
+
+	failurePolicy, err := ParseFailurePolicy(cfg.FailurePolicy)
+	if err != nil {
+		return nil, fmt.Errorf("invalid failure policy: %w", err)
+	}
+
+	provider, err := NewAWSSecretsManagerProvider(ctx, region, roleArn, zl)
+	if err != nil {
+		return nil, fmt.Errorf("failed to setup aws secrets provider: %w", err)
+	}
+	provider.WithFailurePolicy(failurePolicy).
+		WithConcurrency(cfg.Concurrency).
+		WithBatch(cfg.BatchFetch).
+		WithRetryPolicy(cfg.RetryPolicy()).
+		WithRateLimit(cfg.RateLimit, cfg.RateLimitBurst).
+		WithRequestTimeout(cfg.RequestTimeout)
+
+	ssmProvider, err := NewAWSSSMParameterProvider(ctx, region, roleArn, zl)
+	if err != nil {
+		return nil, fmt.Errorf("failed to setup aws ssm parameter provider: %w", err)
+	}
+	ssmProvider.WithFailurePolicy(failurePolicy).WithRequestTimeout(cfg.RequestTimeout)
+
+	fetch := &secrets.ProviderFetch{
+		PathTranslation: secrets.PathTranslationChar(pathTranslation),
+		Timeout:         cfg.Timeout,
+		Done: func() {
+			if retryCounts := provider.RetryCounts(); len(retryCounts) > 0 {
+				zl.Info("retried aws calls", zap.Any("retryCounts", retryCounts))
+			}
+		},
+	}
+
+	switch {
+	case manifestCfg != nil:
+		fetch.Fetcher = NewManifestSecretFetcher(provider, ssmProvider, manifestCfg, zl)
+		fetch.Templates = manifestCfg.Templates
+		fetch.Required = manifestCfg.RequiredSecrets()
+		fetch.Outputs = manifestCfg.Outputs(fetch.PathTranslation)
+	case cfg.ParameterPath != "":
+		fetch.Fetcher = NewParameterPathSecretFetcher(ssmProvider, cfg.ParameterPath, cfg.ParameterRecursive, zl)
+	default:
+		fetch.Fetcher = NewListSecretFetcher(provider, cfg.PrefixFilter, cfg.TagKeyFilters, cfg.TagValueFilters, zl)
+	}
+
+	return fetch, nil
+}
@@ -0,0 +1,217 @@
+package aws
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/retry"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+	"github.com/aws/smithy-go"
+	"github.com/daniel-cohen/secretsfetcher/metrics"
+	"go.uber.org/zap"
+	"golang.org/x/time/rate"
+)
+
+// RetryPolicy - how failed aws calls are retried
+type RetryPolicy struct {
+	MaxAttempts int           // including the first attempt. 1 disables retries
+	BaseBackoff time.Duration // the backoff before the first retry, doubled on every attempt
+	MaxBackoff  time.Duration
+	Jitter      float64 // the fraction (0-1) of the backoff which is randomized
+
+	RetryableErrorCodes []string // aws error codes which are retried. Connection errors are always retried
+}
+
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts: 5,
+	BaseBackoff: 200 * time.Millisecond,
+	MaxBackoff:  10 * time.Second,
+	Jitter:      0.5,
+	RetryableErrorCodes: []string{
+		"ThrottlingException",
+		"TooManyRequestsException",
+		"RequestLimitExceeded",
+		"InternalServiceError",
+		"InternalFailure",
+		"ServiceUnavailable",
+	},
+}
+
+func (rp *RetryPolicy) isRetryable(err error) bool {
+	var ae smithy.APIError
+	if errors.As(err, &ae) {
+		for _, code := range rp.RetryableErrorCodes {
+			if ae.ErrorCode() == code {
+				return true
+			}
+		}
+		return false
+	}
+
+	return retry.RetryableConnectionError{}.IsErrorRetryable(err) == aws.TrueTernary
+}
+
+// backoff - the delay before the given retry (starting from 1)
+func (rp *RetryPolicy) backoff(retryNum int) time.Duration {
+	backoff := rp.BaseBackoff
+	for i := 1; i < retryNum && (rp.MaxBackoff == 0 || backoff < rp.MaxBackoff); i++ {
+		backoff *= 2
+	}
+
+	if rp.MaxBackoff > 0 && backoff > rp.MaxBackoff {
+		backoff = rp.MaxBackoff
+	}
+
+	if rp.Jitter > 0 {
+		backoff -= time.Duration(rand.Float64() * rp.Jitter * float64(backoff))
+	}
+
+	return backoff
+}
+
+// retryingSecretsManagerClient - wraps the secrets manager client with retries and a client side rate limit
+type retryingSecretsManagerClient struct {
+	// implements secretsManagerAPI
+	zl      *zap.Logger
+	client  secretsManagerAPI
+	policy  RetryPolicy
+	limiter *rate.Limiter
+
+	// a timeout for each attempt. 0 disables it
+	requestTimeout time.Duration
+
+	mu          sync.Mutex
+	retryCounts map[string]int
+}
+
+func newRetryingSecretsManagerClient(client secretsManagerAPI, zl *zap.Logger) *retryingSecretsManagerClient {
+	return &retryingSecretsManagerClient{
+		zl:          zl,
+		client:      client,
+		policy:      DefaultRetryPolicy,
+		limiter:     rate.NewLimiter(rate.Inf, 0),
+		retryCounts: map[string]int{},
+	}
+}
+
+// do - calls fn with retries. key identifies the call for logging and retry counts (e.g: the secret id)
+func (c *retryingSecretsManagerClient) do(ctx context.Context, operation string, key string, fn func(ctx context.Context) error) error {
+	for attempt := 1; ; attempt++ {
+		if err := c.limiter.Wait(ctx); err != nil {
+			return err
+		}
+
+		attemptCtx, cancel := withRequestTimeout(ctx, c.requestTimeout)
+		start := time.Now()
+		err := fn(attemptCtx)
+		metrics.ObserveProviderCall(ObjectTypeSecretsManager, operation, time.Since(start), errorCode(err))
+		cancel()
+
+		if err == nil || attempt >= c.policy.MaxAttempts || ctx.Err() != nil {
+			return err
+		}
+
+		// an attempt which timed out (while we still have time) is retried as well:
+		if !c.policy.isRetryable(err) && !errors.Is(err, context.DeadlineExceeded) {
+			return err
+		}
+
+		backoff := c.policy.backoff(attempt)
+		c.zl.Warn("retrying aws call",
+			zap.String("operation", operation),
+			zap.String("key", key),
+			zap.Int("attempt", attempt),
+			zap.Duration("backoff", backoff),
+			zap.Error(err))
+
+		c.mu.Lock()
+		c.retryCounts[key]++
+		c.mu.Unlock()
+		metrics.AddProviderRetry(ObjectTypeSecretsManager, operation)
+
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// errorCode - the aws error code of a failed call for the metrics. Empty if the call succeeded
+func errorCode(err error) string {
+	if err == nil {
+		return ""
+	}
+
+	var ae smithy.APIError
+	switch {
+	case errors.As(err, &ae):
+		return ae.ErrorCode()
+	case errors.Is(err, context.DeadlineExceeded):
+		return metrics.ErrorCodeTimeout
+	case errors.Is(err, context.Canceled):
+		return metrics.ErrorCodeCanceled
+	default:
+		return metrics.ErrorCodeUnknown
+	}
+}
+
+// withRequestTimeout - a context for a single aws call. A zero timeout only inherits the parent deadline
+func withRequestTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+// RetryCounts - the number of retries per key
+func (c *retryingSecretsManagerClient) RetryCounts() map[string]int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	res := make(map[string]int, len(c.retryCounts))
+	for k, v := range c.retryCounts {
+		res[k] = v
+	}
+	return res
+}
+
+func (c *retryingSecretsManagerClient) ListSecrets(ctx context.Context, params *secretsmanager.ListSecretsInput, optFns ...func(*secretsmanager.Options)) (*secretsmanager.ListSecretsOutput, error) {
+	var output *secretsmanager.ListSecretsOutput
+	err := c.do(ctx, "ListSecrets", "ListSecrets", func(ctx context.Context) (err error) {
+		output, err = c.client.ListSecrets(ctx, params, optFns...)
+		return err
+	})
+	return output, err
+}
+
+func (c *retryingSecretsManagerClient) GetSecretValue(ctx context.Context, params *secretsmanager.GetSecretValueInput, optFns ...func(*secretsmanager.Options)) (*secretsmanager.GetSecretValueOutput, error) {
+	var output *secretsmanager.GetSecretValueOutput
+	err := c.do(ctx, "GetSecretValue", aws.ToString(params.SecretId), func(ctx context.Context) (err error) {
+		output, err = c.client.GetSecretValue(ctx, params, optFns...)
+		return err
+	})
+	return output, err
+}
+
+func (c *retryingSecretsManagerClient) BatchGetSecretValue(ctx context.Context, params *secretsmanager.BatchGetSecretValueInput, optFns ...func(*secretsmanager.Options)) (*secretsmanager.BatchGetSecretValueOutput, error) {
+	var output *secretsmanager.BatchGetSecretValueOutput
+	err := c.do(ctx, "BatchGetSecretValue", "BatchGetSecretValue", func(ctx context.Context) (err error) {
+		output, err = c.client.BatchGetSecretValue(ctx, params, optFns...)
+		return err
+	})
+	return output, err
+}
+
+func (c *retryingSecretsManagerClient) DescribeSecret(ctx context.Context, params *secretsmanager.DescribeSecretInput, optFns ...func(*secretsmanager.Options)) (*secretsmanager.DescribeSecretOutput, error) {
+	var output *secretsmanager.DescribeSecretOutput
+	err := c.do(ctx, "DescribeSecret", aws.ToString(params.SecretId), func(ctx context.Context) (err error) {
+		output, err = c.client.DescribeSecret(ctx, params, optFns...)
+		return err
+	})
+	return output, err
+}
@@ -0,0 +1,180 @@
+package aws
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+
+	"github.com/aws/smithy-go"
+	"github.com/hashicorp/go-multierror"
+)
+
+var _ = Describe("Retrying aws calls", func() {
+	var (
+		mockDataStore = map[string]*MockAwsSecret{
+			"secret1": {value: "value1", arn: "arn1"},
+			"secret2": {value: "value2", arn: "arn2"},
+		}
+
+		fastPolicy = RetryPolicy{
+			MaxAttempts:         3,
+			BaseBackoff:         time.Millisecond,
+			MaxBackoff:          5 * time.Millisecond,
+			Jitter:              0.5,
+			RetryableErrorCodes: DefaultRetryPolicy.RetryableErrorCodes,
+		}
+	)
+
+	It("retries throttled calls and counts the retries per secret", func() {
+		provider, mockClient := createProviderWithMock(GinkgoT(), mockDataStore)
+		mockClient.throttles = map[string]int{"ListSecrets": 1, "arn1": 2}
+		provider.WithRetryPolicy(fastPolicy)
+
+		res, err := provider.FetchAllSecrets(context.Background(), "secret", nil, nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(res).To(HaveLen(2))
+		Expect(provider.RetryCounts()).To(Equal(map[string]int{"ListSecrets": 1, "arn1": 2}))
+	})
+
+	It("gives up after the maximum attempts", func() {
+		provider, mockClient := createProviderWithMock(GinkgoT(), mockDataStore)
+		mockClient.throttles = map[string]int{"secret1": 3}
+		provider.WithRetryPolicy(fastPolicy)
+
+		_, err := provider.FetchSecrets(context.Background(), []*AwsSecretObject{{ObjectName: "secret1"}})
+		Expect(err).To(HaveOccurred())
+
+		var fe *ObjectFetchError
+		Expect(errors.As(err.(*multierror.Error).Errors[0], &fe)).To(BeTrue())
+		Expect(fe.ErrorCode).To(Equal("ThrottlingException"))
+		Expect(provider.RetryCounts()).To(Equal(map[string]int{"secret1": 2}))
+	})
+
+	It("does not retry error codes which are not retryable", func() {
+		provider, mockClient := createProviderWithMock(GinkgoT(), mockDataStore)
+		mockClient.throttles = map[string]int{"secret1": 1}
+		policy := fastPolicy
+		policy.RetryableErrorCodes = []string{"InternalServiceError"}
+		provider.WithRetryPolicy(policy)
+
+		_, err := provider.FetchSecrets(context.Background(), []*AwsSecretObject{{ObjectName: "secret1"}})
+		Expect(err).To(HaveOccurred())
+		Expect(provider.RetryCounts()).To(BeEmpty())
+	})
+
+	It("rate limits the calls", func() {
+		provider, mockClient := createProviderWithMock(GinkgoT(), mockDataStore)
+		provider.WithRateLimit(50, 1).WithConcurrency(4)
+
+		var objs []*AwsSecretObject
+		for i := 0; i < 6; i++ {
+			objs = append(objs, &AwsSecretObject{ObjectName: "secret1"})
+		}
+
+		start := time.Now()
+		_, err := provider.FetchSecrets(context.Background(), objs)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(mockClient.calls).To(BeEquivalentTo(6))
+		// the first call uses the burst, the other 5 are 20ms apart:
+		Expect(time.Since(start)).To(BeNumerically(">=", 90*time.Millisecond))
+	})
+
+	DescribeTable("exponential backoff",
+		func(retryNum int, maxBackoff time.Duration, expected time.Duration) {
+			policy := RetryPolicy{BaseBackoff: 100 * time.Millisecond, MaxBackoff: maxBackoff}
+			Expect(policy.backoff(retryNum)).To(Equal(expected))
+		},
+		Entry("first retry", 1, time.Second, 100*time.Millisecond),
+		Entry("second retry", 2, time.Second, 200*time.Millisecond),
+		Entry("fourth retry", 4, time.Second, 800*time.Millisecond),
+		Entry("capped", 10, time.Second, time.Second),
+		Entry("uncapped", 10, time.Duration(0), 51200*time.Millisecond),
+	)
+
+	DescribeTable("error codes for the metrics",
+		func(err error, expected string) {
+			Expect(errorCode(err)).To(Equal(expected))
+		},
+		Entry("success", nil, ""),
+		Entry("aws error", &smithy.GenericAPIError{Code: "ThrottlingException"}, "ThrottlingException"),
+		Entry("wrapped aws error", fmt.Errorf("failed: %w", &smithy.GenericAPIError{Code: "AccessDeniedException"}), "AccessDeniedException"),
+		Entry("timeout", context.DeadlineExceeded, "Timeout"),
+		Entry("other", errors.New("connection reset"), "Unknown"),
+	)
+
+	It("applies jitter within the backoff", func() {
+		policy := RetryPolicy{BaseBackoff: 100 * time.Millisecond, MaxBackoff: time.Second, Jitter: 0.5}
+		for i := 0; i < 20; i++ {
+			Expect(policy.backoff(1)).To(And(
+				BeNumerically(">=", 50*time.Millisecond),
+				BeNumerically("<=", 100*time.Millisecond),
+			))
+		}
+	})
+
+	DescribeTable("clamps the configured jitter",
+		func(jitter float64, expected float64) {
+			cfg := &AWSConfig{RetryJitter: jitter}
+			Expect(cfg.RetryPolicy().Jitter).To(Equal(expected))
+		},
+		Entry("negative", -0.5, 0.0),
+		Entry("in range", 0.3, 0.3),
+		Entry("above 1", 2.0, 1.0),
+	)
+})
+
+var _ = Describe("Cancelling aws calls", func() {
+	var mockDataStore = map[string]*MockAwsSecret{
+		"secret1": {value: "value1"},
+		"secret2": {value: "value2"},
+		"secret3": {value: "value3"},
+	}
+
+	It("retries attempts which exceeded the request timeout", func() {
+		provider, mockClient := createProviderWithMock(GinkgoT(), mockDataStore)
+		mockClient.delay = 50 * time.Millisecond
+		mockClient.respectContext = true
+		provider.WithRequestTimeout(10 * time.Millisecond).WithRetryPolicy(RetryPolicy{MaxAttempts: 2, BaseBackoff: time.Millisecond})
+
+		_, err := provider.FetchSecrets(context.Background(), []*AwsSecretObject{{ObjectName: "secret1"}})
+		Expect(err).To(HaveOccurred())
+		Expect(errors.Is(err, context.DeadlineExceeded)).To(BeTrue())
+		Expect(provider.RetryCounts()).To(Equal(map[string]int{"secret1": 1}))
+	})
+
+	It("stops fetching once the context is cancelled", func() {
+		provider, mockClient := createProviderWithMock(GinkgoT(), mockDataStore)
+		mockClient.delay = time.Second
+		mockClient.respectContext = true
+		provider.WithConcurrency(1)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+
+		start := time.Now()
+		res, err := provider.FetchSecrets(ctx, []*AwsSecretObject{{ObjectName: "secret1"}, {ObjectName: "secret2"}, {ObjectName: "secret3"}})
+		Expect(err).To(HaveOccurred())
+		Expect(res).To(BeNil())
+		Expect(errors.Is(err, context.DeadlineExceeded)).To(BeTrue())
+		Expect(time.Since(start)).To(BeNumerically("<", 500*time.Millisecond))
+	})
+
+	It("fails instead of skipping the unfetched objects with besteffort", func() {
+		provider, mockClient := createProviderWithMock(GinkgoT(), mockDataStore)
+		mockClient.delay = time.Second
+		mockClient.respectContext = true
+		provider.WithConcurrency(1).WithFailurePolicy(FailurePolicyBestEffort)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+
+		res, err := provider.FetchSecrets(ctx, []*AwsSecretObject{{ObjectName: "secret1"}, {ObjectName: "secret2"}, {ObjectName: "secret3"}})
+		Expect(errors.Is(err, context.DeadlineExceeded)).To(BeTrue())
+		Expect(res).To(BeNil())
+	})
+})
@@ -1,8 +1,15 @@
 package aws
 
+import (
+	"fmt"
+	"strings"
+
+	"github.com/daniel-cohen/secretsfetcher/secrets"
+)
+
 const (
-	DefaultPathTranslation = "_"
-	PathTranslationFalse   = "False"
+	DefaultPathTranslation = secrets.DefaultPathTranslation
+	PathTranslationFalse   = secrets.PathTranslationFalse
 )
 
 // Our manifest strucutre:
@@ -11,9 +18,87 @@ type SecretManifest struct {
 	SecretObjects []*AwsSecretObject
 	Region        string
 
+	// an optional role assumed to fetch the secrets (E.g: a role in another account). Overrides the config's
+	RoleArn string
+
 	//An optional field to specify a substitution character to use when the path separator character (slash on Linux) is used in the file name.
 	// If a Secret or parameter name contains the path separator failures will occur when the provider tries to create a mounted file using the name.
 	// When not specified the underscore character is used, thus My/Path/Secret will be mounted as My_Path_Secret. This pathTranslation value can either be the string "False" or a single character string. When set to "False", no character substitution is performed.
 	//TOOD: validate that this is a single charactr or "False"
 	PathTranslation string //An optional field to specify a substitution character to use when the path separator character (slash on Linux) is used in the file name.
+
+	// templates rendered with the secrets by the template output format
+	Templates []*secrets.TemplateSpec
+}
+
+// Validate - checks the manifest for conflicting output names, invalid file modes and incomplete templates before anything is fetched or written
+func (m *SecretManifest) Validate() error {
+	if err := secrets.ValidateTemplates(m.Templates); err != nil {
+		return err
+	}
+
+	aliases := map[string]string{}
+	addAlias := func(alias string, objectName string) error {
+		if alias == "" {
+			return nil
+		}
+		if other, ok := aliases[alias]; ok {
+			return fmt.Errorf("duplicate objectAlias %q used by objects %s and %s", alias, other, objectName)
+		}
+		aliases[alias] = objectName
+		return nil
+	}
+
+	for _, obj := range m.SecretObjects {
+		if _, err := obj.FilePermissions(); err != nil {
+			return err
+		}
+
+		if err := addAlias(obj.ObjectAlias, obj.ObjectName); err != nil {
+			return err
+		}
+
+		for _, entry := range obj.JMESPath {
+			if err := addAlias(entry.ObjectAlias, obj.ObjectName); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// Outputs - the output names of the objects' secrets and their jmesPath fields, with the slashes of unaliased names converted like the writers do.
+// Unaliased objects requested by arn are left out, as they're written by the name they're fetched with
+func (m *SecretManifest) Outputs(slashConversionChar string) []secrets.ObjectOutput {
+	var outputs []secrets.ObjectOutput
+	for _, obj := range m.SecretObjects {
+		if obj.ObjectAlias != "" || !strings.HasPrefix(obj.ObjectName, "arn:") {
+			outputs = append(outputs, secrets.ObjectOutput{
+				ObjectName: obj.ObjectName,
+				OutputName: secrets.OutputName(&secrets.Secret{Name: obj.ObjectName, Alias: obj.ObjectAlias}, slashConversionChar),
+			})
+		}
+
+		for _, entry := range obj.JMESPath {
+			outputs = append(outputs, secrets.ObjectOutput{ObjectName: obj.ObjectName, OutputName: entry.ObjectAlias})
+		}
+	}
+	return outputs
+}
+
+// RequiredSecrets - the object names of the non optional objects, and the aliases of their jmesPath fields
+func (m *SecretManifest) RequiredSecrets() []string {
+	var required []string
+	for _, obj := range m.SecretObjects {
+		if obj.Optional {
+			continue
+		}
+
+		required = append(required, obj.ObjectName)
+		for _, entry := range obj.JMESPath {
+			required = append(required, entry.ObjectAlias)
+		}
+	}
+	return required
 }
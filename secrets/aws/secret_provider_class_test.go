@@ -0,0 +1,76 @@
+package aws
+
+import (
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+
+	"github.com/daniel-cohen/secretsfetcher/secrets"
+)
+
+var _ = DescribeTable("Validating a manifest",
+	func(objs []*AwsSecretObject, expectError bool) {
+		err := (&SecretManifest{SecretObjects: objs}).Validate()
+		if expectError {
+			Expect(err).To(HaveOccurred())
+		} else {
+			Expect(err).NotTo(HaveOccurred())
+		}
+	},
+	Entry("no aliases", []*AwsSecretObject{{ObjectName: "a"}, {ObjectName: "b"}}, false),
+	Entry("unique aliases", []*AwsSecretObject{{ObjectName: "a", ObjectAlias: "x"}, {ObjectName: "b", ObjectAlias: "y"}}, false),
+	Entry("duplicate object aliases", []*AwsSecretObject{{ObjectName: "a", ObjectAlias: "x"}, {ObjectName: "b", ObjectAlias: "x"}}, true),
+	Entry("object alias clashing with a jmesPath alias", []*AwsSecretObject{
+		{ObjectName: "a", ObjectAlias: "x"},
+		{ObjectName: "b", JMESPath: []*JMESPathEntry{{Path: "user", ObjectAlias: "x"}}},
+	}, true),
+	Entry("valid file mode", []*AwsSecretObject{{ObjectName: "a", FileMode: "0400"}}, false),
+	Entry("invalid file mode", []*AwsSecretObject{{ObjectName: "a", FileMode: "0999"}}, true),
+)
+
+var _ = DescribeTable("Validating manifest templates",
+	func(templates []*secrets.TemplateSpec, expectError bool) {
+		err := (&SecretManifest{Templates: templates}).Validate()
+		if expectError {
+			Expect(err).To(HaveOccurred())
+		} else {
+			Expect(err).NotTo(HaveOccurred())
+		}
+	},
+	Entry("complete", []*secrets.TemplateSpec{{Source: "nginx.conf.tmpl", Destination: "nginx.conf", FileMode: "0640"}}, false),
+	Entry("missing destination", []*secrets.TemplateSpec{{Source: "nginx.conf.tmpl"}}, true),
+	Entry("invalid file mode", []*secrets.TemplateSpec{{Source: "nginx.conf.tmpl", Destination: "nginx.conf", FileMode: "rw"}}, true),
+)
+
+var _ = DescribeTable("The output names of a manifest",
+	func(objs []*AwsSecretObject, expected []secrets.ObjectOutput) {
+		Expect((&SecretManifest{SecretObjects: objs}).Outputs("_")).To(Equal(expected))
+	},
+	Entry("names and aliases", []*AwsSecretObject{{ObjectName: "prod/db"}, {ObjectName: "b", ObjectAlias: "x/y"}}, []secrets.ObjectOutput{
+		{ObjectName: "prod/db", OutputName: "prod_db"},
+		{ObjectName: "b", OutputName: "x/y"},
+	}),
+	Entry("jmesPath fields", []*AwsSecretObject{
+		{ObjectName: "a", JMESPath: []*JMESPathEntry{{Path: "user", ObjectAlias: "user"}}},
+	}, []secrets.ObjectOutput{{ObjectName: "a", OutputName: "a"}, {ObjectName: "a", OutputName: "user"}}),
+	Entry("unaliased arns", []*AwsSecretObject{
+		{ObjectName: "arn:aws:secretsmanager:us-west-2:111122223333:secret:my/secret-a1b2c3"},
+		{ObjectName: "arn:aws:secretsmanager:us-west-2:111122223333:secret:other-a1b2c3", ObjectAlias: "other"},
+	}, []secrets.ObjectOutput{{ObjectName: "arn:aws:secretsmanager:us-west-2:111122223333:secret:other-a1b2c3", OutputName: "other"}}),
+)
+
+var _ = DescribeTable("The required secrets of a manifest",
+	func(objs []*AwsSecretObject, expected []string) {
+		Expect((&SecretManifest{SecretObjects: objs}).RequiredSecrets()).To(Equal(expected))
+	},
+	Entry("names", []*AwsSecretObject{{ObjectName: "a"}, {ObjectName: "b", ObjectAlias: "x"}}, []string{"a", "b"}),
+	Entry("optional objects", []*AwsSecretObject{{ObjectName: "a"}, {ObjectName: "b", Optional: true}}, []string{"a"}),
+	Entry("jmesPath fields", []*AwsSecretObject{
+		{ObjectName: "a", JMESPath: []*JMESPathEntry{{Path: "user", ObjectAlias: "user"}}},
+	}, []string{"a", "user"}),
+	Entry("secrets manager arns", []*AwsSecretObject{
+		{ObjectName: "arn:aws:secretsmanager:us-west-2:111122223333:secret:my/secret-a1b2c3"},
+	}, []string{"arn:aws:secretsmanager:us-west-2:111122223333:secret:my/secret-a1b2c3"}),
+	Entry("ssm parameter arns", []*AwsSecretObject{
+		{ObjectName: "arn:aws:ssm:us-west-2:111122223333:parameter/my/param", ObjectType: ObjectTypeSSMParameter},
+	}, []string{"arn:aws:ssm:us-west-2:111122223333:parameter/my/param"}),
+)
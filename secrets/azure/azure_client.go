@@ -0,0 +1,270 @@
+package azure
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/daniel-cohen/secretsfetcher/metrics"
+	"go.uber.org/zap"
+)
+
+const (
+	providerName = "azurekeyvault"
+
+	// the key vault resource tokens are requested for
+	keyVaultResource = "https://vault.azure.net"
+
+	imdsTokenPath       = "/metadata/identity/oauth2/token"
+	imdsTokenAPIVersion = "2018-02-01"
+
+	// tokens are requested again once less than this is left of them:
+	tokenRenewMargin = 5 * time.Minute
+)
+
+// AzureError - a failed key vault request
+type AzureError struct {
+	StatusCode int
+	Code       string // E.g: SecretNotFound
+	Message    string
+}
+
+func (e *AzureError) Error() string {
+	return fmt.Sprintf("key vault request failed: %d %s: %s", e.StatusCode, e.Code, e.Message)
+}
+
+// IsNotFound - true if the secret (or version) doesn't exist
+func IsNotFound(err error) bool {
+	var ae *AzureError
+	return errors.As(err, &ae) && ae.StatusCode == http.StatusNotFound
+}
+
+// errorCode - the error code of a failed request for the metrics. Empty if the request succeeded
+func errorCode(err error) string {
+	var ae *AzureError
+	switch {
+	case err == nil:
+		return ""
+	case errors.As(err, &ae):
+		return ae.Code
+	case errors.Is(err, context.DeadlineExceeded):
+		return metrics.ErrorCodeTimeout
+	case errors.Is(err, context.Canceled):
+		return metrics.ErrorCodeCanceled
+	default:
+		return metrics.ErrorCodeUnknown
+	}
+}
+
+// tokenResponse - an azure ad or managed identity token. expires_in is a string in managed identity responses
+type tokenResponse struct {
+	AccessToken string      `json:"access_token"`
+	ExpiresIn   json.Number `json:"expires_in"` // seconds
+}
+
+// azureClient - a minimal key vault rest api client, authenticated with a managed identity or a service principal
+type azureClient struct {
+	zl         *zap.Logger
+	cfg        *AzureConfig
+	httpClient *http.Client
+
+	// a timeout for each request. 0 disables it
+	requestTimeout time.Duration
+
+	mu          sync.Mutex
+	token       string
+	tokenExpiry time.Time
+}
+
+func newAzureClient(cfg *AzureConfig, zl *zap.Logger) (*azureClient, error) {
+	if cfg.VaultURL == "" {
+		return nil, fmt.Errorf("no key vault url set")
+	}
+
+	switch cfg.CredentialType {
+	case "", CredentialManagedIdentity:
+	case CredentialClientSecret:
+		if cfg.TenantID == "" || cfg.ClientID == "" {
+			return nil, fmt.Errorf("the clientsecret credential requires a tenant id and a client id")
+		}
+	default:
+		return nil, fmt.Errorf("unsupported credential type %q", cfg.CredentialType)
+	}
+
+	return &azureClient{
+		zl:         zl,
+		cfg:        cfg,
+		httpClient: &http.Client{},
+	}, nil
+}
+
+// accessToken - a key vault access token, requested again before it expires
+func (c *azureClient) accessToken(ctx context.Context) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.token != "" && time.Until(c.tokenExpiry) > tokenRenewMargin {
+		return c.token, nil
+	}
+
+	var (
+		req *http.Request
+		err error
+	)
+	if c.cfg.CredentialType == CredentialClientSecret {
+		req, err = c.clientSecretTokenRequest(ctx)
+	} else {
+		req, err = c.managedIdentityTokenRequest(ctx)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to get an access token: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		var errResp struct {
+			Error            string `json:"error"`
+			ErrorDescription string `json:"error_description"`
+		}
+		json.NewDecoder(resp.Body).Decode(&errResp)
+		return "", fmt.Errorf("failed to get an access token: %s: %s %s", resp.Status, errResp.Error, errResp.ErrorDescription)
+	}
+
+	var tokenResp tokenResponse
+	if err := json.NewDecoder(resp.Body).Decode(&tokenResp); err != nil {
+		return "", err
+	}
+	expiresIn, err := tokenResp.ExpiresIn.Int64()
+	if err != nil {
+		return "", fmt.Errorf("invalid token expiry %q", tokenResp.ExpiresIn)
+	}
+
+	c.token = tokenResp.AccessToken
+	c.tokenExpiry = time.Now().Add(time.Duration(expiresIn) * time.Second)
+	c.zl.Debug("got an access token", zap.String("credentialType", c.cfg.CredentialType), zap.Int64("expiresInSeconds", expiresIn))
+	return c.token, nil
+}
+
+// managedIdentityTokenRequest - the instance metadata service token request, for the user assigned identity if a client id is set
+func (c *azureClient) managedIdentityTokenRequest(ctx context.Context) (*http.Request, error) {
+	endpoint := c.cfg.IMDSEndpoint
+	if endpoint == "" {
+		endpoint = DefaultIMDSEndpoint
+	}
+
+	query := url.Values{"api-version": {imdsTokenAPIVersion}, "resource": {keyVaultResource}}
+	if c.cfg.ClientID != "" {
+		query.Set("client_id", c.cfg.ClientID)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(endpoint, "/")+imdsTokenPath+"?"+query.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Metadata", "true")
+	return req, nil
+}
+
+// clientSecretTokenRequest - the service principal's client credentials token request
+func (c *azureClient) clientSecretTokenRequest(ctx context.Context) (*http.Request, error) {
+	clientSecret := c.cfg.ClientSecret
+	if c.cfg.ClientSecretPath != "" {
+		b, err := os.ReadFile(c.cfg.ClientSecretPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read the client secret: %w", err)
+		}
+		clientSecret = strings.TrimSpace(string(b))
+	}
+	if clientSecret == "" {
+		return nil, fmt.Errorf("no client secret set")
+	}
+
+	authorityHost := c.cfg.AuthorityHost
+	if authorityHost == "" {
+		authorityHost = DefaultAuthorityHost
+	}
+
+	form := url.Values{
+		"grant_type":    {"client_credentials"},
+		"client_id":     {c.cfg.ClientID},
+		"client_secret": {clientSecret},
+		"scope":         {keyVaultResource + "/.default"},
+	}
+	u := strings.TrimSuffix(authorityHost, "/") + "/" + url.PathEscape(c.cfg.TenantID) + "/oauth2/v2.0/token"
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return req, nil
+}
+
+// get - sends an authenticated GET request to the vault path, decoding the json response into out
+func (c *azureClient) get(ctx context.Context, operation string, path string, query url.Values, out interface{}) error {
+	if query == nil {
+		query = url.Values{}
+	}
+	query.Set("api-version", DefaultAPIVersion)
+	return c.getURL(ctx, operation, strings.TrimSuffix(c.cfg.VaultURL, "/")+"/"+path+"?"+query.Encode(), out)
+}
+
+// getURL - sends an authenticated GET request to the url (E.g: a list nextLink), decoding the json response into out
+func (c *azureClient) getURL(ctx context.Context, operation string, u string, out interface{}) (err error) {
+	start := time.Now()
+	defer func() {
+		metrics.ObserveProviderCall(providerName, operation, time.Since(start), errorCode(err))
+	}()
+
+	if c.requestTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.requestTimeout)
+		defer cancel()
+	}
+
+	token, err := c.accessToken(ctx)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		ae := &AzureError{StatusCode: resp.StatusCode, Code: http.StatusText(resp.StatusCode)}
+		var errResp struct {
+			Error struct {
+				Code    string `json:"code"`
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		if json.NewDecoder(resp.Body).Decode(&errResp) == nil && errResp.Error.Code != "" {
+			ae.Code = errResp.Error.Code
+			ae.Message = errResp.Error.Message
+		}
+		return ae
+	}
+
+	return json.NewDecoder(resp.Body).Decode(out)
+}
@@ -0,0 +1,52 @@
+package azure
+
+import "time"
+
+const (
+	CredentialManagedIdentity = "managedidentity"
+	CredentialClientSecret    = "clientsecret"
+
+	DefaultAuthorityHost  = "https://login.microsoftonline.com"
+	DefaultIMDSEndpoint   = "http://169.254.169.254"
+	DefaultAPIVersion     = "7.4"
+	DefaultConcurrency    = 10
+	DefaultTimeout        = 5 * time.Minute
+	DefaultRequestTimeout = 30 * time.Second
+)
+
+type AzureConfig struct {
+	// the key vault url. E.g: https://my-vault.vault.azure.net
+	VaultURL string
+
+	// managedidentity (default) or clientsecret
+	CredentialType string
+
+	// the service principal (clientsecret), or the user assigned managed identity (managedidentity)
+	TenantID string
+	ClientID string
+
+	// the client secret, or a file to read it from
+	ClientSecret     string
+	ClientSecretPath string
+
+	// the azure ad and instance metadata service endpoints. Overridden in sovereign clouds and tests
+	AuthorityHost string
+	IMDSEndpoint  string
+
+	// list mode: fetch all the vault's secrets with the name prefix and tag key/value prefixes
+	PrefixFilter    string
+	TagKeyFilters   []string
+	TagValueFilters []string
+
+	PathTranslation string
+
+	// failfast, failatend or besteffort. Empty uses the shared failure policy
+	FailurePolicy string
+
+	// the maximum number of secrets fetched in parallel
+	Concurrency int
+
+	// the overall timeout for fetching and writing the secrets, and the timeout for each request. 0 disables them
+	Timeout        time.Duration
+	RequestTimeout time.Duration
+}
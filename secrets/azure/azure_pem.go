@@ -0,0 +1,59 @@
+package azure
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+
+	"software.sslmate.com/src/go-pkcs12"
+)
+
+const (
+	// the content types of certificate backed secrets
+	contentTypePKCS12 = "application/x-pkcs12"
+	contentTypePEM    = "application/x-pem-file"
+)
+
+// secretContent - the secret value. Certificate backed PKCS#12 secrets are converted to PEM (the private key followed by the certificate chain)
+func secretContent(value string, contentType string) (string, error) {
+	if contentType != contentTypePKCS12 {
+		return value, nil
+	}
+
+	pfx, err := base64.StdEncoding.DecodeString(value)
+	if err != nil {
+		return "", fmt.Errorf("failed to decode the pkcs12 content: %w", err)
+	}
+
+	// key vault exports certificates without a password:
+	key, cert, caCerts, err := pkcs12.DecodeChain(pfx, "")
+	if err != nil {
+		return "", fmt.Errorf("failed to decode the pkcs12 content: %w", err)
+	}
+
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode the private key: %w", err)
+	}
+
+	var buf bytes.Buffer
+	pem.Encode(&buf, &pem.Block{Type: "PRIVATE KEY", Bytes: keyDER})
+	for _, c := range append([]*x509.Certificate{cert}, caCerts...) {
+		pem.Encode(&buf, &pem.Block{Type: "CERTIFICATE", Bytes: c.Raw})
+	}
+	return buf.String(), nil
+}
+
+// certificatePEM - a certificate's public DER (base64) as PEM
+func certificatePEM(cer string) (string, error) {
+	der, err := base64.StdEncoding.DecodeString(cer)
+	if err != nil {
+		return "", fmt.Errorf("failed to decode the certificate: %w", err)
+	}
+	if _, err := x509.ParseCertificate(der); err != nil {
+		return "", fmt.Errorf("invalid certificate: %w", err)
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})), nil
+}
@@ -0,0 +1,70 @@
+package azure
+
+import (
+	"context"
+
+	"github.com/daniel-cohen/secretsfetcher/secrets"
+	"go.uber.org/zap"
+)
+
+type ManifestSecretsFetcher struct {
+	// implements secrets.SecretsFetcher
+	zl       *zap.Logger
+	provider *AzureKeyVaultProvider
+	manifest *SecretManifest
+}
+
+func NewManifestSecretFetcher(
+	provider *AzureKeyVaultProvider,
+	manifest *SecretManifest,
+	zl *zap.Logger) *ManifestSecretsFetcher {
+	return &ManifestSecretsFetcher{
+		zl:       zl,
+		provider: provider,
+		manifest: manifest,
+	}
+}
+
+func (msf *ManifestSecretsFetcher) Fetch(ctx context.Context) ([]*secrets.Secret, error) {
+	if err := msf.manifest.Validate(); err != nil {
+		msf.zl.Error("invalid manifest", zap.Error(err))
+		return nil, err
+	}
+
+	secretRes, err := msf.provider.FetchSecrets(ctx, msf.manifest.SecretObjects)
+	if err != nil {
+		return nil, err
+	}
+
+	msf.zl.Info("fetched azure secrets", zap.Int("secrets", len(secretRes)))
+	return secretRes, nil
+}
+
+// ListSecretFetcher - fetches all the vault's secrets matching the prefix and tag filters
+type ListSecretFetcher struct {
+	// implements secrets.SecretsFetcher
+	zl              *zap.Logger
+	provider        *AzureKeyVaultProvider
+	prefix          string
+	tagKeyFilters   []string
+	tagValueFilters []string
+}
+
+func NewListSecretFetcher(
+	provider *AzureKeyVaultProvider,
+	prefix string,
+	tagKeyFilters []string,
+	tagValueFilters []string,
+	zl *zap.Logger) *ListSecretFetcher {
+	return &ListSecretFetcher{
+		zl:              zl,
+		provider:        provider,
+		prefix:          prefix,
+		tagKeyFilters:   tagKeyFilters,
+		tagValueFilters: tagValueFilters,
+	}
+}
+
+func (lsf *ListSecretFetcher) Fetch(ctx context.Context) ([]*secrets.Secret, error) {
+	return lsf.provider.FetchAllSecrets(ctx, lsf.prefix, lsf.tagKeyFilters, lsf.tagValueFilters)
+}
@@ -0,0 +1,70 @@
+package azure
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/daniel-cohen/secretsfetcher/secrets"
+)
+
+const (
+	// the secret value. Certificate backed PKCS#12 secrets are written as PEM (the private key and the certificate chain)
+	ObjectTypeSecret = "secret"
+
+	// a certificate's public part as PEM
+	ObjectTypeCertificate = "cert"
+)
+
+// key vault object names: 1-127 alphanumerics and dashes
+var objectNameRegexp = regexp.MustCompile(`^[0-9a-zA-Z-]{1,127}$`)
+
+// key vault versions: 32 hex characters
+var objectVersionRegexp = regexp.MustCompile(`^[0-9a-fA-F]{32}$`)
+
+type AzureSecretObject struct {
+	ObjectName    string // the secret or certificate name
+	ObjectType    string // secret (default) or cert
+	ObjectVersion string // an optional version id to pin, defaults to the current version
+	ObjectAlias   string // optional output file name, defaults to the object name
+
+	// optional objects which fail to be fetched are skipped
+	Optional bool
+
+	// optional overrides of the writer's file mode (octal. E.g: "0400") and owner
+	FileMode string
+	UID      *int
+	GID      *int
+}
+
+// FilePermissions - the object's file mode and owner overrides, nil if none are set
+func (o *AzureSecretObject) FilePermissions() (*secrets.FilePermissions, error) {
+	perms, err := secrets.ObjectFilePermissions(o.FileMode, o.UID, o.GID)
+	if err != nil {
+		return nil, fmt.Errorf("object %s: %w", o.ObjectName, err)
+	}
+	return perms, nil
+}
+
+func (o *AzureSecretObject) validate() error {
+	if !objectNameRegexp.MatchString(o.ObjectName) {
+		return fmt.Errorf("invalid object name %q: expected alphanumerics and dashes", o.ObjectName)
+	}
+	if o.ObjectVersion != "" && !objectVersionRegexp.MatchString(o.ObjectVersion) {
+		return fmt.Errorf("object %s: invalid version %q", o.ObjectName, o.ObjectVersion)
+	}
+	switch o.ObjectType {
+	case "", ObjectTypeSecret, ObjectTypeCertificate:
+	default:
+		return fmt.Errorf("object %s: unsupported object type %q (expected secret or cert)", o.ObjectName, o.ObjectType)
+	}
+	_, err := o.FilePermissions()
+	return err
+}
+
+// outputName - the alias or object name the object is written as
+func (o *AzureSecretObject) outputName() string {
+	if o.ObjectAlias != "" {
+		return o.ObjectAlias
+	}
+	return o.ObjectName
+}
@@ -0,0 +1,236 @@
+package azure
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/daniel-cohen/secretsfetcher/secrets"
+	"go.uber.org/zap"
+)
+
+// the maximum page size of the secrets list
+const listPageSize = 25
+
+type secretBundle struct {
+	ID          string `json:"id"` // https://<vault>.vault.azure.net/secrets/<name>/<version>
+	Value       string `json:"value"`
+	ContentType string `json:"contentType"`
+}
+
+type certificateBundle struct {
+	ID  string `json:"id"` // https://<vault>.vault.azure.net/certificates/<name>/<version>
+	Cer string `json:"cer"`
+}
+
+type secretListResult struct {
+	Value []struct {
+		ID         string            `json:"id"` // https://<vault>.vault.azure.net/secrets/<name>
+		Tags       map[string]string `json:"tags"`
+		Attributes struct {
+			Enabled bool `json:"enabled"`
+		} `json:"attributes"`
+	} `json:"value"`
+	NextLink string `json:"nextLink"`
+}
+
+type AzureKeyVaultProvider struct {
+	zl     *zap.Logger
+	client *azureClient
+
+	// the maximum number of secrets fetched in parallel
+	concurrency int
+
+	failurePolicy secrets.FailurePolicy
+}
+
+// WithFailurePolicy - sets how secrets which failed to be fetched are handled
+func (p *AzureKeyVaultProvider) WithFailurePolicy(policy secrets.FailurePolicy) *AzureKeyVaultProvider {
+	p.failurePolicy = policy
+	return p
+}
+
+// WithConcurrency - the maximum number of secrets fetched in parallel
+func (p *AzureKeyVaultProvider) WithConcurrency(concurrency int) *AzureKeyVaultProvider {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	p.concurrency = concurrency
+	return p
+}
+
+// WithRequestTimeout - the timeout for each key vault request. 0 disables it
+func (p *AzureKeyVaultProvider) WithRequestTimeout(timeout time.Duration) *AzureKeyVaultProvider {
+	p.client.requestTimeout = timeout
+	return p
+}
+
+func NewAzureKeyVaultProvider(cfg *AzureConfig, zl *zap.Logger) (*AzureKeyVaultProvider, error) {
+	client, err := newAzureClient(cfg, zl)
+	if err != nil {
+		return nil, err
+	}
+
+	return &AzureKeyVaultProvider{
+		zl:            zl,
+		client:        client,
+		concurrency:   DefaultConcurrency,
+		failurePolicy: secrets.DefaultFailurePolicy,
+	}, nil
+}
+
+// lastSegment - the last path segment of a key vault object id. E.g: its version or name
+func lastSegment(id string) string {
+	return id[strings.LastIndex(id, "/")+1:]
+}
+
+// fetchSecret - gets the object's secret or certificate, with its output options set
+func (p *AzureKeyVaultProvider) fetchSecret(ctx context.Context, obj *AzureSecretObject) (*secrets.Secret, error) {
+	if err := obj.validate(); err != nil {
+		return nil, err
+	}
+
+	var (
+		content string
+		id      string
+	)
+	switch obj.ObjectType {
+	case ObjectTypeCertificate:
+		var bundle certificateBundle
+		if err := p.client.get(ctx, "GetCertificate", objectPath("certificates", obj), nil, &bundle); err != nil {
+			return nil, fmt.Errorf("failed to get certificate %s: %w", obj.ObjectName, err)
+		}
+
+		pem, err := certificatePEM(bundle.Cer)
+		if err != nil {
+			return nil, fmt.Errorf("certificate %s: %w", obj.ObjectName, err)
+		}
+		content, id = pem, bundle.ID
+	default:
+		var bundle secretBundle
+		if err := p.client.get(ctx, "GetSecret", objectPath("secrets", obj), nil, &bundle); err != nil {
+			return nil, fmt.Errorf("failed to get secret %s: %w", obj.ObjectName, err)
+		}
+
+		value, err := secretContent(bundle.Value, bundle.ContentType)
+		if err != nil {
+			return nil, fmt.Errorf("secret %s: %w", obj.ObjectName, err)
+		}
+		content, id = value, bundle.ID
+	}
+
+	perms, err := obj.FilePermissions()
+	if err != nil {
+		return nil, err
+	}
+
+	return &secrets.Secret{
+		Name:        obj.ObjectName,
+		Content:     content,
+		Alias:       obj.ObjectAlias,
+		Permissions: perms,
+		Version:     lastSegment(id),
+	}, nil
+}
+
+// objectPath - the object's api path (secrets/<name>[/<version>])
+func objectPath(collection string, obj *AzureSecretObject) string {
+	path := collection + "/" + url.PathEscape(obj.ObjectName)
+	if obj.ObjectVersion != "" {
+		path += "/" + url.PathEscape(obj.ObjectVersion)
+	}
+	return path
+}
+
+// FetchSecrets - fetches the objects' secrets in parallel, handling the failed ones by the failure policy
+func (p *AzureKeyVaultProvider) FetchSecrets(ctx context.Context, objs []*AzureSecretObject) ([]*secrets.Secret, error) {
+	refs := make([]secrets.ObjectRef, len(objs))
+	for i, obj := range objs {
+		refs[i] = secrets.ObjectRef{Name: obj.ObjectName, Optional: obj.Optional}
+	}
+
+	return secrets.FetchObjects(ctx, refs, p.concurrency, p.failurePolicy, func(ctx context.Context, i int) (*secrets.Secret, error) {
+		return p.fetchSecret(ctx, objs[i])
+	}, p.zl)
+}
+
+// hasTags - true if every tag key filter prefixes one of the tag keys, and every tag value filter one of the tag values (like the aws tag filters)
+func hasTags(tags map[string]string, tagKeyFilters []string, tagValueFilters []string) bool {
+	for _, f := range tagKeyFilters {
+		found := false
+		for k := range tags {
+			if strings.HasPrefix(k, f) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	for _, f := range tagValueFilters {
+		found := false
+		for _, v := range tags {
+			if strings.HasPrefix(v, f) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	return true
+}
+
+// listSecrets - the names of the vault's enabled secrets with the name prefix and tag filters.
+// The secrets list api has no filters, so they're applied here
+func (p *AzureKeyVaultProvider) listSecrets(ctx context.Context, prefix string, tagKeyFilters []string, tagValueFilters []string) ([]string, error) {
+	var (
+		names []string
+		resp  secretListResult
+	)
+	if err := p.client.get(ctx, "GetSecrets", "secrets", url.Values{"maxresults": {fmt.Sprint(listPageSize)}}, &resp); err != nil {
+		return nil, err
+	}
+
+	for {
+		for _, s := range resp.Value {
+			name := lastSegment(s.ID)
+			if !s.Attributes.Enabled || !strings.HasPrefix(name, prefix) || !hasTags(s.Tags, tagKeyFilters, tagValueFilters) {
+				continue
+			}
+			names = append(names, name)
+		}
+
+		if resp.NextLink == "" {
+			return names, nil
+		}
+
+		nextLink := resp.NextLink
+		resp = secretListResult{}
+		if err := p.client.getURL(ctx, "GetSecrets", nextLink, &resp); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// FetchAllSecrets - fetches the current version of all the vault's enabled secrets with the name prefix and tag filters
+func (p *AzureKeyVaultProvider) FetchAllSecrets(ctx context.Context, prefix string, tagKeyFilters []string, tagValueFilters []string) ([]*secrets.Secret, error) {
+	names, err := p.listSecrets(ctx, prefix, tagKeyFilters, tagValueFilters)
+	if err != nil {
+		p.zl.Error("failed to list secrets", zap.String("vaultURL", p.client.cfg.VaultURL), zap.Error(err))
+		return nil, err
+	}
+	p.zl.Info("listed secrets", zap.String("vaultURL", p.client.cfg.VaultURL), zap.Int("secrets", len(names)))
+
+	objs := make([]*AzureSecretObject, 0, len(names))
+	for _, name := range names {
+		objs = append(objs, &AzureSecretObject{ObjectName: name})
+	}
+	return p.FetchSecrets(ctx, objs)
+}
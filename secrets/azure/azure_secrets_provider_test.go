@@ -0,0 +1,378 @@
+package azure
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"go.uber.org/zap/zaptest"
+	"software.sslmate.com/src/go-pkcs12"
+
+	"github.com/daniel-cohen/secretsfetcher/secrets"
+)
+
+const fakeToken = "eyJ0eXAi.fake-token"
+
+type fakeSecretVersion struct {
+	id          string
+	value       string
+	contentType string
+}
+
+type fakeSecret struct {
+	tags     map[string]string
+	disabled bool
+	versions []fakeSecretVersion
+}
+
+// fakeKeyVault - the key vault secrets and certificates apis, and the managed identity and azure ad token endpoints
+type fakeKeyVault struct {
+	mu      sync.Mutex
+	secrets map[string]*fakeSecret
+	// the public DER of each certificate
+	certificates map[string][]byte
+
+	pageSize      int
+	tokenRequests int32
+	tokenForms    []map[string][]string
+}
+
+func (f *fakeKeyVault) writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+func (f *fakeKeyVault) writeError(w http.ResponseWriter, status int, code string, message string) {
+	f.writeJSON(w, status, map[string]interface{}{"error": map[string]interface{}{"code": code, "message": message}})
+}
+
+func (f *fakeKeyVault) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	baseURL := "http://" + r.Host
+	switch {
+	case r.URL.Path == imdsTokenPath:
+		if r.Header.Get("Metadata") != "true" || r.URL.Query().Get("resource") != keyVaultResource {
+			f.writeJSON(w, http.StatusBadRequest, map[string]interface{}{"error": "invalid_request"})
+			return
+		}
+		atomic.AddInt32(&f.tokenRequests, 1)
+		f.tokenForms = append(f.tokenForms, r.URL.Query())
+		// managed identity expiries are strings:
+		f.writeJSON(w, http.StatusOK, map[string]interface{}{"access_token": fakeToken, "expires_in": "3599"})
+		return
+	case strings.HasSuffix(r.URL.Path, "/oauth2/v2.0/token"):
+		r.ParseForm()
+		if r.PostForm.Get("client_secret") != "client-secret" {
+			f.writeJSON(w, http.StatusUnauthorized, map[string]interface{}{"error": "invalid_client", "error_description": "AADSTS7000215: Invalid client secret provided."})
+			return
+		}
+		atomic.AddInt32(&f.tokenRequests, 1)
+		f.tokenForms = append(f.tokenForms, r.PostForm)
+		f.writeJSON(w, http.StatusOK, map[string]interface{}{"access_token": fakeToken, "expires_in": 3599, "token_type": "Bearer"})
+		return
+	case r.Header.Get("Authorization") != "Bearer "+fakeToken:
+		f.writeError(w, http.StatusUnauthorized, "Unauthorized", "AKV10000: Request is missing a Bearer or PoP token.")
+		return
+	case r.URL.Query().Get("api-version") != DefaultAPIVersion:
+		f.writeError(w, http.StatusBadRequest, "BadParameter", "invalid api version")
+		return
+	}
+
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	switch {
+	case len(parts) == 1 && parts[0] == "secrets":
+		f.list(w, r, baseURL)
+	case len(parts) >= 2 && parts[0] == "secrets":
+		secret, ok := f.secrets[parts[1]]
+		if !ok || secret.disabled {
+			f.writeError(w, http.StatusNotFound, "SecretNotFound", "A secret with (name/id) "+parts[1]+" was not found in this key vault.")
+			return
+		}
+
+		version := secret.versions[len(secret.versions)-1]
+		if len(parts) == 3 {
+			found := false
+			for _, v := range secret.versions {
+				if v.id == parts[2] {
+					version, found = v, true
+				}
+			}
+			if !found {
+				f.writeError(w, http.StatusNotFound, "SecretNotFound", "A secret with (name/id) "+parts[1]+"/"+parts[2]+" was not found in this key vault.")
+				return
+			}
+		}
+		f.writeJSON(w, http.StatusOK, map[string]interface{}{
+			"id":          baseURL + "/secrets/" + parts[1] + "/" + version.id,
+			"value":       version.value,
+			"contentType": version.contentType,
+			"attributes":  map[string]interface{}{"enabled": true},
+		})
+	case len(parts) == 2 && parts[0] == "certificates":
+		der, ok := f.certificates[parts[1]]
+		if !ok {
+			f.writeError(w, http.StatusNotFound, "CertificateNotFound", "A certificate with (name/id) "+parts[1]+" was not found in this key vault.")
+			return
+		}
+		f.writeJSON(w, http.StatusOK, map[string]interface{}{
+			"id":  baseURL + "/certificates/" + parts[1] + "/0b1c2d3e4f5a6b7c8d9e0f1a2b3c4d5e",
+			"cer": base64.StdEncoding.EncodeToString(der),
+		})
+	default:
+		f.writeError(w, http.StatusNotFound, "NotFound", "not found")
+	}
+}
+
+// list - pages through all the secrets with absolute next links
+func (f *fakeKeyVault) list(w http.ResponseWriter, r *http.Request, baseURL string) {
+	var names []string
+	for name := range f.secrets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	start, _ := strconv.Atoi(r.URL.Query().Get("$skiptoken"))
+	end := len(names)
+	nextLink := ""
+	if f.pageSize > 0 && start+f.pageSize < end {
+		end = start + f.pageSize
+		nextLink = baseURL + "/secrets?api-version=" + DefaultAPIVersion + "&$skiptoken=" + strconv.Itoa(end)
+	}
+
+	res := []map[string]interface{}{}
+	for _, name := range names[start:end] {
+		res = append(res, map[string]interface{}{
+			"id":         baseURL + "/secrets/" + name,
+			"tags":       f.secrets[name].tags,
+			"attributes": map[string]interface{}{"enabled": !f.secrets[name].disabled},
+		})
+	}
+	f.writeJSON(w, http.StatusOK, map[string]interface{}{"value": res, "nextLink": nextLink})
+}
+
+// newCertificate - a self signed certificate, its key and a PKCS#12 (base64) bundle of them like key vault's
+func newCertificate() (*x509.Certificate, *ecdsa.PrivateKey, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	Expect(err).NotTo(HaveOccurred())
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "my-app.example.com"},
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	Expect(err).NotTo(HaveOccurred())
+	cert, err := x509.ParseCertificate(der)
+	Expect(err).NotTo(HaveOccurred())
+
+	pfx, err := pkcs12.Modern.Encode(key, cert, nil, "")
+	Expect(err).NotTo(HaveOccurred())
+	return cert, key, base64.StdEncoding.EncodeToString(pfx)
+}
+
+var _ = Describe("Fetching azure key vault secrets", func() {
+	var (
+		keyVault *fakeKeyVault
+		server   *httptest.Server
+		cfg      *AzureConfig
+		cert     *x509.Certificate
+		key      *ecdsa.PrivateKey
+	)
+
+	BeforeEach(func() {
+		var pfx string
+		cert, key, pfx = newCertificate()
+
+		keyVault = &fakeKeyVault{
+			secrets: map[string]*fakeSecret{
+				"db-password": {tags: map[string]string{"app": "my-app", "env": "prod"}, versions: []fakeSecretVersion{
+					{id: "4387e9f3d6e14c459867679a90fd0f79", value: "old"},
+					{id: "5f1bd1a2c3d44e5f8a9b0c1d2e3f4a5b", value: "rotated"},
+				}},
+				"api-key":      {tags: map[string]string{"app": "my-app", "env": "prod"}, versions: []fakeSecretVersion{{id: "a1", value: "api-key"}}},
+				"db-staging":   {tags: map[string]string{"app": "my-app", "env": "staging"}, versions: []fakeSecretVersion{{id: "b1", value: "staging"}}},
+				"db-disabled":  {tags: map[string]string{"app": "my-app", "env": "prod"}, disabled: true, versions: []fakeSecretVersion{{id: "c1", value: "disabled"}}},
+				"other-secret": {tags: map[string]string{"team": "other"}, versions: []fakeSecretVersion{{id: "d1", value: "other"}}},
+				"tls":          {versions: []fakeSecretVersion{{id: "0b1c2d3e4f5a6b7c8d9e0f1a2b3c4d5e", value: pfx, contentType: contentTypePKCS12}}},
+			},
+			certificates: map[string][]byte{"tls": cert.Raw},
+		}
+		server = httptest.NewServer(keyVault)
+		cfg = &AzureConfig{VaultURL: server.URL, IMDSEndpoint: server.URL, AuthorityHost: server.URL}
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	newProvider := func() *AzureKeyVaultProvider {
+		provider, err := NewAzureKeyVaultProvider(cfg, zaptest.NewLogger(GinkgoT()))
+		Expect(err).NotTo(HaveOccurred())
+		return provider
+	}
+
+	It("fetches the current version of a secret", func() {
+		res, err := newProvider().FetchSecrets(context.Background(), []*AzureSecretObject{{ObjectName: "db-password"}})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(res).To(HaveLen(1))
+		Expect(res[0].Name).To(Equal("db-password"))
+		Expect(res[0].Content).To(Equal("rotated"))
+		Expect(res[0].Version).To(Equal("5f1bd1a2c3d44e5f8a9b0c1d2e3f4a5b"))
+	})
+
+	It("fetches a pinned version", func() {
+		res, err := newProvider().FetchSecrets(context.Background(), []*AzureSecretObject{
+			{ObjectName: "db-password", ObjectVersion: "4387e9f3d6e14c459867679a90fd0f79", ObjectAlias: "old-password", FileMode: "0400"},
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(res[0].Content).To(Equal("old"))
+		Expect(res[0].Alias).To(Equal("old-password"))
+		Expect(res[0].Version).To(Equal("4387e9f3d6e14c459867679a90fd0f79"))
+		Expect(res[0].Permissions.Mode).To(BeEquivalentTo(0400))
+	})
+
+	It("exports certificates as pem", func() {
+		res, err := newProvider().FetchSecrets(context.Background(), []*AzureSecretObject{
+			{ObjectName: "tls", ObjectAlias: "tls.pem"},
+			{ObjectName: "tls", ObjectType: ObjectTypeCertificate, ObjectAlias: "tls.crt"},
+		})
+		Expect(err).NotTo(HaveOccurred())
+
+		// the secret has the private key followed by the certificate:
+		block, rest := pem.Decode([]byte(res[0].Content))
+		Expect(block.Type).To(Equal("PRIVATE KEY"))
+		parsedKey, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(key.Equal(parsedKey)).To(BeTrue())
+		block, _ = pem.Decode(rest)
+		Expect(block.Type).To(Equal("CERTIFICATE"))
+		Expect(block.Bytes).To(Equal(cert.Raw))
+
+		// the certificate only has its public part:
+		block, rest = pem.Decode([]byte(res[1].Content))
+		Expect(block.Type).To(Equal("CERTIFICATE"))
+		Expect(block.Bytes).To(Equal(cert.Raw))
+		Expect(rest).To(BeEmpty())
+	})
+
+	It("fails on missing secrets, versions and certificates unless optional", func() {
+		provider := newProvider()
+
+		_, err := provider.FetchSecrets(context.Background(), []*AzureSecretObject{
+			{ObjectName: "db-password"},
+			{ObjectName: "missing"},
+			{ObjectName: "api-key", ObjectVersion: "ffffffffffffffffffffffffffffffff"},
+			{ObjectName: "db-password", ObjectType: ObjectTypeCertificate, ObjectAlias: "db-cert"},
+		})
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("SecretNotFound"))
+		Expect(err.Error()).To(ContainSubstring("missing"))
+		Expect(err.Error()).To(ContainSubstring("api-key"))
+		Expect(err.Error()).To(ContainSubstring("CertificateNotFound"))
+
+		res, err := provider.FetchSecrets(context.Background(), []*AzureSecretObject{
+			{ObjectName: "db-password"},
+			{ObjectName: "missing", Optional: true},
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(res).To(HaveLen(1))
+	})
+
+	It("lists and fetches all the enabled secrets with the prefix and tag filters over several pages", func() {
+		keyVault.pageSize = 2
+		res, err := newProvider().FetchAllSecrets(context.Background(), "db-", []string{"app"}, []string{"pro"})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(res).To(HaveLen(1))
+		Expect(res[0].Name).To(Equal("db-password"))
+		Expect(res[0].Content).To(Equal("rotated"))
+
+		res, err = newProvider().FetchAllSecrets(context.Background(), "", []string{"app"}, nil)
+		Expect(err).NotTo(HaveOccurred())
+
+		var names []string
+		for _, s := range res {
+			names = append(names, s.Name)
+		}
+		Expect(names).To(Equal([]string{"api-key", "db-password", "db-staging"}))
+	})
+
+	Describe("credentials", func() {
+		It("requests a user assigned managed identity token once while it is valid", func() {
+			cfg.ClientID = "identity-client-id"
+			provider := newProvider()
+			for i := 0; i < 2; i++ {
+				_, err := provider.FetchSecrets(context.Background(), []*AzureSecretObject{{ObjectName: "db-password"}})
+				Expect(err).NotTo(HaveOccurred())
+			}
+			Expect(atomic.LoadInt32(&keyVault.tokenRequests)).To(BeEquivalentTo(1))
+			Expect(keyVault.tokenForms[0]["client_id"]).To(ConsistOf("identity-client-id"))
+		})
+
+		It("requests a service principal token with the client secret from a file", func() {
+			tmpFolder, err := ioutil.TempDir("", "secretsfetcher")
+			Expect(err).NotTo(HaveOccurred())
+			defer os.RemoveAll(tmpFolder)
+
+			cfg.CredentialType = CredentialClientSecret
+			cfg.TenantID = "tenant-id"
+			cfg.ClientID = "client-id"
+			cfg.ClientSecretPath = filepath.Join(tmpFolder, "client-secret")
+			Expect(ioutil.WriteFile(cfg.ClientSecretPath, []byte("client-secret\n"), 0600)).To(Succeed())
+
+			_, err = newProvider().FetchSecrets(context.Background(), []*AzureSecretObject{{ObjectName: "db-password"}})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(keyVault.tokenForms[0]["scope"]).To(ConsistOf("https://vault.azure.net/.default"))
+		})
+
+		It("fails with an invalid client secret", func() {
+			cfg.CredentialType = CredentialClientSecret
+			cfg.TenantID = "tenant-id"
+			cfg.ClientID = "client-id"
+			cfg.ClientSecret = "wrong"
+
+			_, err := newProvider().FetchSecrets(context.Background(), []*AzureSecretObject{{ObjectName: "db-password"}})
+			Expect(err).To(MatchError(ContainSubstring("invalid_client")))
+		})
+
+		It("fails without a tenant for the client secret credential", func() {
+			cfg.CredentialType = CredentialClientSecret
+			_, err := NewAzureKeyVaultProvider(cfg, zaptest.NewLogger(GinkgoT()))
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	It("fetches the manifest secrets", func() {
+		fetcher := NewManifestSecretFetcher(newProvider(), &SecretManifest{
+			SecretObjects: []*AzureSecretObject{{ObjectName: "api-key", ObjectAlias: "key"}},
+		}, zaptest.NewLogger(GinkgoT()))
+
+		var _ secrets.SecretsFetcher = fetcher
+		res, err := fetcher.Fetch(context.Background())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(res[0].Content).To(Equal("api-key"))
+	})
+})
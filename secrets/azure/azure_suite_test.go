@@ -0,0 +1,13 @@
+package azure_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func TestAzureSecrets(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "azure secret Suite")
+}
@@ -0,0 +1,150 @@
+package azure
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/daniel-cohen/secretsfetcher/secrets"
+	"github.com/spf13/pflag"
+	"go.uber.org/zap"
+)
+
+// registration - the azure provider of the secrets registry
+type registration struct {
+	// implements secrets.Provider
+}
+
+func init() {
+	secrets.RegisterProvider(registration{})
+}
+
+func (registration) Name() string {
+	return ProviderName
+}
+
+func (registration) Usage() secrets.ProviderUsage {
+	return secrets.ProviderUsage{
+		Short: "fetches secrets and certificates from an azure key vault",
+		Long: `Fetches the secrets in an azure manifest, or all the vault's secrets with the name prefix (--prefix) and tag filters (--tagkeys, --tagvalues), and writes them like the aws command.
+The tenant id, client id and client secret default to the AZURE_TENANT_ID, AZURE_CLIENT_ID and AZURE_CLIENT_SECRET environment variables.`,
+		Example: "  secretsfetcher azure -m manifest.yaml --vaulturl https://my-vault.vault.azure.net -o /secrets\n  secretsfetcher azure --vaulturl https://my-vault.vault.azure.net --tagkeys app --tagvalues my-app -o /secrets",
+	}
+}
+
+func (registration) ConfigDefaults() map[string]interface{} {
+	return map[string]interface{}{
+		"VaultURL":         "",
+		"CredentialType":   CredentialManagedIdentity,
+		"TenantID":         "",
+		"ClientID":         "",
+		"ClientSecret":     "",
+		"ClientSecretPath": "",
+		"AuthorityHost":    DefaultAuthorityHost,
+		"IMDSEndpoint":     DefaultIMDSEndpoint,
+		"PrefixFilter":     "",
+		"TagKeyFilters":    []string{},
+		"TagValueFilters":  []string{},
+		"PathTranslation":  secrets.DefaultPathTranslation,
+		"FailurePolicy":    "",
+		"Concurrency":      DefaultConcurrency,
+		"Timeout":          DefaultTimeout,
+		"RequestTimeout":   DefaultRequestTimeout,
+	}
+}
+
+func (registration) AddFlags(flags *pflag.FlagSet) map[string]string {
+	flags.String("vaulturl", "", "the key vault url. Example: --vaulturl=https://my-vault.vault.azure.net")
+	flags.String("credential", CredentialManagedIdentity, "the credential type: managedidentity or clientsecret")
+	flags.String("tenantid", "", "the service principal's tenant id for --credential=clientsecret (defaults to AZURE_TENANT_ID)")
+	flags.String("clientid", "", "the service principal's client id, or the client id of a user assigned managed identity (defaults to AZURE_CLIENT_ID)")
+	flags.String("clientsecretpath", "", "a file with the service principal's client secret (or set APP_AZURE_CLIENTSECRET or AZURE_CLIENT_SECRET)")
+	flags.String("authorityhost", DefaultAuthorityHost, "the azure ad authority host (for sovereign clouds)")
+	flags.String("prefix", "", "only fetch the vault's secrets with names starting with this prefix (without a manifest)")
+	flags.StringSlice("tagkeys", []string{}, "an array of tag key prefixes the listed secrets must have (without a manifest). Example: --tagkeys=app,secret-type")
+	flags.StringSlice("tagvalues", []string{}, "an array of tag value prefixes the listed secrets must have (without a manifest). Example: --tagvalues=my-app-name")
+	flags.Int("concurrency", DefaultConcurrency, "the maximum number of secrets fetched in parallel")
+	flags.Duration("timeout", DefaultTimeout, "the overall timeout for fetching and writing the secrets. 0 disables the timeout")
+	flags.Duration("requesttimeout", DefaultRequestTimeout, "the timeout for each key vault request. 0 disables the timeout")
+
+	return map[string]string{
+		"vaulturl":         "VaultURL",
+		"credential":       "CredentialType",
+		"tenantid":         "TenantID",
+		"clientid":         "ClientID",
+		"clientsecretpath": "ClientSecretPath",
+		"authorityhost":    "AuthorityHost",
+		"prefix":           "PrefixFilter",
+		"tagkeys":          "TagKeyFilters",
+		"tagvalues":        "TagValueFilters",
+		"concurrency":      "Concurrency",
+		"timeout":          "Timeout",
+		"requesttimeout":   "RequestTimeout",
+	}
+}
+
+// NewFetch - the fetcher for the manifest, or all the vault's secrets with the prefix and tag filters
+func (registration) NewFetch(ctx context.Context, config secrets.Decoder, manifest secrets.Decoder, zl *zap.Logger) (*secrets.ProviderFetch, error) {
+	cfg := &AzureConfig{}
+	if err := config(cfg); err != nil {
+		return nil, fmt.Errorf("invalid azure config: %w", err)
+	}
+
+	if cfg.TenantID == "" {
+		cfg.TenantID = os.Getenv("AZURE_TENANT_ID")
+	}
+	if cfg.ClientID == "" {
+		cfg.ClientID = os.Getenv("AZURE_CLIENT_ID")
+	}
+	if cfg.ClientSecret == "" && cfg.ClientSecretPath == "" {
+		cfg.ClientSecret = os.Getenv("AZURE_CLIENT_SECRET")
+	}
+
+	pathTranslation := cfg.PathTranslation
+
+	var manifestCfg *SecretManifest
+	if manifest != nil {
+		manifestCfg = &SecretManifest{}
+		if err := manifest(manifestCfg); err != nil {
+			return nil, fmt.Errorf("unable to decode the manifest: %w", err)
+		}
+		if err := manifestCfg.Validate(); err != nil {
+			return nil, fmt.Errorf("invalid manifest: %w", err)
+		}
+		zl.Info("Loaded manifest config", zap.Any("manifestCfg", manifestCfg))
+
+		// the manifest takes precedence over the main config:
+		if manifestCfg.VaultURL != "" {
+			cfg.VaultURL = manifestCfg.VaultURL
+		}
+		if manifestCfg.PathTranslation != "" {
+			pathTranslation = manifestCfg.PathTranslation
+		}
+	}
+
+	failurePolicy, err := secrets.ParseFailurePolicy(cfg.FailurePolicy)
+	if err != nil {
+		return nil, fmt.Errorf("invalid failure policy: %w", err)
+	}
+
+	provider, err := NewAzureKeyVaultProvider(cfg, zl)
+	if err != nil {
+		return nil, fmt.Errorf("failed to setup azure key vault provider: %w", err)
+	}
+	provider.WithConcurrency(cfg.Concurrency).WithRequestTimeout(cfg.RequestTimeout).WithFailurePolicy(failurePolicy)
+
+	fetch := &secrets.ProviderFetch{
+		PathTranslation: secrets.PathTranslationChar(pathTranslation),
+		Timeout:         cfg.Timeout,
+	}
+	if manifestCfg != nil {
+		fetch.Fetcher = NewManifestSecretFetcher(provider, manifestCfg, zl)
+		fetch.Templates = manifestCfg.Templates
+		fetch.Required = manifestCfg.RequiredSecrets()
+		fetch.Outputs = manifestCfg.Outputs(fetch.PathTranslation)
+	} else {
+		fetch.Fetcher = NewListSecretFetcher(provider, cfg.PrefixFilter, cfg.TagKeyFilters, cfg.TagValueFilters, zl)
+	}
+
+	return fetch, nil
+}
@@ -0,0 +1,30 @@
+package azure
+
+import (
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+var _ = DescribeTable("Validating an azure manifest",
+	func(manifest *SecretManifest, expectError bool) {
+		err := manifest.Validate()
+		if expectError {
+			Expect(err).To(HaveOccurred())
+		} else {
+			Expect(err).NotTo(HaveOccurred())
+		}
+	},
+	Entry("valid", &SecretManifest{Provider: "azure", SecretObjects: []*AzureSecretObject{{ObjectName: "db-password", ObjectVersion: "4387e9f3d6e14c459867679a90fd0f79"}, {ObjectName: "tls", ObjectType: "cert"}}}, false),
+	Entry("invalid object name", &SecretManifest{SecretObjects: []*AzureSecretObject{{ObjectName: "my-app/db"}}}, true),
+	Entry("invalid version", &SecretManifest{SecretObjects: []*AzureSecretObject{{ObjectName: "a", ObjectVersion: "latest"}}}, true),
+	Entry("unsupported object type", &SecretManifest{SecretObjects: []*AzureSecretObject{{ObjectName: "a", ObjectType: "key"}}}, true),
+	Entry("invalid file mode", &SecretManifest{SecretObjects: []*AzureSecretObject{{ObjectName: "a", FileMode: "0999"}}}, true),
+	Entry("the secret and certificate of a certificate without aliases", &SecretManifest{SecretObjects: []*AzureSecretObject{
+		{ObjectName: "tls"},
+		{ObjectName: "tls", ObjectType: "cert"},
+	}}, true),
+	Entry("the secret and certificate of a certificate with aliases", &SecretManifest{SecretObjects: []*AzureSecretObject{
+		{ObjectName: "tls", ObjectAlias: "tls.pem"},
+		{ObjectName: "tls", ObjectType: "cert", ObjectAlias: "tls.crt"},
+	}}, false),
+)
@@ -0,0 +1,208 @@
+package secrets
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/daniel-cohen/secretsfetcher/metrics"
+	"go.uber.org/zap"
+)
+
+const DefaultCacheTTL = 5 * time.Minute
+
+var ErrSecretNotFound = errors.New("secret not found")
+
+// SecretsCache - keeps the fetched secrets in memory, fetching them again once they're older than the ttl
+type SecretsCache struct {
+	zl      *zap.Logger
+	fetcher SecretsFetcher
+	ttl     time.Duration
+
+	// the timeout of each fetch. 0 disables it
+	fetchTimeout time.Duration
+
+	now func() time.Time
+
+	// guards the secrets and the fetch in flight. Never held while fetching
+	mu        sync.Mutex
+	secrets   map[string]*Secret // by alias or name
+	byName    map[string]*Secret
+	fetchedAt time.Time
+	inflight  *cacheFetch
+}
+
+// cacheFetch - a fetch shared by the lookups which need it, closing done once it finished
+type cacheFetch struct {
+	done    chan struct{}
+	changed []string
+	err     error
+}
+
+// WithFetchTimeout - the timeout for fetching the secrets. 0 disables it
+func (c *SecretsCache) WithFetchTimeout(timeout time.Duration) *SecretsCache {
+	c.fetchTimeout = timeout
+	return c
+}
+
+func NewSecretsCache(
+	fetcher SecretsFetcher,
+	ttl time.Duration,
+	zl *zap.Logger) *SecretsCache {
+	return &SecretsCache{
+		zl:      zl,
+		fetcher: fetcher,
+		ttl:     ttl,
+		now:     time.Now,
+	}
+}
+
+// fetch - joins the fetch in flight, or starts one. The fetch isn't bound to the ctx of the lookups waiting for it
+// (only to the fetch timeout), so a cancelled lookup doesn't fail the others.
+// Returns the names (alias or name) of the changed secrets
+func (c *SecretsCache) fetch(ctx context.Context) ([]string, error) {
+	c.mu.Lock()
+	f := c.inflight
+	if f == nil {
+		f = &cacheFetch{done: make(chan struct{})}
+		c.inflight = f
+		go c.runFetch(f)
+	}
+	c.mu.Unlock()
+
+	select {
+	case <-f.done:
+		return f.changed, f.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// runFetch - fetches the secrets (only the changed ones if the fetcher supports it), and swaps them in the cache
+func (c *SecretsCache) runFetch(f *cacheFetch) {
+	defer close(f.done)
+
+	ctx := context.Background()
+	if c.fetchTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.fetchTimeout)
+		defer cancel()
+	}
+
+	var (
+		secretRes []*Secret
+		err       error
+	)
+	if cf, ok := c.fetcher.(ChangesFetcher); ok {
+		secretRes, err = cf.FetchChanges(ctx)
+	} else {
+		secretRes, err = c.fetcher.Fetch(ctx)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.inflight = nil
+
+	if err != nil {
+		metrics.RefreshFailed()
+		f.err = err
+		return
+	}
+
+	current := map[string]*Secret{}
+	byName := map[string]*Secret{}
+	for _, s := range secretRes {
+		current[secretKey(s)] = s
+		byName[s.Name] = s
+	}
+
+	f.changed = changedSecrets(c.secrets, current)
+	c.secrets = current
+	c.byName = byName
+	c.fetchedAt = c.now()
+	metrics.RefreshSucceeded(c.fetchedAt)
+
+	c.zl.Debug("fetched secrets into the cache", zap.Int("secrets", len(current)), zap.Strings("changed", f.changed))
+}
+
+// ensureFresh - fetches the secrets if they were never fetched or are older than the ttl.
+// Expired secrets are still served if fetching them again fails
+func (c *SecretsCache) ensureFresh(ctx context.Context) error {
+	c.mu.Lock()
+	fresh := c.secrets != nil && c.now().Sub(c.fetchedAt) < c.ttl
+	c.mu.Unlock()
+	if fresh {
+		return nil
+	}
+
+	_, err := c.fetch(ctx)
+	if err == nil {
+		return nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.secrets == nil || ctx.Err() != nil {
+		return err
+	}
+	c.zl.Warn("failed to refresh the expired secrets, serving the previous ones",
+		zap.Time("fetchedAt", c.fetchedAt),
+		zap.Error(err),
+	)
+	return nil
+}
+
+// Get - the secret by its alias or name, and when it was fetched
+func (c *SecretsCache) Get(ctx context.Context, name string) (*Secret, time.Time, error) {
+	if err := c.ensureFresh(ctx); err != nil {
+		return nil, time.Time{}, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if s, ok := c.secrets[name]; ok {
+		return s, c.fetchedAt, nil
+	}
+	if s, ok := c.byName[name]; ok {
+		return s, c.fetchedAt, nil
+	}
+	return nil, c.fetchedAt, ErrSecretNotFound
+}
+
+// List - all the secrets sorted by their alias or name, and when they were fetched
+func (c *SecretsCache) List(ctx context.Context) ([]*Secret, time.Time, error) {
+	if err := c.ensureFresh(ctx); err != nil {
+		return nil, time.Time{}, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	keys := make([]string, 0, len(c.secrets))
+	for key := range c.secrets {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	secretRes := make([]*Secret, 0, len(keys))
+	for _, key := range keys {
+		secretRes = append(secretRes, c.secrets[key])
+	}
+	return secretRes, c.fetchedAt, nil
+}
+
+// Refresh - fetches the secrets now (or joins the fetch in flight), regardless of the ttl.
+// Returns the names (alias or name) of the changed secrets
+func (c *SecretsCache) Refresh(ctx context.Context) ([]string, time.Time, error) {
+	changed, err := c.fetch(ctx)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err != nil {
+		return nil, c.fetchedAt, err
+	}
+	return changed, c.fetchedAt, nil
+}
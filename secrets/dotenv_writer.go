@@ -0,0 +1,125 @@
+package secrets
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+const DefaultEnvFile = ".env"
+
+type DotenvSecretWriter struct {
+	// implements SecretWriter
+	zl         *zap.Logger
+	outputFile string
+	keyOptions EnvKeyOptions
+
+	fileMode os.FileMode
+	dirMode  os.FileMode
+	uid      int
+	gid      int
+}
+
+// WithFileMode - the mode of the written env file (regardless of the umask)
+func (dw *DotenvSecretWriter) WithFileMode(mode os.FileMode) *DotenvSecretWriter {
+	dw.fileMode = mode
+	return dw
+}
+
+// WithDirMode - the mode of the env file's folder (if created)
+func (dw *DotenvSecretWriter) WithDirMode(mode os.FileMode) *DotenvSecretWriter {
+	dw.dirMode = mode
+	return dw
+}
+
+// WithOwner - the uid and gid of the written env file. KeepOwner leaves them as the user running the process
+func (dw *DotenvSecretWriter) WithOwner(uid int, gid int) *DotenvSecretWriter {
+	dw.uid = uid
+	dw.gid = gid
+	return dw
+}
+
+func NewDotenvSecretWriter(
+	outputFile string,
+	keyOptions EnvKeyOptions,
+	zl *zap.Logger) *DotenvSecretWriter {
+	return &DotenvSecretWriter{
+		zl:         zl,
+		outputFile: outputFile,
+		keyOptions: keyOptions,
+		fileMode:   DefaultFileMode,
+		dirMode:    DefaultDirMode,
+		uid:        KeepOwner,
+		gid:        KeepOwner,
+	}
+}
+
+// DotenvValue - quotes the value for an env file. Backslashes, quotes, dollar signs, backticks and newlines are escaped
+func DotenvValue(value string) string {
+	replacer := strings.NewReplacer(
+		`\`, `\\`,
+		`"`, `\"`,
+		`$`, `\$`,
+		"`", "\\`",
+		"\n", `\n`,
+		"\r", `\r`,
+	)
+	return `"` + replacer.Replace(value) + `"`
+}
+
+// RenderDotenv - renders the environment variables as KEY="value" lines
+func RenderDotenv(envVars []EnvVar) string {
+	var sb strings.Builder
+	for _, v := range envVars {
+		sb.WriteString(v.Key)
+		sb.WriteString("=")
+		sb.WriteString(DotenvValue(v.Value))
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
+
+// WriteSecrets - writes all the secrets to a single env file, replacing it atomically.
+func (dw *DotenvSecretWriter) WriteSecrets(ctx context.Context, secretRes []*Secret) error {
+	envVars, err := EnvVars(secretRes, dw.keyOptions)
+	if err != nil {
+		dw.zl.Error("failed to map secrets to environment variables", zap.Error(err))
+		return err
+	}
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if err := dw.createOutputFolder(filepath.Dir(dw.outputFile)); err != nil {
+		dw.zl.Error("failed to create the env file folder", zap.String("file_path", dw.outputFile), zap.Error(err))
+		return err
+	}
+
+	if err := writeFileAtomic(dw.outputFile, []byte(RenderDotenv(envVars)), FilePermissions{Mode: dw.fileMode, UID: dw.uid, GID: dw.gid}); err != nil {
+		dw.zl.Error("failed to write the env file", zap.String("file_path", dw.outputFile), zap.Error(err))
+		return err
+	}
+
+	dw.zl.Info("wrote secrets to env file",
+		zap.String("file_path", dw.outputFile),
+		zap.Int("variables", len(envVars)),
+	)
+	return nil
+}
+
+// createOutputFolder - creates the env file's folder with the dir mode if it's missing
+func (dw *DotenvSecretWriter) createOutputFolder(outputFolder string) error {
+	if _, err := os.Stat(outputFolder); !os.IsNotExist(err) {
+		return err
+	}
+
+	dw.zl.Info("creating the output folder", zap.String("output_folder", outputFolder))
+	if err := os.MkdirAll(outputFolder, dw.dirMode); err != nil {
+		return err
+	}
+	return setPermissions(outputFolder, FilePermissions{Mode: dw.dirMode, UID: dw.uid, GID: dw.gid})
+}
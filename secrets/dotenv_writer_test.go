@@ -0,0 +1,87 @@
+package secrets_test
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"go.uber.org/zap/zaptest"
+
+	"github.com/daniel-cohen/secretsfetcher/secrets"
+)
+
+var _ = Describe("Writing secrets to an env file", func() {
+	var outputFolder string
+
+	BeforeEach(func() {
+		var err error
+		outputFolder, err = ioutil.TempDir("", "secretsfetcher")
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(outputFolder)
+	})
+
+	It("quotes and escapes the values", func() {
+		envFile := filepath.Join(outputFolder, secrets.DefaultEnvFile)
+		writer := secrets.NewDotenvSecretWriter(envFile, secrets.EnvKeyOptions{Prefix: "app/", Uppercase: true}, zaptest.NewLogger(GinkgoT())).
+			WithFileMode(0600)
+
+		Expect(writer.WriteSecrets(context.Background(), []*secrets.Secret{
+			{Name: "db/password", Content: `pa$$"word\`},
+			{Name: "db/user", Content: "`id`"},
+			{Name: "tls/key", Content: "line1\nline2\n"},
+		})).To(Succeed())
+
+		b, err := ioutil.ReadFile(envFile)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(b)).To(Equal(
+			`APP_DB_PASSWORD="pa\$\$\"word\\"` + "\n" +
+				"APP_DB_USER=\"\\`id\\`\"\n" +
+				`APP_TLS_KEY="line1\nline2\n"` + "\n"))
+
+		fi, err := os.Stat(envFile)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(fi.Mode().Perm()).To(Equal(os.FileMode(0600)))
+
+		// no temporary files are left behind:
+		files, err := ioutil.ReadDir(outputFolder)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(files).To(HaveLen(1))
+	})
+
+	It("creates a missing output folder with the dir mode", func() {
+		envFile := filepath.Join(outputFolder, "missing", "config", secrets.DefaultEnvFile)
+		writer := secrets.NewDotenvSecretWriter(envFile, secrets.EnvKeyOptions{Uppercase: true}, zaptest.NewLogger(GinkgoT())).
+			WithDirMode(0750)
+
+		Expect(writer.WriteSecrets(context.Background(), []*secrets.Secret{{Name: "db/password", Content: "value1"}})).To(Succeed())
+
+		b, err := ioutil.ReadFile(envFile)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(b)).To(Equal(`DB_PASSWORD="value1"` + "\n"))
+
+		fi, err := os.Stat(filepath.Dir(envFile))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(fi.Mode().Perm()).To(Equal(os.FileMode(0750)))
+	})
+
+	It("does not replace the env file when the names collide", func() {
+		envFile := filepath.Join(outputFolder, secrets.DefaultEnvFile)
+		Expect(ioutil.WriteFile(envFile, []byte("OLD=1\n"), 0600)).To(Succeed())
+
+		writer := secrets.NewDotenvSecretWriter(envFile, secrets.EnvKeyOptions{Uppercase: true}, zaptest.NewLogger(GinkgoT()))
+		Expect(writer.WriteSecrets(context.Background(), []*secrets.Secret{
+			{Name: "a/b", Content: "1"},
+			{Name: "A_B", Content: "2"},
+		})).NotTo(Succeed())
+
+		b, err := ioutil.ReadFile(envFile)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(b)).To(Equal("OLD=1\n"))
+	})
+})
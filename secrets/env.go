@@ -0,0 +1,167 @@
+package secrets
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// EnvKeyOptions - how secret names are turned into environment variable names
+type EnvKeyOptions struct {
+	Prefix    string // prepended to every key. E.g: APP_
+	Uppercase bool
+
+	// SlashReplacement - what slashes in the names are replaced with. E.g: "__" for nested names. Empty keeps the underscore
+	SlashReplacement string
+
+	// JSONKeys - json object secrets are expanded into a variable per top level field (NAME_FIELD) instead of a single variable
+	JSONKeys bool
+}
+
+// EnvVar - a single environment variable derived from a secret
+type EnvVar struct {
+	Key        string
+	Value      string
+	SecretName string
+}
+
+// EnvKey - the environment variable name for a secret (or json field) name.
+// Slashes are replaced with the SlashReplacement, then any character other than letters, digits and underscores with an underscore.
+func EnvKey(name string, opts EnvKeyOptions) string {
+	if opts.SlashReplacement != "" {
+		name = strings.ReplaceAll(name, "/", opts.SlashReplacement)
+	}
+
+	key := []rune(opts.Prefix + name)
+	for i, r := range key {
+		if !(r == '_' || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9')) {
+			key[i] = '_'
+		}
+	}
+
+	res := string(key)
+	if opts.Uppercase {
+		res = strings.ToUpper(res)
+	}
+
+	// names can't start with a digit:
+	if res != "" && res[0] >= '0' && res[0] <= '9' {
+		res = "_" + res
+	}
+	return res
+}
+
+// EnvVars - maps the secrets (or their json fields) to environment variables.
+// The secret alias is used as the name if set. Secrets mapped to the same key are rejected.
+func EnvVars(secretRes []*Secret, opts EnvKeyOptions) ([]EnvVar, error) {
+	var res []EnvVar
+	keys := map[string]string{}
+	add := func(name string, value string, secretName string) error {
+		key := EnvKey(name, opts)
+		if key == "" {
+			return fmt.Errorf("secret %s has an empty environment variable name", secretName)
+		}
+		if other, ok := keys[key]; ok {
+			return fmt.Errorf("secrets %s and %s are both mapped to the environment variable %s", other, secretName, key)
+		}
+		keys[key] = secretName
+		res = append(res, EnvVar{Key: key, Value: value, SecretName: secretName})
+		return nil
+	}
+
+	for _, s := range secretRes {
+		name := s.Name
+		if s.Alias != "" {
+			name = s.Alias
+		}
+
+		fields, ok := jsonFields(s.Content)
+		if !opts.JSONKeys || !ok {
+			if err := add(name, s.Content, s.Name); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		for _, field := range fields {
+			if err := add(name+"_"+field.name, field.value, s.Name); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return res, nil
+}
+
+type jsonField struct {
+	name  string
+	value string
+}
+
+// jsonFields - the top level fields of a json object sorted by name. Non string values are kept as json
+func jsonFields(content string) ([]jsonField, bool) {
+	var obj map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(content), &obj); err != nil || obj == nil {
+		return nil, false
+	}
+
+	var res []jsonField
+	for name, raw := range obj {
+		value := string(raw)
+		var s string
+		if err := json.Unmarshal(raw, &s); err == nil {
+			value = s
+		}
+		res = append(res, jsonField{name: name, value: value})
+	}
+
+	sort.Slice(res, func(i, j int) bool { return res[i].name < res[j].name })
+	return res, true
+}
+
+// MergeEnvOptions - how the secret variables are combined with the parent environment
+type MergeEnvOptions struct {
+	// Scrub - start from an empty environment, keeping only the Keep variables (e.g: PATH, HOME)
+	Scrub bool
+	Keep  []string
+
+	// Override - secrets replace existing variables with the same name instead of failing
+	Override bool
+}
+
+// MergeEnv - adds the secret variables to the environment (in os.Environ() format).
+// Secrets colliding with an existing variable are rejected unless Override is set.
+func MergeEnv(environ []string, envVars []EnvVar, opts MergeEnvOptions) ([]string, error) {
+	keep := map[string]bool{}
+	for _, k := range opts.Keep {
+		keep[k] = true
+	}
+
+	var res []string
+	existing := map[string]int{}
+	for _, kv := range environ {
+		key := strings.SplitN(kv, "=", 2)[0]
+		if opts.Scrub && !keep[key] {
+			continue
+		}
+		existing[key] = len(res)
+		res = append(res, kv)
+	}
+
+	for _, v := range envVars {
+		kv := v.Key + "=" + v.Value
+		i, ok := existing[v.Key]
+		if !ok {
+			res = append(res, kv)
+			continue
+		}
+
+		if !opts.Override {
+			return nil, fmt.Errorf("secret %s collides with the existing environment variable %s", v.SecretName, v.Key)
+		}
+		res[i] = kv
+	}
+
+	return res, nil
+}
@@ -0,0 +1,85 @@
+package secrets_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+
+	"github.com/daniel-cohen/secretsfetcher/secrets"
+)
+
+var _ = DescribeTable("Environment variable names",
+	func(name string, opts secrets.EnvKeyOptions, expected string) {
+		Expect(secrets.EnvKey(name, opts)).To(Equal(expected))
+	},
+	Entry("as is", "db_password", secrets.EnvKeyOptions{}, "db_password"),
+	Entry("uppercase", "db_password", secrets.EnvKeyOptions{Uppercase: true}, "DB_PASSWORD"),
+	Entry("slashes and dashes", "my-app/db/password", secrets.EnvKeyOptions{Uppercase: true}, "MY_APP_DB_PASSWORD"),
+	Entry("slash replacement", "my-app/db/password", secrets.EnvKeyOptions{Uppercase: true, SlashReplacement: "__"}, "MY_APP__DB__PASSWORD"),
+	Entry("invalid slash replacement", "db/password", secrets.EnvKeyOptions{SlashReplacement: "."}, "db_password"),
+	Entry("prefix", "password", secrets.EnvKeyOptions{Prefix: "app_", Uppercase: true}, "APP_PASSWORD"),
+	Entry("leading digit", "1password", secrets.EnvKeyOptions{}, "_1password"),
+)
+
+var _ = Describe("Mapping secrets to environment variables", func() {
+	It("uses the alias and expands json fields", func() {
+		envVars, err := secrets.EnvVars([]*secrets.Secret{
+			{Name: "arn:aws:secretsmanager:us-west-2:111122223333:secret:db-1a2b3c", Alias: "db", Content: `{"user": "admin", "port": 5432}`},
+			{Name: "my/plain", Content: "plain value"},
+		}, secrets.EnvKeyOptions{Uppercase: true, JSONKeys: true})
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(envVars).To(Equal([]secrets.EnvVar{
+			{Key: "DB_PORT", Value: "5432", SecretName: "arn:aws:secretsmanager:us-west-2:111122223333:secret:db-1a2b3c"},
+			{Key: "DB_USER", Value: "admin", SecretName: "arn:aws:secretsmanager:us-west-2:111122223333:secret:db-1a2b3c"},
+			{Key: "MY_PLAIN", Value: "plain value", SecretName: "my/plain"},
+		}))
+	})
+
+	It("keeps json secrets whole unless asked to expand them", func() {
+		envVars, err := secrets.EnvVars([]*secrets.Secret{{Name: "db", Content: `{"user": "admin"}`}}, secrets.EnvKeyOptions{})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(envVars).To(ConsistOf(secrets.EnvVar{Key: "db", Value: `{"user": "admin"}`, SecretName: "db"}))
+	})
+
+	It("rejects secrets mapped to the same variable", func() {
+		_, err := secrets.EnvVars([]*secrets.Secret{
+			{Name: "my/secret", Content: "1"},
+			{Name: "my-secret", Content: "2"},
+		}, secrets.EnvKeyOptions{})
+		Expect(err).To(MatchError(ContainSubstring("my_secret")))
+	})
+})
+
+var _ = Describe("Merging secrets into the environment", func() {
+	envVars := []secrets.EnvVar{{Key: "DB_PASSWORD", Value: "secret", SecretName: "db/password"}}
+
+	It("appends the secrets to the environment", func() {
+		env, err := secrets.MergeEnv([]string{"PATH=/bin", "HOME=/root"}, envVars, secrets.MergeEnvOptions{})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(env).To(Equal([]string{"PATH=/bin", "HOME=/root", "DB_PASSWORD=secret"}))
+	})
+
+	It("scrubs the environment except the kept variables", func() {
+		env, err := secrets.MergeEnv([]string{"PATH=/bin", "AWS_SECRET_ACCESS_KEY=x"}, envVars, secrets.MergeEnvOptions{Scrub: true, Keep: []string{"PATH"}})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(env).To(Equal([]string{"PATH=/bin", "DB_PASSWORD=secret"}))
+	})
+
+	It("rejects collisions with existing variables", func() {
+		_, err := secrets.MergeEnv([]string{"DB_PASSWORD=old"}, envVars, secrets.MergeEnvOptions{})
+		Expect(err).To(MatchError(ContainSubstring("DB_PASSWORD")))
+	})
+
+	It("overrides existing variables when asked to", func() {
+		env, err := secrets.MergeEnv([]string{"DB_PASSWORD=old", "PATH=/bin"}, envVars, secrets.MergeEnvOptions{Override: true})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(env).To(Equal([]string{"DB_PASSWORD=secret", "PATH=/bin"}))
+	})
+
+	It("ignores collisions with scrubbed variables", func() {
+		env, err := secrets.MergeEnv([]string{"DB_PASSWORD=old"}, envVars, secrets.MergeEnvOptions{Scrub: true})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(env).To(Equal([]string{"DB_PASSWORD=secret"}))
+	})
+})
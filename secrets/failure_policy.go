@@ -0,0 +1,30 @@
+package secrets
+
+import "fmt"
+
+// FailurePolicy - how to handle required objects which failed to be fetched
+type FailurePolicy string
+
+const (
+	// FailurePolicyFailFast - stop on the first failed object
+	FailurePolicyFailFast FailurePolicy = "failfast"
+	// FailurePolicyFailAtEnd - fetch everything and fail with all the failed objects
+	FailurePolicyFailAtEnd FailurePolicy = "failatend"
+	// FailurePolicyBestEffort - log failed objects and return whatever was fetched
+	FailurePolicyBestEffort FailurePolicy = "besteffort"
+
+	DefaultFailurePolicy = FailurePolicyFailAtEnd
+)
+
+// ParseFailurePolicy - an empty string returns the default policy
+func ParseFailurePolicy(s string) (FailurePolicy, error) {
+	switch FailurePolicy(s) {
+	case "":
+		return DefaultFailurePolicy, nil
+	case FailurePolicyFailFast, FailurePolicyFailAtEnd, FailurePolicyBestEffort:
+		return FailurePolicy(s), nil
+	}
+
+	return "", fmt.Errorf("unsupported failure policy %q. Supported values are: %s, %s, %s",
+		s, FailurePolicyFailFast, FailurePolicyFailAtEnd, FailurePolicyBestEffort)
+}
@@ -0,0 +1,163 @@
+package secrets
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+
+	"github.com/hashicorp/go-multierror"
+	"go.uber.org/zap"
+)
+
+// ObjectRef - the name of a manifest (or listed) object, and whether it may fail to be fetched
+type ObjectRef struct {
+	Name     string
+	Optional bool
+}
+
+// ObjectFetchError - a failure to fetch a single manifest (or listed) object
+type ObjectFetchError struct {
+	ObjectName string
+	ErrorCode  string // the provider's error code, if available (E.g: the aws error code)
+	Err        error
+}
+
+// NewObjectFetchError - the error code is taken from errors with an ErrorCode method (E.g: aws api errors)
+func NewObjectFetchError(objectName string, err error) *ObjectFetchError {
+	fe := &ObjectFetchError{
+		ObjectName: objectName,
+		Err:        err,
+	}
+
+	var coded interface{ ErrorCode() string }
+	if errors.As(err, &coded) {
+		fe.ErrorCode = coded.ErrorCode()
+	}
+
+	return fe
+}
+
+func (e *ObjectFetchError) Error() string {
+	if e.ErrorCode != "" {
+		return fmt.Sprintf("object %s: %s: %v", e.ObjectName, e.ErrorCode, e.Err)
+	}
+	return fmt.Sprintf("object %s: %v", e.ObjectName, e.Err)
+}
+
+func (e *ObjectFetchError) Unwrap() error {
+	return e.Err
+}
+
+// FetchObjects - fetches the objects in parallel with fetchOne (at most concurrency at a time), handling failures by the policy:
+// optional objects which fail are skipped, failfast cancels the fetches still running and fails with the first failed object (in order),
+// failatend fails with all the failed objects and besteffort skips them.
+// Objects still waiting for a slot when ctx is done aren't fetched, and the fetch fails with ctx's error.
+func FetchObjects(
+	ctx context.Context,
+	objects []ObjectRef,
+	concurrency int,
+	policy FailurePolicy,
+	fetchOne func(ctx context.Context, i int) (*Secret, error),
+	zl *zap.Logger) ([]*Secret, error) {
+	byObject, err := FetchObjectSecrets(ctx, objects, concurrency, policy, func(ctx context.Context, i int) ([]*Secret, error) {
+		secret, err := fetchOne(ctx, i)
+		if err != nil {
+			return nil, err
+		}
+		return []*Secret{secret}, nil
+	}, zl)
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]*Secret, 0, len(objects))
+	for _, objSecrets := range byObject {
+		res = append(res, objSecrets...)
+	}
+	return res, nil
+}
+
+// FetchObjectSecrets - like FetchObjects, for objects fetched as several secrets (E.g: with the fields extracted from them).
+// Returns the secrets of each object at its index, nil for the skipped ones
+func FetchObjectSecrets(
+	ctx context.Context,
+	objects []ObjectRef,
+	concurrency int,
+	policy FailurePolicy,
+	fetchOne func(ctx context.Context, i int) ([]*Secret, error),
+	zl *zap.Logger) ([][]*Secret, error) {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	fetchCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	res := make([][]*Secret, len(objects))
+	errs := make([]error, len(objects))
+	var failedFast atomic.Bool
+
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for i := range objects {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			select {
+			case sem <- struct{}{}:
+				defer func() { <-sem }()
+			case <-fetchCtx.Done():
+				errs[i] = fetchCtx.Err()
+				return
+			}
+
+			// both may be ready once a slot is released:
+			if err := fetchCtx.Err(); err != nil {
+				errs[i] = err
+				return
+			}
+
+			res[i], errs[i] = fetchOne(fetchCtx, i)
+			if errs[i] != nil && !objects[i].Optional && policy == FailurePolicyFailFast {
+				failedFast.Store(true)
+				cancel()
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	// Everything fails once the caller gave up:
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	var result *multierror.Error
+	for i, obj := range objects {
+		err := errs[i]
+		switch {
+		case err == nil:
+			continue
+		case failedFast.Load() && errors.Is(err, context.Canceled):
+			// cancelled by the failed object
+		case obj.Optional:
+			zl.Warn("skipping optional object which failed to be fetched", zap.String("objectName", obj.Name), zap.Error(err))
+		case policy == FailurePolicyBestEffort:
+			zl.Warn("skipping object which failed to be fetched", zap.String("objectName", obj.Name), zap.Error(err))
+		default:
+			objErr := NewObjectFetchError(obj.Name, err)
+			zl.Error("failed to fetch secret", zap.String("objectName", obj.Name), zap.String("errorCode", objErr.ErrorCode), zap.Error(err))
+			result = multierror.Append(result, objErr)
+			if policy == FailurePolicyFailFast {
+				return nil, result.ErrorOrNil()
+			}
+		}
+		res[i] = nil
+	}
+
+	if err := result.ErrorOrNil(); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
@@ -0,0 +1,104 @@
+package secrets_test
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"go.uber.org/zap/zaptest"
+
+	"github.com/daniel-cohen/secretsfetcher/secrets"
+)
+
+var _ = Describe("Fetching objects in parallel", func() {
+	var (
+		objects []secrets.ObjectRef
+		failing map[int]bool
+		fetched int32
+	)
+
+	BeforeEach(func() {
+		objects = []secrets.ObjectRef{{Name: "a"}, {Name: "b", Optional: true}, {Name: "c"}, {Name: "d"}}
+		failing = map[int]bool{}
+		fetched = 0
+	})
+
+	fetchOne := func(ctx context.Context, i int) (*secrets.Secret, error) {
+		atomic.AddInt32(&fetched, 1)
+		if failing[i] {
+			return nil, errors.New("access denied")
+		}
+		return &secrets.Secret{Name: objects[i].Name}, nil
+	}
+
+	fetch := func(policy secrets.FailurePolicy, concurrency int) ([]*secrets.Secret, error) {
+		return secrets.FetchObjects(context.Background(), objects, concurrency, policy, fetchOne, zaptest.NewLogger(GinkgoT()))
+	}
+
+	names := func(res []*secrets.Secret) []string {
+		var names []string
+		for _, s := range res {
+			names = append(names, s.Name)
+		}
+		return names
+	}
+
+	It("fetches all the objects in order", func() {
+		res, err := fetch(secrets.FailurePolicyFailAtEnd, 2)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(names(res)).To(Equal([]string{"a", "b", "c", "d"}))
+	})
+
+	It("skips optional objects which failed", func() {
+		failing[1] = true
+		res, err := fetch(secrets.FailurePolicyFailFast, 2)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(names(res)).To(Equal([]string{"a", "c", "d"}))
+	})
+
+	It("fails at the end with all the failed objects", func() {
+		failing[0] = true
+		failing[3] = true
+		_, err := fetch(secrets.FailurePolicyFailAtEnd, 1)
+		Expect(err).To(MatchError(ContainSubstring("object a: access denied")))
+		Expect(err).To(MatchError(ContainSubstring("object d: access denied")))
+		Expect(fetched).To(BeEquivalentTo(4))
+	})
+
+	It("stops on the first failed object with failfast", func() {
+		failing[0] = true
+		_, err := fetch(secrets.FailurePolicyFailFast, 1)
+		Expect(err).To(MatchError(ContainSubstring("object a: access denied")))
+		Expect(err).NotTo(MatchError(ContainSubstring("canceled")))
+		Expect(fetched).To(BeNumerically("<", 4))
+	})
+
+	It("skips the failed objects with besteffort", func() {
+		failing[2] = true
+		res, err := fetch(secrets.FailurePolicyBestEffort, 2)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(names(res)).To(Equal([]string{"a", "b", "d"}))
+	})
+
+	It("doesn't wait for a slot once the context is done", func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		slow := func(ctx context.Context, i int) (*secrets.Secret, error) {
+			atomic.AddInt32(&fetched, 1)
+			cancel()
+			<-ctx.Done()
+			return nil, ctx.Err()
+		}
+
+		done := make(chan error, 1)
+		go func() {
+			_, err := secrets.FetchObjects(ctx, objects, 1, secrets.FailurePolicyBestEffort, slow, zaptest.NewLogger(GinkgoT()))
+			done <- err
+		}()
+
+		Eventually(done, time.Second).Should(Receive(MatchError(context.Canceled)))
+		Expect(fetched).To(BeEquivalentTo(1))
+	})
+})
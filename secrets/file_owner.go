@@ -0,0 +1,17 @@
+//go:build !windows
+
+package secrets
+
+import (
+	"os"
+	"syscall"
+)
+
+// fileOwner - the uid and gid of the file, if the platform reports them
+func fileOwner(fi os.FileInfo) (int, int, bool) {
+	stat, ok := fi.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, 0, false
+	}
+	return int(stat.Uid), int(stat.Gid), true
+}
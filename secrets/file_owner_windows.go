@@ -0,0 +1,8 @@
+package secrets
+
+import "os"
+
+// fileOwner - windows files have no uid/gid
+func fileOwner(fi os.FileInfo) (int, int, bool) {
+	return 0, 0, false
+}
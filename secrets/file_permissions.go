@@ -0,0 +1,54 @@
+package secrets
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+)
+
+const (
+	DefaultFileMode os.FileMode = 0600
+	DefaultDirMode  os.FileMode = 0700
+
+	// KeepOwner - leaves the uid/gid of written files as the user running the process
+	KeepOwner = -1
+)
+
+// FilePermissions - per secret overrides of the writer's file mode and ownership
+type FilePermissions struct {
+	Mode os.FileMode // 0 keeps the writer's file mode
+	UID  int         // KeepOwner keeps the writer's uid
+	GID  int         // KeepOwner keeps the writer's gid
+}
+
+// ParseFileMode - parses an octal file mode string. E.g: "0640" or "640"
+func ParseFileMode(s string) (os.FileMode, error) {
+	mode, err := strconv.ParseUint(s, 8, 32)
+	if err != nil || mode > uint64(os.ModePerm) {
+		return 0, fmt.Errorf("invalid file mode %q: expected octal permissions such as 0640", s)
+	}
+	return os.FileMode(mode), nil
+}
+
+// ObjectFilePermissions - a manifest object's file mode (octal) and owner overrides, nil if none are set
+func ObjectFilePermissions(fileMode string, uid *int, gid *int) (*FilePermissions, error) {
+	if fileMode == "" && uid == nil && gid == nil {
+		return nil, nil
+	}
+
+	perms := &FilePermissions{UID: KeepOwner, GID: KeepOwner}
+	if fileMode != "" {
+		mode, err := ParseFileMode(fileMode)
+		if err != nil {
+			return nil, err
+		}
+		perms.Mode = mode
+	}
+	if uid != nil {
+		perms.UID = *uid
+	}
+	if gid != nil {
+		perms.GID = *gid
+	}
+	return perms, nil
+}
@@ -0,0 +1,194 @@
+package gcp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/daniel-cohen/secretsfetcher/metrics"
+	"go.uber.org/zap"
+)
+
+const (
+	providerName = "gcpsecretmanager"
+
+	metadataTokenPath = "/computeMetadata/v1/instance/service-accounts/default/token"
+
+	// metadata server tokens are requested again once less than this is left of them:
+	tokenRenewMargin = time.Minute
+)
+
+// GCPError - a failed secret manager request
+type GCPError struct {
+	StatusCode int
+	Status     string // E.g: NOT_FOUND
+	Message    string
+}
+
+func (e *GCPError) Error() string {
+	return fmt.Sprintf("secret manager request failed: %d %s: %s", e.StatusCode, e.Status, e.Message)
+}
+
+// IsNotFound - true if the secret (or version) doesn't exist
+func IsNotFound(err error) bool {
+	var ge *GCPError
+	return errors.As(err, &ge) && ge.StatusCode == http.StatusNotFound
+}
+
+// errorCode - the error status of a failed request for the metrics. Empty if the request succeeded
+func errorCode(err error) string {
+	var ge *GCPError
+	switch {
+	case err == nil:
+		return ""
+	case errors.As(err, &ge):
+		return ge.Status
+	case errors.Is(err, context.DeadlineExceeded):
+		return metrics.ErrorCodeTimeout
+	case errors.Is(err, context.Canceled):
+		return metrics.ErrorCodeCanceled
+	default:
+		return metrics.ErrorCodeUnknown
+	}
+}
+
+// gcpClient - a minimal secret manager rest api client, authenticated with an oauth2 access token
+type gcpClient struct {
+	zl         *zap.Logger
+	cfg        *GCPConfig
+	httpClient *http.Client
+
+	// a timeout for each request. 0 disables it
+	requestTimeout time.Duration
+
+	mu          sync.Mutex
+	token       string
+	tokenExpiry time.Time
+}
+
+func newGCPClient(cfg *GCPConfig, zl *zap.Logger) *gcpClient {
+	return &gcpClient{
+		zl:         zl,
+		cfg:        cfg,
+		httpClient: &http.Client{},
+	}
+}
+
+// accessToken - the configured access token, or a metadata server token (requested again before it expires)
+func (c *gcpClient) accessToken(ctx context.Context) (string, error) {
+	if c.cfg.AccessToken != "" {
+		return c.cfg.AccessToken, nil
+	}
+	if c.cfg.AccessTokenPath != "" {
+		b, err := os.ReadFile(c.cfg.AccessTokenPath)
+		if err != nil {
+			return "", fmt.Errorf("failed to read the access token: %w", err)
+		}
+		return strings.TrimSpace(string(b)), nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.token != "" && time.Until(c.tokenExpiry) > tokenRenewMargin {
+		return c.token, nil
+	}
+
+	endpoint := c.cfg.MetadataEndpoint
+	if endpoint == "" {
+		endpoint = DefaultMetadataEndpoint
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(endpoint, "/")+metadataTokenPath, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Metadata-Flavor", "Google")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to get an access token from the metadata server: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to get an access token from the metadata server: %s", resp.Status)
+	}
+
+	var tokenResp struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"` // seconds
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&tokenResp); err != nil {
+		return "", err
+	}
+
+	c.token = tokenResp.AccessToken
+	c.tokenExpiry = time.Now().Add(time.Duration(tokenResp.ExpiresIn) * time.Second)
+	c.zl.Debug("got an access token from the metadata server", zap.Int("expiresInSeconds", tokenResp.ExpiresIn))
+	return c.token, nil
+}
+
+// get - sends an authenticated GET request to the api path (without /v1), decoding the json response into out
+func (c *gcpClient) get(ctx context.Context, operation string, path string, query url.Values, out interface{}) (err error) {
+	start := time.Now()
+	defer func() {
+		metrics.ObserveProviderCall(providerName, operation, time.Since(start), errorCode(err))
+	}()
+
+	if c.requestTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.requestTimeout)
+		defer cancel()
+	}
+
+	token, err := c.accessToken(ctx)
+	if err != nil {
+		return err
+	}
+
+	endpoint := c.cfg.Endpoint
+	if endpoint == "" {
+		endpoint = DefaultEndpoint
+	}
+	u := strings.TrimSuffix(endpoint, "/") + "/v1/" + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		ge := &GCPError{StatusCode: resp.StatusCode, Status: http.StatusText(resp.StatusCode)}
+		var errResp struct {
+			Error struct {
+				Message string `json:"message"`
+				Status  string `json:"status"`
+			} `json:"error"`
+		}
+		if json.NewDecoder(resp.Body).Decode(&errResp) == nil && errResp.Error.Status != "" {
+			ge.Status = errResp.Error.Status
+			ge.Message = errResp.Error.Message
+		}
+		return ge
+	}
+
+	return json.NewDecoder(resp.Body).Decode(out)
+}
@@ -0,0 +1,41 @@
+package gcp
+
+import "time"
+
+const (
+	DefaultEndpoint         = "https://secretmanager.googleapis.com"
+	DefaultMetadataEndpoint = "http://metadata.google.internal"
+	DefaultVersion          = "latest"
+	DefaultConcurrency      = 10
+	DefaultTimeout          = 5 * time.Minute
+	DefaultRequestTimeout   = 30 * time.Second
+)
+
+type GCPConfig struct {
+	// the default project of the secrets (id or number)
+	Project string
+
+	// list mode: fetch all the project's secrets with the labels and name prefix
+	LabelFilters map[string]string
+	PrefixFilter string
+
+	// an oauth2 access token, or a file to read it from. By default tokens are requested from the metadata server (workload identity)
+	AccessToken     string
+	AccessTokenPath string
+
+	// the secret manager api and metadata server endpoints. Overridden in tests
+	Endpoint         string
+	MetadataEndpoint string
+
+	PathTranslation string
+
+	// failfast, failatend or besteffort. Empty uses the shared failure policy
+	FailurePolicy string
+
+	// the maximum number of secrets fetched in parallel
+	Concurrency int
+
+	// the overall timeout for fetching and writing the secrets, and the timeout for each request. 0 disables them
+	Timeout        time.Duration
+	RequestTimeout time.Duration
+}
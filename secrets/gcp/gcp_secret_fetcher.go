@@ -0,0 +1,67 @@
+package gcp
+
+import (
+	"context"
+
+	"github.com/daniel-cohen/secretsfetcher/secrets"
+	"go.uber.org/zap"
+)
+
+type ManifestSecretsFetcher struct {
+	// implements secrets.SecretsFetcher
+	zl       *zap.Logger
+	provider *GCPSecretsProvider
+	manifest *SecretManifest
+}
+
+func NewManifestSecretFetcher(
+	provider *GCPSecretsProvider,
+	manifest *SecretManifest,
+	zl *zap.Logger) *ManifestSecretsFetcher {
+	return &ManifestSecretsFetcher{
+		zl:       zl,
+		provider: provider,
+		manifest: manifest,
+	}
+}
+
+func (msf *ManifestSecretsFetcher) Fetch(ctx context.Context) ([]*secrets.Secret, error) {
+	if err := msf.manifest.Validate(); err != nil {
+		msf.zl.Error("invalid manifest", zap.Error(err))
+		return nil, err
+	}
+
+	secretRes, err := msf.provider.FetchSecrets(ctx, msf.manifest.SecretObjects)
+	if err != nil {
+		return nil, err
+	}
+
+	msf.zl.Info("fetched gcp secrets", zap.Int("secrets", len(secretRes)))
+	return secretRes, nil
+}
+
+// ListSecretFetcher - fetches all the project's secrets matching the prefix and labels
+type ListSecretFetcher struct {
+	// implements secrets.SecretsFetcher
+	zl           *zap.Logger
+	provider     *GCPSecretsProvider
+	prefix       string
+	labelFilters map[string]string
+}
+
+func NewListSecretFetcher(
+	provider *GCPSecretsProvider,
+	prefix string,
+	labelFilters map[string]string,
+	zl *zap.Logger) *ListSecretFetcher {
+	return &ListSecretFetcher{
+		zl:           zl,
+		provider:     provider,
+		prefix:       prefix,
+		labelFilters: labelFilters,
+	}
+}
+
+func (lsf *ListSecretFetcher) Fetch(ctx context.Context) ([]*secrets.Secret, error) {
+	return lsf.provider.FetchAllSecrets(ctx, lsf.prefix, lsf.labelFilters)
+}
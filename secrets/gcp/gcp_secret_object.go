@@ -0,0 +1,65 @@
+package gcp
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/daniel-cohen/secretsfetcher/secrets"
+)
+
+type GCPSecretObject struct {
+	ObjectName    string // the secret id, or its resource name (projects/<project>/secrets/<secret>)
+	ObjectVersion string // an optional version number or alias to pin, defaults to latest
+	ObjectAlias   string // optional output file name, defaults to the secret id
+
+	// an optional project overriding the manifest's (ignored for resource names)
+	Project string
+
+	// optional objects which fail to be fetched are skipped
+	Optional bool
+
+	// optional overrides of the writer's file mode (octal. E.g: "0400") and owner
+	FileMode string
+	UID      *int
+	GID      *int
+}
+
+// FilePermissions - the object's file mode and owner overrides, nil if none are set
+func (o *GCPSecretObject) FilePermissions() (*secrets.FilePermissions, error) {
+	perms, err := secrets.ObjectFilePermissions(o.FileMode, o.UID, o.GID)
+	if err != nil {
+		return nil, fmt.Errorf("object %s: %w", o.ObjectName, err)
+	}
+	return perms, nil
+}
+
+// resourceName - the secret's project and id. Resource names take precedence over the project
+func (o *GCPSecretObject) resourceName(defaultProject string) (string, string, error) {
+	if strings.HasPrefix(o.ObjectName, "projects/") {
+		parts := strings.Split(o.ObjectName, "/")
+		if len(parts) != 4 || parts[2] != "secrets" || parts[1] == "" || parts[3] == "" {
+			return "", "", fmt.Errorf("invalid secret resource name %q: expected projects/<project>/secrets/<secret>", o.ObjectName)
+		}
+		return parts[1], parts[3], nil
+	}
+
+	project := o.Project
+	if project == "" {
+		project = defaultProject
+	}
+	if project == "" {
+		return "", "", fmt.Errorf("object %s: no project set", o.ObjectName)
+	}
+	return project, o.ObjectName, nil
+}
+
+// outputName - the alias or secret id the object is written as
+func (o *GCPSecretObject) outputName() string {
+	if o.ObjectAlias != "" {
+		return o.ObjectAlias
+	}
+	if i := strings.LastIndex(o.ObjectName, "/"); i >= 0 {
+		return o.ObjectName[i+1:]
+	}
+	return o.ObjectName
+}
@@ -0,0 +1,233 @@
+package gcp
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"hash/crc32"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/daniel-cohen/secretsfetcher/secrets"
+	"go.uber.org/zap"
+)
+
+// the maximum page size of secrets.list
+const listPageSize = 250
+
+var crc32cTable = crc32.MakeTable(crc32.Castagnoli)
+
+type accessResponse struct {
+	Name    string `json:"name"` // projects/<project number>/secrets/<secret>/versions/<version>
+	Payload struct {
+		Data       string `json:"data"`       // base64
+		DataCrc32c string `json:"dataCrc32c"` // an int64 as a string
+	} `json:"payload"`
+}
+
+type listResponse struct {
+	Secrets []struct {
+		Name   string            `json:"name"`
+		Labels map[string]string `json:"labels"`
+	} `json:"secrets"`
+	NextPageToken string `json:"nextPageToken"`
+}
+
+type GCPSecretsProvider struct {
+	zl      *zap.Logger
+	client  *gcpClient
+	project string
+
+	// the maximum number of secrets fetched in parallel
+	concurrency int
+
+	failurePolicy secrets.FailurePolicy
+}
+
+// WithFailurePolicy - sets how secrets which failed to be fetched are handled
+func (p *GCPSecretsProvider) WithFailurePolicy(policy secrets.FailurePolicy) *GCPSecretsProvider {
+	p.failurePolicy = policy
+	return p
+}
+
+// WithConcurrency - the maximum number of secrets fetched in parallel
+func (p *GCPSecretsProvider) WithConcurrency(concurrency int) *GCPSecretsProvider {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	p.concurrency = concurrency
+	return p
+}
+
+// WithRequestTimeout - the timeout for each secret manager request. 0 disables it
+func (p *GCPSecretsProvider) WithRequestTimeout(timeout time.Duration) *GCPSecretsProvider {
+	p.client.requestTimeout = timeout
+	return p
+}
+
+// WithProject - the default project of the secrets
+func (p *GCPSecretsProvider) WithProject(project string) *GCPSecretsProvider {
+	p.project = project
+	return p
+}
+
+func NewGCPSecretsProvider(cfg *GCPConfig, zl *zap.Logger) *GCPSecretsProvider {
+	return &GCPSecretsProvider{
+		zl:            zl,
+		client:        newGCPClient(cfg, zl),
+		project:       cfg.Project,
+		concurrency:   DefaultConcurrency,
+		failurePolicy: secrets.DefaultFailurePolicy,
+	}
+}
+
+// verifyCRC32C - checks the payload against its crc32c checksum (when the api returned one)
+func verifyCRC32C(data []byte, checksum string) error {
+	if checksum == "" {
+		return nil
+	}
+
+	expected, err := strconv.ParseUint(checksum, 10, 32)
+	if err != nil {
+		return fmt.Errorf("invalid crc32c checksum %q", checksum)
+	}
+	if actual := crc32.Checksum(data, crc32cTable); actual != uint32(expected) {
+		return fmt.Errorf("data corrupted: crc32c %d doesn't match the checksum %d", actual, expected)
+	}
+	return nil
+}
+
+// fetchSecret - accesses the object's secret version, with its output options set
+func (p *GCPSecretsProvider) fetchSecret(ctx context.Context, obj *GCPSecretObject) (*secrets.Secret, error) {
+	project, secretID, err := obj.resourceName(p.project)
+	if err != nil {
+		return nil, err
+	}
+
+	version := obj.ObjectVersion
+	if version == "" {
+		version = DefaultVersion
+	}
+
+	var resp accessResponse
+	path := "projects/" + url.PathEscape(project) + "/secrets/" + url.PathEscape(secretID) + "/versions/" + url.PathEscape(version) + ":access"
+	if err := p.client.get(ctx, "AccessSecretVersion", path, nil, &resp); err != nil {
+		return nil, fmt.Errorf("failed to access %s: %w", obj.ObjectName, err)
+	}
+
+	data, err := base64.StdEncoding.DecodeString(resp.Payload.Data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode %s: %w", obj.ObjectName, err)
+	}
+	if err := verifyCRC32C(data, resp.Payload.DataCrc32c); err != nil {
+		return nil, fmt.Errorf("secret %s: %w", obj.ObjectName, err)
+	}
+
+	perms, err := obj.FilePermissions()
+	if err != nil {
+		return nil, err
+	}
+
+	// the version number the alias (e.g: latest) resolved to:
+	resolvedVersion := version
+	if i := strings.LastIndex(resp.Name, "/versions/"); i >= 0 {
+		resolvedVersion = resp.Name[i+len("/versions/"):]
+	}
+
+	return &secrets.Secret{
+		Name:        secretID,
+		Content:     string(data),
+		Alias:       obj.ObjectAlias,
+		Permissions: perms,
+		Version:     resolvedVersion,
+	}, nil
+}
+
+// FetchSecrets - fetches the objects' secrets in parallel, handling the failed ones by the failure policy
+func (p *GCPSecretsProvider) FetchSecrets(ctx context.Context, objs []*GCPSecretObject) ([]*secrets.Secret, error) {
+	refs := make([]secrets.ObjectRef, len(objs))
+	for i, obj := range objs {
+		refs[i] = secrets.ObjectRef{Name: obj.ObjectName, Optional: obj.Optional}
+	}
+
+	return secrets.FetchObjects(ctx, refs, p.concurrency, p.failurePolicy, func(ctx context.Context, i int) (*secrets.Secret, error) {
+		return p.fetchSecret(ctx, objs[i])
+	}, p.zl)
+}
+
+// labelsFilter - the list filter matching all the labels. E.g: labels.env=prod AND labels.app=my-app
+func labelsFilter(labels map[string]string) string {
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	terms := make([]string, 0, len(keys))
+	for _, k := range keys {
+		terms = append(terms, fmt.Sprintf("labels.%s=%s", k, labels[k]))
+	}
+	return strings.Join(terms, " AND ")
+}
+
+// listSecrets - the resource names of the project's secrets with all the labels and the secret id prefix
+func (p *GCPSecretsProvider) listSecrets(ctx context.Context, prefix string, labels map[string]string) ([]string, error) {
+	if p.project == "" {
+		return nil, fmt.Errorf("no project set")
+	}
+
+	query := url.Values{"pageSize": {strconv.Itoa(listPageSize)}}
+	if filter := labelsFilter(labels); filter != "" {
+		query.Set("filter", filter)
+	}
+
+	var names []string
+	for {
+		var resp listResponse
+		if err := p.client.get(ctx, "ListSecrets", "projects/"+url.PathEscape(p.project)+"/secrets", query, &resp); err != nil {
+			return nil, err
+		}
+
+		for _, s := range resp.Secrets {
+			// the filter is applied by the api, but double check it (and the prefix, which it doesn't support):
+			secretID := s.Name[strings.LastIndex(s.Name, "/")+1:]
+			if !strings.HasPrefix(secretID, prefix) || !hasLabels(s.Labels, labels) {
+				continue
+			}
+			names = append(names, s.Name)
+		}
+
+		if resp.NextPageToken == "" {
+			return names, nil
+		}
+		query.Set("pageToken", resp.NextPageToken)
+	}
+}
+
+func hasLabels(secretLabels map[string]string, labels map[string]string) bool {
+	for k, v := range labels {
+		if secretLabels[k] != v {
+			return false
+		}
+	}
+	return true
+}
+
+// FetchAllSecrets - fetches the latest version of all the project's secrets with the secret id prefix and all the labels
+func (p *GCPSecretsProvider) FetchAllSecrets(ctx context.Context, prefix string, labels map[string]string) ([]*secrets.Secret, error) {
+	names, err := p.listSecrets(ctx, prefix, labels)
+	if err != nil {
+		p.zl.Error("failed to list secrets", zap.String("project", p.project), zap.Error(err))
+		return nil, err
+	}
+	p.zl.Info("listed secrets", zap.String("project", p.project), zap.Int("secrets", len(names)))
+
+	objs := make([]*GCPSecretObject, 0, len(names))
+	for _, name := range names {
+		objs = append(objs, &GCPSecretObject{ObjectName: name})
+	}
+	return p.FetchSecrets(ctx, objs)
+}
@@ -0,0 +1,300 @@
+package gcp
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"hash/crc32"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"go.uber.org/zap/zaptest"
+
+	"github.com/daniel-cohen/secretsfetcher/secrets"
+)
+
+const fakeToken = "ya29.fake-token"
+
+type fakeSecret struct {
+	labels   map[string]string
+	versions []string
+}
+
+// fakeSecretManager - the secret manager access and list apis, and the metadata server token endpoint
+type fakeSecretManager struct {
+	mu sync.Mutex
+	// the secrets of each project
+	projects map[string]map[string]*fakeSecret
+
+	pageSize      int
+	corrupted     bool
+	tokenRequests int32
+	filters       []string
+}
+
+func (f *fakeSecretManager) writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+func (f *fakeSecretManager) writeError(w http.ResponseWriter, status int, code string, message string) {
+	f.writeJSON(w, status, map[string]interface{}{
+		"error": map[string]interface{}{"code": status, "message": message, "status": code},
+	})
+}
+
+func (f *fakeSecretManager) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if r.URL.Path == metadataTokenPath {
+		if r.Header.Get("Metadata-Flavor") != "Google" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		atomic.AddInt32(&f.tokenRequests, 1)
+		f.writeJSON(w, http.StatusOK, map[string]interface{}{"access_token": fakeToken, "expires_in": 3600, "token_type": "Bearer"})
+		return
+	}
+
+	if r.Header.Get("Authorization") != "Bearer "+fakeToken {
+		f.writeError(w, http.StatusUnauthorized, "UNAUTHENTICATED", "invalid authentication credentials")
+		return
+	}
+
+	// /v1/projects/<project>/secrets[/<secret>/versions/<version>:access]
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/v1/"), "/")
+	if len(parts) < 3 || parts[0] != "projects" || parts[2] != "secrets" {
+		f.writeError(w, http.StatusNotFound, "NOT_FOUND", "not found")
+		return
+	}
+	project := f.projects[parts[1]]
+
+	if len(parts) == 3 {
+		f.list(w, r, parts[1], project)
+		return
+	}
+
+	secret, ok := project[parts[3]]
+	if len(parts) != 6 || !ok || !strings.HasSuffix(parts[5], ":access") {
+		f.writeError(w, http.StatusNotFound, "NOT_FOUND", "Secret ["+r.URL.Path+"] not found")
+		return
+	}
+
+	version := len(secret.versions)
+	if v := strings.TrimSuffix(parts[5], ":access"); v != "latest" {
+		version, _ = strconv.Atoi(v)
+	}
+	if version < 1 || version > len(secret.versions) {
+		f.writeError(w, http.StatusNotFound, "NOT_FOUND", "Secret version not found")
+		return
+	}
+
+	data := []byte(secret.versions[version-1])
+	checksum := crc32.Checksum(data, crc32.MakeTable(crc32.Castagnoli))
+	if f.corrupted {
+		checksum++
+	}
+	f.writeJSON(w, http.StatusOK, map[string]interface{}{
+		"name": "projects/123456/secrets/" + parts[3] + "/versions/" + strconv.Itoa(version),
+		"payload": map[string]interface{}{
+			"data":       base64.StdEncoding.EncodeToString(data),
+			"dataCrc32c": strconv.FormatUint(uint64(checksum), 10),
+		},
+	})
+}
+
+// list - pages through the project's secrets matching the labels filter
+func (f *fakeSecretManager) list(w http.ResponseWriter, r *http.Request, projectID string, project map[string]*fakeSecret) {
+	filter := r.URL.Query().Get("filter")
+	f.filters = append(f.filters, filter)
+
+	labels := map[string]string{}
+	if filter != "" {
+		for _, term := range strings.Split(filter, " AND ") {
+			kv := strings.SplitN(strings.TrimPrefix(term, "labels."), "=", 2)
+			labels[kv[0]] = kv[1]
+		}
+	}
+
+	var names []string
+	for name, secret := range project {
+		if hasLabels(secret.labels, labels) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	start, _ := strconv.Atoi(r.URL.Query().Get("pageToken"))
+	end := len(names)
+	nextPageToken := ""
+	if f.pageSize > 0 && start+f.pageSize < end {
+		end = start + f.pageSize
+		nextPageToken = strconv.Itoa(end)
+	}
+
+	res := []map[string]interface{}{}
+	for _, name := range names[start:end] {
+		res = append(res, map[string]interface{}{
+			"name":   "projects/" + projectID + "/secrets/" + name,
+			"labels": project[name].labels,
+		})
+	}
+	f.writeJSON(w, http.StatusOK, map[string]interface{}{"secrets": res, "nextPageToken": nextPageToken})
+}
+
+var _ = Describe("Fetching gcp secrets", func() {
+	var (
+		secretManager *fakeSecretManager
+		server        *httptest.Server
+		cfg           *GCPConfig
+	)
+
+	BeforeEach(func() {
+		secretManager = &fakeSecretManager{projects: map[string]map[string]*fakeSecret{
+			"my-project": {
+				"db-password":  {labels: map[string]string{"app": "my-app", "env": "prod"}, versions: []string{"old", "rotated"}},
+				"api-key":      {labels: map[string]string{"app": "my-app", "env": "prod"}, versions: []string{"api-key"}},
+				"db-staging":   {labels: map[string]string{"app": "my-app", "env": "staging"}, versions: []string{"staging"}},
+				"other-secret": {labels: map[string]string{"app": "other-app"}, versions: []string{"other"}},
+			},
+			"other-project": {
+				"db-password": {versions: []string{"other-project"}},
+			},
+		}}
+		server = httptest.NewServer(secretManager)
+		cfg = &GCPConfig{Project: "my-project", AccessToken: fakeToken, Endpoint: server.URL, MetadataEndpoint: server.URL}
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	newProvider := func() *GCPSecretsProvider {
+		return NewGCPSecretsProvider(cfg, zaptest.NewLogger(GinkgoT()))
+	}
+
+	It("fetches the latest version of a secret", func() {
+		res, err := newProvider().FetchSecrets(context.Background(), []*GCPSecretObject{{ObjectName: "db-password"}})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(res).To(HaveLen(1))
+		Expect(res[0].Name).To(Equal("db-password"))
+		Expect(res[0].Content).To(Equal("rotated"))
+		Expect(res[0].Version).To(Equal("2"))
+	})
+
+	It("fetches a pinned version from another project", func() {
+		res, err := newProvider().FetchSecrets(context.Background(), []*GCPSecretObject{
+			{ObjectName: "db-password", ObjectVersion: "1", ObjectAlias: "old-password", FileMode: "0400"},
+			{ObjectName: "projects/other-project/secrets/db-password", ObjectAlias: "other-password"},
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(res[0].Content).To(Equal("old"))
+		Expect(res[0].Alias).To(Equal("old-password"))
+		Expect(res[0].Version).To(Equal("1"))
+		Expect(res[0].Permissions.Mode).To(BeEquivalentTo(0400))
+		Expect(res[1].Content).To(Equal("other-project"))
+	})
+
+	It("fails on corrupted data", func() {
+		secretManager.corrupted = true
+		_, err := newProvider().FetchSecrets(context.Background(), []*GCPSecretObject{{ObjectName: "db-password"}})
+		Expect(err).To(MatchError(ContainSubstring("data corrupted")))
+	})
+
+	It("fails on missing secrets and versions unless optional", func() {
+		provider := newProvider()
+
+		_, err := provider.FetchSecrets(context.Background(), []*GCPSecretObject{
+			{ObjectName: "db-password"},
+			{ObjectName: "missing"},
+			{ObjectName: "api-key", ObjectVersion: "5"},
+		})
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("missing"))
+		Expect(err.Error()).To(ContainSubstring("NOT_FOUND"))
+		Expect(err.Error()).To(ContainSubstring("api-key"))
+
+		res, err := provider.FetchSecrets(context.Background(), []*GCPSecretObject{
+			{ObjectName: "db-password"},
+			{ObjectName: "missing", Optional: true},
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(res).To(HaveLen(1))
+	})
+
+	It("fails with an invalid token", func() {
+		cfg.AccessToken = "invalid"
+		_, err := newProvider().FetchSecrets(context.Background(), []*GCPSecretObject{{ObjectName: "db-password"}})
+		Expect(err).To(MatchError(ContainSubstring("UNAUTHENTICATED")))
+		Expect(IsNotFound(err)).To(BeFalse())
+	})
+
+	It("lists and fetches all the secrets with the labels and prefix over several pages", func() {
+		secretManager.pageSize = 1
+		res, err := newProvider().FetchAllSecrets(context.Background(), "db-", map[string]string{"app": "my-app", "env": "prod"})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(res).To(HaveLen(1))
+		Expect(res[0].Name).To(Equal("db-password"))
+		Expect(res[0].Content).To(Equal("rotated"))
+		Expect(secretManager.filters).To(HaveLen(2))
+		Expect(secretManager.filters[0]).To(Equal("labels.app=my-app AND labels.env=prod"))
+
+		res, err = newProvider().FetchAllSecrets(context.Background(), "", map[string]string{"app": "my-app"})
+		Expect(err).NotTo(HaveOccurred())
+
+		var names []string
+		for _, s := range res {
+			names = append(names, s.Name)
+		}
+		Expect(names).To(Equal([]string{"api-key", "db-password", "db-staging"}))
+	})
+
+	Describe("access tokens", func() {
+		It("requests a token from the metadata server once while it is valid", func() {
+			cfg.AccessToken = ""
+			provider := newProvider()
+			for i := 0; i < 2; i++ {
+				_, err := provider.FetchSecrets(context.Background(), []*GCPSecretObject{{ObjectName: "db-password"}})
+				Expect(err).NotTo(HaveOccurred())
+			}
+			Expect(atomic.LoadInt32(&secretManager.tokenRequests)).To(BeEquivalentTo(1))
+		})
+
+		It("reads the token from a file", func() {
+			tmpFolder, err := ioutil.TempDir("", "secretsfetcher")
+			Expect(err).NotTo(HaveOccurred())
+			defer os.RemoveAll(tmpFolder)
+
+			cfg.AccessToken = ""
+			cfg.AccessTokenPath = filepath.Join(tmpFolder, "token")
+			Expect(ioutil.WriteFile(cfg.AccessTokenPath, []byte(fakeToken+"\n"), 0600)).To(Succeed())
+
+			_, err = newProvider().FetchSecrets(context.Background(), []*GCPSecretObject{{ObjectName: "db-password"}})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(atomic.LoadInt32(&secretManager.tokenRequests)).To(BeZero())
+		})
+	})
+
+	It("fetches the manifest secrets", func() {
+		fetcher := NewManifestSecretFetcher(newProvider(), &SecretManifest{
+			SecretObjects: []*GCPSecretObject{{ObjectName: "api-key", ObjectAlias: "key"}},
+		}, zaptest.NewLogger(GinkgoT()))
+
+		var _ secrets.SecretsFetcher = fetcher
+		res, err := fetcher.Fetch(context.Background())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(res[0].Content).To(Equal("api-key"))
+	})
+})
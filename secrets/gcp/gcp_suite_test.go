@@ -0,0 +1,13 @@
+package gcp_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func TestGCPSecrets(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "gcp secret Suite")
+}
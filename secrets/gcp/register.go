@@ -0,0 +1,128 @@
+package gcp
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/daniel-cohen/secretsfetcher/secrets"
+	"github.com/spf13/pflag"
+	"go.uber.org/zap"
+)
+
+// registration - the gcp provider of the secrets registry
+type registration struct {
+	// implements secrets.Provider
+}
+
+func init() {
+	secrets.RegisterProvider(registration{})
+}
+
+func (registration) Name() string {
+	return ProviderName
+}
+
+func (registration) Usage() secrets.ProviderUsage {
+	return secrets.ProviderUsage{
+		Short: "fetches secrets from google cloud secret manager",
+		Long: `Fetches the secrets in a gcp manifest, or all the project's secrets with the labels (--labels) and name prefix (--prefix), and writes them like the aws command.
+Access tokens are requested from the metadata server (workload identity) unless --tokenpath or APP_GCP_ACCESSTOKEN are set.`,
+		Example: "  secretsfetcher gcp -m manifest.yaml --project my-project -o /secrets\n  secretsfetcher gcp --project my-project --labels app=my-app,env=prod -o /secrets",
+	}
+}
+
+func (registration) ConfigDefaults() map[string]interface{} {
+	return map[string]interface{}{
+		"Project":          "",
+		"LabelFilters":     map[string]string{},
+		"PrefixFilter":     "",
+		"AccessToken":      "",
+		"AccessTokenPath":  "",
+		"Endpoint":         DefaultEndpoint,
+		"MetadataEndpoint": DefaultMetadataEndpoint,
+		"PathTranslation":  secrets.DefaultPathTranslation,
+		"FailurePolicy":    "",
+		"Concurrency":      DefaultConcurrency,
+		"Timeout":          DefaultTimeout,
+		"RequestTimeout":   DefaultRequestTimeout,
+	}
+}
+
+func (registration) AddFlags(flags *pflag.FlagSet) map[string]string {
+	flags.String("project", "", "the default project of the secrets (id or number)")
+	flags.StringToString("labels", map[string]string{}, "fetch all the project's secrets with these labels instead of a manifest. Example: --labels=app=my-app,env=prod")
+	flags.String("prefix", "", "only fetch the project's secrets with ids starting with this prefix (without a manifest)")
+	flags.String("tokenpath", "", "a file with an oauth2 access token (or set APP_GCP_ACCESSTOKEN). Defaults to the metadata server's")
+	flags.String("endpoint", DefaultEndpoint, "the secret manager api endpoint. Example: --endpoint=https://secretmanager.europe-west1.rep.googleapis.com")
+	flags.Int("concurrency", DefaultConcurrency, "the maximum number of secrets fetched in parallel")
+	flags.Duration("timeout", DefaultTimeout, "the overall timeout for fetching and writing the secrets. 0 disables the timeout")
+	flags.Duration("requesttimeout", DefaultRequestTimeout, "the timeout for each secret manager request. 0 disables the timeout")
+
+	return map[string]string{
+		"project":        "Project",
+		"labels":         "LabelFilters",
+		"prefix":         "PrefixFilter",
+		"tokenpath":      "AccessTokenPath",
+		"endpoint":       "Endpoint",
+		"concurrency":    "Concurrency",
+		"timeout":        "Timeout",
+		"requesttimeout": "RequestTimeout",
+	}
+}
+
+// NewFetch - the fetcher for the manifest, or all the project's secrets with the labels and prefix
+func (registration) NewFetch(ctx context.Context, config secrets.Decoder, manifest secrets.Decoder, zl *zap.Logger) (*secrets.ProviderFetch, error) {
+	cfg := &GCPConfig{}
+	if err := config(cfg); err != nil {
+		return nil, fmt.Errorf("invalid gcp config: %w", err)
+	}
+
+	pathTranslation := cfg.PathTranslation
+
+	var manifestCfg *SecretManifest
+	if manifest != nil {
+		manifestCfg = &SecretManifest{}
+		if err := manifest(manifestCfg); err != nil {
+			return nil, fmt.Errorf("unable to decode the manifest: %w", err)
+		}
+		if err := manifestCfg.Validate(); err != nil {
+			return nil, fmt.Errorf("invalid manifest: %w", err)
+		}
+		zl.Info("Loaded manifest config", zap.Any("manifestCfg", manifestCfg))
+
+		// the manifest takes precedence over the main config:
+		if manifestCfg.Project != "" {
+			cfg.Project = manifestCfg.Project
+		}
+		if manifestCfg.PathTranslation != "" {
+			pathTranslation = manifestCfg.PathTranslation
+		}
+	} else if cfg.Project == "" {
+		return nil, fmt.Errorf("no manifest and no gcp project set")
+	}
+
+	failurePolicy, err := secrets.ParseFailurePolicy(cfg.FailurePolicy)
+	if err != nil {
+		return nil, fmt.Errorf("invalid failure policy: %w", err)
+	}
+
+	provider := NewGCPSecretsProvider(cfg, zl).
+		WithConcurrency(cfg.Concurrency).
+		WithRequestTimeout(cfg.RequestTimeout).
+		WithFailurePolicy(failurePolicy)
+
+	fetch := &secrets.ProviderFetch{
+		PathTranslation: secrets.PathTranslationChar(pathTranslation),
+		Timeout:         cfg.Timeout,
+	}
+	if manifestCfg != nil {
+		fetch.Fetcher = NewManifestSecretFetcher(provider, manifestCfg, zl)
+		fetch.Templates = manifestCfg.Templates
+		fetch.Required = manifestCfg.RequiredSecrets()
+		fetch.Outputs = manifestCfg.Outputs(fetch.PathTranslation)
+	} else {
+		fetch.Fetcher = NewListSecretFetcher(provider, cfg.PrefixFilter, cfg.LabelFilters, zl)
+	}
+
+	return fetch, nil
+}
@@ -0,0 +1,81 @@
+package gcp
+
+import (
+	"strings"
+
+	"github.com/daniel-cohen/secretsfetcher/secrets"
+)
+
+const ProviderName = "gcp"
+
+// SecretManifest - the gcp manifest, mirroring the aws one
+type SecretManifest struct {
+	Provider      string
+	SecretObjects []*GCPSecretObject
+
+	// the default project of the objects, overriding the config's
+	Project string
+
+	// the substitution character for slashes in file names (defaults to "_"). "False" disables it
+	PathTranslation string
+
+	// templates rendered with the secrets by the template output format
+	Templates []*secrets.TemplateSpec
+}
+
+// Validate - checks the manifest for conflicting output names, invalid resource names and file modes before anything is fetched or written
+func (m *SecretManifest) Validate() error {
+	if err := secrets.CheckManifestProvider(m.Provider, ProviderName); err != nil {
+		return err
+	}
+
+	if err := secrets.ValidateTemplates(m.Templates); err != nil {
+		return err
+	}
+
+	outputs := make([]secrets.ObjectOutput, 0, len(m.SecretObjects))
+	for _, obj := range m.SecretObjects {
+		outputs = append(outputs, secrets.ObjectOutput{ObjectName: obj.ObjectName, OutputName: obj.outputName()})
+	}
+	if err := secrets.CheckOutputNames(outputs); err != nil {
+		return err
+	}
+
+	for _, obj := range m.SecretObjects {
+		// the project may also come from the config, so only resource names are checked:
+		if strings.HasPrefix(obj.ObjectName, "projects/") {
+			if _, _, err := obj.resourceName(m.Project); err != nil {
+				return err
+			}
+		}
+
+		if _, err := obj.FilePermissions(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Outputs - the output names of the objects' secrets, with the slashes of unaliased names converted like the writers do
+func (m *SecretManifest) Outputs(slashConversionChar string) []secrets.ObjectOutput {
+	outputs := make([]secrets.ObjectOutput, 0, len(m.SecretObjects))
+	for _, obj := range m.SecretObjects {
+		outputs = append(outputs, secrets.ObjectOutput{
+			ObjectName: obj.ObjectName,
+			OutputName: secrets.OutputName(&secrets.Secret{Name: obj.outputName(), Alias: obj.ObjectAlias}, slashConversionChar),
+		})
+	}
+	return outputs
+}
+
+// RequiredSecrets - the names (alias or secret id) of the secrets written for the non optional objects
+func (m *SecretManifest) RequiredSecrets() []string {
+	var required []string
+	for _, obj := range m.SecretObjects {
+		if !obj.Optional {
+			required = append(required, obj.outputName())
+		}
+	}
+	return required
+}
@@ -0,0 +1,29 @@
+package gcp
+
+import (
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+var _ = DescribeTable("Validating a gcp manifest",
+	func(manifest *SecretManifest, expectError bool) {
+		err := manifest.Validate()
+		if expectError {
+			Expect(err).To(HaveOccurred())
+		} else {
+			Expect(err).NotTo(HaveOccurred())
+		}
+	},
+	Entry("valid", &SecretManifest{Provider: "gcp", Project: "my-project", SecretObjects: []*GCPSecretObject{{ObjectName: "a", ObjectVersion: "3"}, {ObjectName: "b"}}}, false),
+	Entry("resource name", &SecretManifest{SecretObjects: []*GCPSecretObject{{ObjectName: "projects/other/secrets/a"}}}, false),
+	Entry("invalid resource name", &SecretManifest{SecretObjects: []*GCPSecretObject{{ObjectName: "projects/other/a"}}}, true),
+	Entry("invalid file mode", &SecretManifest{SecretObjects: []*GCPSecretObject{{ObjectName: "a", FileMode: "0999"}}}, true),
+	Entry("the same secret from two projects without aliases", &SecretManifest{SecretObjects: []*GCPSecretObject{
+		{ObjectName: "projects/a/secrets/db"},
+		{ObjectName: "projects/b/secrets/db"},
+	}}, true),
+	Entry("the same secret from two projects with aliases", &SecretManifest{SecretObjects: []*GCPSecretObject{
+		{ObjectName: "projects/a/secrets/db", ObjectAlias: "db-a"},
+		{ObjectName: "projects/b/secrets/db", ObjectAlias: "db-b"},
+	}}, false),
+)
@@ -0,0 +1,106 @@
+//go:build !windows
+
+package secrets
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+var signalsByName = map[string]syscall.Signal{
+	"HUP":  syscall.SIGHUP,
+	"INT":  syscall.SIGINT,
+	"QUIT": syscall.SIGQUIT,
+	"TERM": syscall.SIGTERM,
+	"USR1": syscall.SIGUSR1,
+	"USR2": syscall.SIGUSR2,
+}
+
+// ParseSignal - parses a signal name (HUP or SIGHUP) or number
+func ParseSignal(s string) (syscall.Signal, error) {
+	if n, err := strconv.Atoi(s); err == nil && n > 0 {
+		return syscall.Signal(n), nil
+	}
+
+	if sig, ok := signalsByName[strings.TrimPrefix(strings.ToUpper(s), "SIG")]; ok {
+		return sig, nil
+	}
+	return 0, fmt.Errorf("unsupported signal %q: expected HUP, INT, QUIT, TERM, USR1, USR2 or a signal number", s)
+}
+
+// SignalHook - sends a signal to the process in the pid file, or to all the processes with the name
+type SignalHook struct {
+	PidFile     string
+	ProcessName string
+	Signal      syscall.Signal
+
+	// the proc filesystem processes are found by name in
+	procPath string
+}
+
+func (h *SignalHook) Name() string {
+	return "signal"
+}
+
+// pids - the pid from the pid file, or the pids of the processes named ProcessName
+func (h *SignalHook) pids() ([]int, error) {
+	if h.PidFile != "" {
+		b, err := os.ReadFile(h.PidFile)
+		if err != nil {
+			return nil, err
+		}
+
+		pid, err := strconv.Atoi(strings.TrimSpace(string(b)))
+		if err != nil || pid <= 0 {
+			return nil, fmt.Errorf("invalid pid in %s", h.PidFile)
+		}
+		return []int{pid}, nil
+	}
+
+	procPath := h.procPath
+	if procPath == "" {
+		procPath = "/proc"
+	}
+
+	comms, err := filepath.Glob(filepath.Join(procPath, "[0-9]*", "comm"))
+	if err != nil {
+		return nil, err
+	}
+
+	var pids []int
+	for _, comm := range comms {
+		b, err := os.ReadFile(comm)
+		if err != nil || strings.TrimSpace(string(b)) != h.ProcessName {
+			continue
+		}
+
+		pid, err := strconv.Atoi(filepath.Base(filepath.Dir(comm)))
+		if err == nil && pid != os.Getpid() {
+			pids = append(pids, pid)
+		}
+	}
+
+	if len(pids) == 0 {
+		return nil, fmt.Errorf("no process named %q found", h.ProcessName)
+	}
+	return pids, nil
+}
+
+func (h *SignalHook) Run(ctx context.Context, changed []string) error {
+	pids, err := h.pids()
+	if err != nil {
+		return err
+	}
+
+	for _, pid := range pids {
+		if err := syscall.Kill(pid, h.Signal); err != nil {
+			return fmt.Errorf("failed to send %s to pid %d: %w", h.Signal, pid, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,29 @@
+package secrets
+
+import (
+	"context"
+	"errors"
+	"syscall"
+)
+
+var errSignalsNotSupported = errors.New("signal hooks are not supported on windows")
+
+// ParseSignal - windows processes can't be signalled
+func ParseSignal(s string) (syscall.Signal, error) {
+	return 0, errSignalsNotSupported
+}
+
+// SignalHook - windows processes can't be signalled
+type SignalHook struct {
+	PidFile     string
+	ProcessName string
+	Signal      syscall.Signal
+}
+
+func (h *SignalHook) Name() string {
+	return "signal"
+}
+
+func (h *SignalHook) Run(ctx context.Context, changed []string) error {
+	return errSignalsNotSupported
+}
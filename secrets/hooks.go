@@ -0,0 +1,145 @@
+package secrets
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/go-multierror"
+)
+
+const (
+	DefaultHookTimeout = 30 * time.Second
+
+	// ChangedSecretsEnv - the environment variable command hooks get the changed secret names in (comma separated)
+	ChangedSecretsEnv = "SECRETSFETCHER_CHANGED"
+)
+
+// ChangeHook - runs after changed secrets were written. E.g: to reload the app using them
+type ChangeHook interface {
+	Name() string
+	Run(ctx context.Context, changed []string) error
+}
+
+// CommandHook - runs a shell command with the changed secret names in the SECRETSFETCHER_CHANGED environment variable
+type CommandHook struct {
+	Command string
+	Timeout time.Duration // 0 disables it
+}
+
+func (h *CommandHook) Name() string {
+	return "command"
+}
+
+func (h *CommandHook) Run(ctx context.Context, changed []string) error {
+	if h.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, h.Timeout)
+		defer cancel()
+	}
+
+	cmd := exec.CommandContext(ctx, "/bin/sh", "-c", h.Command)
+	cmd.Env = append(os.Environ(), ChangedSecretsEnv+"="+strings.Join(changed, ","))
+	// don't wait for the output of background processes the killed shell started:
+	cmd.WaitDelay = time.Second
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		if ctx.Err() != nil {
+			err = ctx.Err()
+		}
+		return fmt.Errorf("command %q failed: %w: %s", h.Command, err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// CheckLocalURL - checks the url is an http(s) url on the loopback interface, so secret names don't leave the host
+func CheckLocalURL(rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("unsupported url scheme %q in %s", u.Scheme, rawURL)
+	}
+
+	host := u.Hostname()
+	if host == "localhost" {
+		return nil
+	}
+	if ip := net.ParseIP(host); ip != nil && ip.IsLoopback() {
+		return nil
+	}
+	return fmt.Errorf("%s is not a local url", rawURL)
+}
+
+// hookHTTPClient - doesn't follow redirects, as only the configured url was checked to be local
+var hookHTTPClient = &http.Client{
+	CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	},
+}
+
+// HTTPHook - POSTs the changed secret names as json ({"changed": [...]}) to the url. Non 2xx responses (including redirects) fail the hook
+type HTTPHook struct {
+	URL     string
+	Timeout time.Duration // 0 disables it
+}
+
+func (h *HTTPHook) Name() string {
+	return "http"
+}
+
+func (h *HTTPHook) Run(ctx context.Context, changed []string) error {
+	if err := CheckLocalURL(h.URL); err != nil {
+		return err
+	}
+
+	if h.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, h.Timeout)
+		defer cancel()
+	}
+
+	body, err := json.Marshal(struct {
+		Changed []string `json:"changed"`
+	}{Changed: changed})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, h.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := hookHTTPClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("POST %s failed: %w", h.URL, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("POST %s failed: %s", h.URL, resp.Status)
+	}
+	return nil
+}
+
+// runHooks - runs all the hooks (even if some fail), returning their aggregated errors
+func runHooks(ctx context.Context, hooks []ChangeHook, changed []string) error {
+	var result *multierror.Error
+	for _, h := range hooks {
+		if err := h.Run(ctx, changed); err != nil {
+			result = multierror.Append(result, fmt.Errorf("%s hook: %w", h.Name(), err))
+		}
+	}
+	return result.ErrorOrNil()
+}
@@ -0,0 +1,146 @@
+package secrets_test
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/daniel-cohen/secretsfetcher/secrets"
+)
+
+var _ = Describe("Post update hooks", func() {
+	var tmpFolder string
+
+	BeforeEach(func() {
+		var err error
+		tmpFolder, err = ioutil.TempDir("", "secretsfetcher")
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(tmpFolder)
+	})
+
+	Describe("command hooks", func() {
+		It("passes the changed secret names to the command", func() {
+			out := filepath.Join(tmpFolder, "changed")
+			hook := &secrets.CommandHook{Command: "echo -n $SECRETSFETCHER_CHANGED > " + out}
+
+			Expect(hook.Run(context.Background(), []string{"secret1", "secret2"})).To(Succeed())
+
+			b, err := ioutil.ReadFile(out)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(b)).To(Equal("secret1,secret2"))
+		})
+
+		It("reports the output of failed commands", func() {
+			hook := &secrets.CommandHook{Command: "echo reload failed; exit 3"}
+			Expect(hook.Run(context.Background(), []string{"secret1"})).To(MatchError(ContainSubstring("reload failed")))
+		})
+
+		It("kills commands which time out", func() {
+			hook := &secrets.CommandHook{Command: "sleep 10", Timeout: 50 * time.Millisecond}
+			Expect(hook.Run(context.Background(), []string{"secret1"})).To(MatchError(ContainSubstring("deadline exceeded")))
+		})
+	})
+
+	Describe("http hooks", func() {
+		It("POSTs the changed secret names", func() {
+			var changed []string
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				Expect(r.Method).To(Equal(http.MethodPost))
+				var body struct{ Changed []string }
+				Expect(json.NewDecoder(r.Body).Decode(&body)).To(Succeed())
+				changed = body.Changed
+			}))
+			defer server.Close()
+
+			hook := &secrets.HTTPHook{URL: server.URL + "/reload"}
+			Expect(hook.Run(context.Background(), []string{"secret1", "secret2"})).To(Succeed())
+			Expect(changed).To(Equal([]string{"secret1", "secret2"}))
+		})
+
+		It("fails on error responses", func() {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusServiceUnavailable)
+			}))
+			defer server.Close()
+
+			hook := &secrets.HTTPHook{URL: server.URL}
+			Expect(hook.Run(context.Background(), []string{"secret1"})).To(MatchError(ContainSubstring("503")))
+		})
+
+		It("doesn't follow redirects", func() {
+			var redirected int32
+			target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				atomic.AddInt32(&redirected, 1)
+			}))
+			defer target.Close()
+
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				http.Redirect(w, r, target.URL, http.StatusTemporaryRedirect)
+			}))
+			defer server.Close()
+
+			hook := &secrets.HTTPHook{URL: server.URL}
+			Expect(hook.Run(context.Background(), []string{"secret1"})).To(MatchError(ContainSubstring("307")))
+			Expect(atomic.LoadInt32(&redirected)).To(BeZero())
+		})
+
+		It("only allows local urls", func() {
+			Expect(secrets.CheckLocalURL("http://localhost:8080/reload")).To(Succeed())
+			Expect(secrets.CheckLocalURL("http://127.0.0.1:8080/reload")).To(Succeed())
+			Expect(secrets.CheckLocalURL("https://[::1]/reload")).To(Succeed())
+			Expect(secrets.CheckLocalURL("http://example.com/reload")).NotTo(Succeed())
+			Expect(secrets.CheckLocalURL("file:///etc/passwd")).NotTo(Succeed())
+		})
+	})
+
+	Describe("signal hooks", func() {
+		It("parses signal names and numbers", func() {
+			Expect(secrets.ParseSignal("HUP")).To(Equal(syscall.SIGHUP))
+			Expect(secrets.ParseSignal("sigusr1")).To(Equal(syscall.SIGUSR1))
+			Expect(secrets.ParseSignal("15")).To(Equal(syscall.SIGTERM))
+			_, err := secrets.ParseSignal("RELOAD")
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("signals the process in the pid file", func() {
+			sigs := make(chan os.Signal, 1)
+			signal.Notify(sigs, syscall.SIGUSR2)
+			defer signal.Stop(sigs)
+
+			pidFile := filepath.Join(tmpFolder, "app.pid")
+			Expect(ioutil.WriteFile(pidFile, []byte(strconv.Itoa(os.Getpid())+"\n"), 0600)).To(Succeed())
+
+			hook := &secrets.SignalHook{PidFile: pidFile, Signal: syscall.SIGUSR2}
+			Expect(hook.Run(context.Background(), []string{"secret1"})).To(Succeed())
+			Eventually(sigs).Should(Receive(Equal(syscall.SIGUSR2)))
+		})
+
+		It("fails when the pid file is invalid", func() {
+			pidFile := filepath.Join(tmpFolder, "app.pid")
+			Expect(ioutil.WriteFile(pidFile, []byte("not a pid"), 0600)).To(Succeed())
+
+			hook := &secrets.SignalHook{PidFile: pidFile, Signal: syscall.SIGHUP}
+			Expect(hook.Run(context.Background(), []string{"secret1"})).NotTo(Succeed())
+		})
+
+		It("fails when no process has the name", func() {
+			hook := &secrets.SignalHook{ProcessName: "no-such-process-name", Signal: syscall.SIGHUP}
+			Expect(hook.Run(context.Background(), []string{"secret1"})).To(MatchError(ContainSubstring("no process named")))
+		})
+	})
+})
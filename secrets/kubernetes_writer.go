@@ -0,0 +1,261 @@
+package secrets
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"go.uber.org/zap"
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	DefaultKubernetesSecretType = "Opaque"
+	DefaultKubernetesFile       = "secret.yaml"
+
+	KubernetesEncodingYAML = "yaml"
+	KubernetesEncodingJSON = "json"
+
+	// kubernetesMaxSecretSize - the api server rejects secrets with more data than this.
+	// Checked against the raw (not base64 encoded) content of the secrets, as the api server does
+	kubernetesMaxSecretSize = 1024 * 1024
+
+	// kubernetesMaxNameLength - the maximum length of a DNS-1123 subdomain
+	kubernetesMaxNameLength = 253
+)
+
+var (
+	// kubernetesKeyRegexp - valid kubernetes secret data keys
+	kubernetesKeyRegexp = regexp.MustCompile(`^[-._a-zA-Z0-9]+$`)
+
+	// kubernetesNameRegexp - valid kubernetes secret names (DNS-1123 subdomains)
+	kubernetesNameRegexp = regexp.MustCompile(`^[a-z0-9]([-a-z0-9]*[a-z0-9])?(\.[a-z0-9]([-a-z0-9]*[a-z0-9])?)*$`)
+)
+
+// validKubernetesName - whether the name is a valid kubernetes secret name
+func validKubernetesName(name string) bool {
+	return len(name) <= kubernetesMaxNameLength && kubernetesNameRegexp.MatchString(name)
+}
+
+// KubernetesSecretOptions - the metadata of the generated kubernetes secrets
+type KubernetesSecretOptions struct {
+	Name      string
+	Namespace string
+	Labels    map[string]string
+	Type      string // defaults to Opaque
+
+	// PerSecret - a kubernetes secret per fetched secret (named <name>-<key>, lowercased with underscores replaced) instead of a single one with all the keys
+	PerSecret bool
+
+	// yaml (default) or json
+	Encoding string
+}
+
+type kubernetesMetadata struct {
+	Name      string            `json:"name" yaml:"name"`
+	Namespace string            `json:"namespace,omitempty" yaml:"namespace,omitempty"`
+	Labels    map[string]string `json:"labels,omitempty" yaml:"labels,omitempty"`
+}
+
+type kubernetesSecret struct {
+	APIVersion string             `json:"apiVersion" yaml:"apiVersion"`
+	Kind       string             `json:"kind" yaml:"kind"`
+	Metadata   kubernetesMetadata `json:"metadata" yaml:"metadata"`
+	Type       string             `json:"type" yaml:"type"`
+	Data       map[string]string  `json:"data" yaml:"data"`
+}
+
+type kubernetesList struct {
+	APIVersion string              `json:"apiVersion"`
+	Kind       string              `json:"kind"`
+	Items      []*kubernetesSecret `json:"items"`
+}
+
+type KubernetesSecretWriter struct {
+	// implements SecretWriter
+	zl                  *zap.Logger
+	outputFile          string // "-" writes to stdout
+	slashConversionChar string
+	opts                KubernetesSecretOptions
+
+	fileMode os.FileMode
+	uid      int
+	gid      int
+}
+
+// WithFileMode - the mode of the written manifest file (regardless of the umask)
+func (kw *KubernetesSecretWriter) WithFileMode(mode os.FileMode) *KubernetesSecretWriter {
+	kw.fileMode = mode
+	return kw
+}
+
+// WithOwner - the uid and gid of the written manifest file. KeepOwner leaves them as the user running the process
+func (kw *KubernetesSecretWriter) WithOwner(uid int, gid int) *KubernetesSecretWriter {
+	kw.uid = uid
+	kw.gid = gid
+	return kw
+}
+
+func NewKubernetesSecretWriter(
+	outputFile string,
+	slashConversionChar string,
+	opts KubernetesSecretOptions,
+	zl *zap.Logger) *KubernetesSecretWriter {
+	return &KubernetesSecretWriter{
+		zl:                  zl,
+		outputFile:          outputFile,
+		slashConversionChar: slashConversionChar,
+		opts:                opts,
+		fileMode:            DefaultFileMode,
+		uid:                 KeepOwner,
+		gid:                 KeepOwner,
+	}
+}
+
+func (kw *KubernetesSecretWriter) newSecret(name string) *kubernetesSecret {
+	secretType := kw.opts.Type
+	if secretType == "" {
+		secretType = DefaultKubernetesSecretType
+	}
+
+	return &kubernetesSecret{
+		APIVersion: "v1",
+		Kind:       "Secret",
+		Metadata: kubernetesMetadata{
+			Name:      name,
+			Namespace: kw.opts.Namespace,
+			Labels:    kw.opts.Labels,
+		},
+		Type: secretType,
+		Data: map[string]string{},
+	}
+}
+
+// kubernetesSecrets - builds the kubernetes secrets. The data keys follow the file name rules (alias or path translated name).
+// Invalid or duplicate secret names are rejected before anything is rendered
+func (kw *KubernetesSecretWriter) kubernetesSecrets(secretRes []*Secret) ([]*kubernetesSecret, error) {
+	if kw.opts.Name == "" {
+		return nil, fmt.Errorf("kubernetes secret name is not set")
+	}
+	if !kw.opts.PerSecret && !validKubernetesName(kw.opts.Name) {
+		return nil, fmt.Errorf("%q is not a valid kubernetes secret name", kw.opts.Name)
+	}
+
+	var res []*kubernetesSecret
+	sizes := map[*kubernetesSecret]int{}
+	names := map[string]string{} // the secret each kubernetes secret was generated from
+	var single *kubernetesSecret
+	for _, s := range secretRes {
+		key := OutputName(s, kw.slashConversionChar)
+		if !kubernetesKeyRegexp.MatchString(key) {
+			return nil, fmt.Errorf("secret %s: %q is not a valid kubernetes secret key", s.Name, key)
+		}
+
+		var ks *kubernetesSecret
+		if kw.opts.PerSecret {
+			name := strings.ToLower(strings.ReplaceAll(kw.opts.Name+"-"+key, "_", "-"))
+			if !validKubernetesName(name) {
+				return nil, fmt.Errorf("secret %s: %q is not a valid kubernetes secret name", s.Name, name)
+			}
+			if other, ok := names[name]; ok {
+				return nil, fmt.Errorf("secrets %s and %s are both written as the kubernetes secret %s", other, s.Name, name)
+			}
+			names[name] = s.Name
+
+			ks = kw.newSecret(name)
+			res = append(res, ks)
+		} else {
+			if single == nil {
+				single = kw.newSecret(kw.opts.Name)
+				res = append(res, single)
+			}
+			ks = single
+		}
+
+		if _, ok := ks.Data[key]; ok {
+			return nil, fmt.Errorf("secret %s: duplicate kubernetes secret key %q", s.Name, key)
+		}
+		ks.Data[key] = base64.StdEncoding.EncodeToString([]byte(s.Content))
+
+		sizes[ks] += len(s.Content)
+		if sizes[ks] > kubernetesMaxSecretSize {
+			return nil, fmt.Errorf("kubernetes secret %s exceeds the %d bytes size limit of its (raw) data", ks.Metadata.Name, kubernetesMaxSecretSize)
+		}
+	}
+
+	if single == nil && !kw.opts.PerSecret {
+		res = append(res, kw.newSecret(kw.opts.Name))
+	}
+
+	return res, nil
+}
+
+// render - the kubernetes secrets as yaml documents or json (a List if there are several)
+func (kw *KubernetesSecretWriter) render(kubernetesSecrets []*kubernetesSecret) ([]byte, error) {
+	switch kw.opts.Encoding {
+	case "", KubernetesEncodingYAML:
+		var sb strings.Builder
+		for i, ks := range kubernetesSecrets {
+			if i > 0 {
+				sb.WriteString("---\n")
+			}
+			b, err := yaml.Marshal(ks)
+			if err != nil {
+				return nil, err
+			}
+			sb.Write(b)
+		}
+		return []byte(sb.String()), nil
+	case KubernetesEncodingJSON:
+		var v interface{} = kubernetesSecrets[0]
+		if len(kubernetesSecrets) != 1 {
+			v = &kubernetesList{APIVersion: "v1", Kind: "List", Items: kubernetesSecrets}
+		}
+		b, err := json.MarshalIndent(v, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		return append(b, '\n'), nil
+	default:
+		return nil, fmt.Errorf("unsupported kubernetes encoding %q: expected %s or %s", kw.opts.Encoding, KubernetesEncodingYAML, KubernetesEncodingJSON)
+	}
+}
+
+// WriteSecrets - writes the secrets as kubernetes secret manifests to the output file (atomically) or stdout
+func (kw *KubernetesSecretWriter) WriteSecrets(ctx context.Context, secretRes []*Secret) error {
+	kubernetesSecrets, err := kw.kubernetesSecrets(secretRes)
+	if err != nil {
+		kw.zl.Error("failed to build the kubernetes secrets", zap.Error(err))
+		return err
+	}
+
+	content, err := kw.render(kubernetesSecrets)
+	if err != nil {
+		kw.zl.Error("failed to render the kubernetes secrets", zap.Error(err))
+		return err
+	}
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if kw.outputFile == "-" {
+		_, err := os.Stdout.Write(content)
+		return err
+	}
+
+	if err := writeFileAtomic(kw.outputFile, content, FilePermissions{Mode: kw.fileMode, UID: kw.uid, GID: kw.gid}); err != nil {
+		kw.zl.Error("failed to write the kubernetes secrets", zap.String("file_path", kw.outputFile), zap.Error(err))
+		return err
+	}
+
+	kw.zl.Info("wrote kubernetes secrets",
+		zap.String("file_path", kw.outputFile),
+		zap.Int("secrets", len(kubernetesSecrets)),
+	)
+	return nil
+}
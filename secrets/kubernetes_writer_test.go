@@ -0,0 +1,156 @@
+package secrets_test
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+	"go.uber.org/zap/zaptest"
+	"gopkg.in/yaml.v2"
+
+	"github.com/daniel-cohen/secretsfetcher/secrets"
+)
+
+var _ = Describe("Writing secrets as kubernetes secrets", func() {
+	var (
+		outputFolder string
+		outputFile   string
+		secretRes    []*secrets.Secret
+	)
+
+	BeforeEach(func() {
+		var err error
+		outputFolder, err = ioutil.TempDir("", "secretsfetcher")
+		Expect(err).NotTo(HaveOccurred())
+		outputFile = filepath.Join(outputFolder, secrets.DefaultKubernetesFile)
+
+		secretRes = []*secrets.Secret{
+			{Name: "my-app/db", Content: "password"},
+			{Name: "arn:aws:secretsmanager:us-west-2:111122223333:secret:tls-1a2b3c", Alias: "tls.key", Content: "key"},
+		}
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(outputFolder)
+	})
+
+	write := func(opts secrets.KubernetesSecretOptions) (string, error) {
+		writer := secrets.NewKubernetesSecretWriter(outputFile, "_", opts, zaptest.NewLogger(GinkgoT()))
+		if err := writer.WriteSecrets(context.Background(), secretRes); err != nil {
+			return "", err
+		}
+
+		b, err := ioutil.ReadFile(outputFile)
+		Expect(err).NotTo(HaveOccurred())
+		return string(b), nil
+	}
+
+	It("writes a single yaml secret with base64 data", func() {
+		out, err := write(secrets.KubernetesSecretOptions{
+			Name:      "my-app",
+			Namespace: "apps",
+			Labels:    map[string]string{"app": "my-app"},
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(out).To(Equal(`apiVersion: v1
+kind: Secret
+metadata:
+  name: my-app
+  namespace: apps
+  labels:
+    app: my-app
+type: Opaque
+data:
+  my-app_db: cGFzc3dvcmQ=
+  tls.key: a2V5
+`))
+	})
+
+	It("writes a json list with a secret per fetched secret", func() {
+		out, err := write(secrets.KubernetesSecretOptions{
+			Name:      "my-app",
+			Type:      "kubernetes.io/tls",
+			PerSecret: true,
+			Encoding:  secrets.KubernetesEncodingJSON,
+		})
+		Expect(err).NotTo(HaveOccurred())
+
+		var list struct {
+			Kind  string
+			Items []struct {
+				Metadata struct{ Name string }
+				Type     string
+				Data     map[string]string
+			}
+		}
+		Expect(json.Unmarshal([]byte(out), &list)).To(Succeed())
+		Expect(list.Kind).To(Equal("List"))
+		Expect(list.Items).To(HaveLen(2))
+		Expect(list.Items[0].Metadata.Name).To(Equal("my-app-my-app-db"))
+		Expect(list.Items[0].Type).To(Equal("kubernetes.io/tls"))
+		Expect(list.Items[0].Data).To(Equal(map[string]string{"my-app_db": "cGFzc3dvcmQ="}))
+		Expect(list.Items[1].Metadata.Name).To(Equal("my-app-tls.key"))
+	})
+
+	It("rejects invalid kubernetes keys", func() {
+		secretRes = append(secretRes, &secrets.Secret{Name: "nested", Alias: "a/b", Content: "value"})
+		_, err := write(secrets.KubernetesSecretOptions{Name: "my-app"})
+		Expect(err).To(MatchError(ContainSubstring("a/b")))
+
+		_, err = os.Stat(outputFile)
+		Expect(os.IsNotExist(err)).To(BeTrue())
+	})
+
+	It("rejects secrets generated with the same name", func() {
+		secretRes = append(secretRes, &secrets.Secret{Name: "other", Alias: "my-app-db", Content: "value"})
+		_, err := write(secrets.KubernetesSecretOptions{Name: "my-app", PerSecret: true})
+		Expect(err).To(MatchError(ContainSubstring("my-app-my-app-db")))
+	})
+
+	It("rejects invalid kubernetes secret names", func() {
+		secretRes = append(secretRes, &secrets.Secret{Name: "other", Alias: "db.", Content: "value"})
+		_, err := write(secrets.KubernetesSecretOptions{Name: "my-app", PerSecret: true})
+		Expect(err).To(MatchError(ContainSubstring("my-app-db.")))
+
+		_, err = write(secrets.KubernetesSecretOptions{Name: "My_App"})
+		Expect(err).To(MatchError(ContainSubstring("My_App")))
+	})
+
+	DescribeTable("writes only the manifest to stdout",
+		func(encoding string, unmarshal func([]byte, interface{}) error) {
+			r, w, err := os.Pipe()
+			Expect(err).NotTo(HaveOccurred())
+			stdout := os.Stdout
+			os.Stdout = w
+			defer func() { os.Stdout = stdout }()
+
+			writer := secrets.NewKubernetesSecretWriter("-", "_", secrets.KubernetesSecretOptions{Name: "my-app", Encoding: encoding}, zaptest.NewLogger(GinkgoT()))
+			err = writer.WriteSecrets(context.Background(), secretRes)
+			w.Close()
+			Expect(err).NotTo(HaveOccurred())
+
+			out, err := ioutil.ReadAll(r)
+			Expect(err).NotTo(HaveOccurred())
+
+			var secret struct {
+				Kind string
+				Data map[string]string
+			}
+			Expect(unmarshal(out, &secret)).To(Succeed())
+			Expect(secret.Kind).To(Equal("Secret"))
+			Expect(secret.Data).To(HaveKeyWithValue("my-app_db", "cGFzc3dvcmQ="))
+		},
+		Entry("yaml", secrets.KubernetesEncodingYAML, yaml.Unmarshal),
+		Entry("json", secrets.KubernetesEncodingJSON, json.Unmarshal),
+	)
+
+	It("requires a name", func() {
+		_, err := write(secrets.KubernetesSecretOptions{})
+		Expect(err).To(HaveOccurred())
+	})
+})
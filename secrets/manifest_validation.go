@@ -0,0 +1,54 @@
+package secrets
+
+import (
+	"fmt"
+	"path/filepath"
+)
+
+// ObjectOutput - a manifest object, and the name (alias or name) its secret is written as
+type ObjectOutput struct {
+	ObjectName string
+	OutputName string
+}
+
+// CheckManifestProvider - a manifest's provider field must be empty or the provider's name
+func CheckManifestProvider(manifestProvider string, providerName string) error {
+	if manifestProvider != "" && manifestProvider != providerName {
+		return fmt.Errorf("unsupported provider %q in a manifest of the %s provider", manifestProvider, providerName)
+	}
+	return nil
+}
+
+// ValidateTemplates - templates must have a source, a relative destination and a valid file mode (if set)
+func ValidateTemplates(templates []*TemplateSpec) error {
+	for _, t := range templates {
+		if t.Source == "" || t.Destination == "" {
+			return fmt.Errorf("templates must have both a source and a destination")
+		}
+		if filepath.IsAbs(t.Destination) {
+			return fmt.Errorf("template %s: the destination %q must be relative to the output folder", t.Source, t.Destination)
+		}
+		if t.FileMode != "" {
+			if _, err := ParseFileMode(t.FileMode); err != nil {
+				return fmt.Errorf("template %s: %w", t.Source, err)
+			}
+		}
+	}
+	return nil
+}
+
+// CheckOutputNames - every object must have a name, and no two objects can be written as the same output name
+func CheckOutputNames(outputs []ObjectOutput) error {
+	names := map[string]string{}
+	for _, o := range outputs {
+		if o.ObjectName == "" {
+			return fmt.Errorf("objects must have an objectName")
+		}
+
+		if other, ok := names[o.OutputName]; ok {
+			return fmt.Errorf("objects %s and %s are both written as %q", other, o.ObjectName, o.OutputName)
+		}
+		names[o.OutputName] = o.ObjectName
+	}
+	return nil
+}
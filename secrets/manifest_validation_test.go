@@ -0,0 +1,53 @@
+package secrets_test
+
+import (
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+
+	"github.com/daniel-cohen/secretsfetcher/secrets"
+)
+
+var _ = DescribeTable("Checking a manifest's provider",
+	func(manifestProvider string, expectError bool) {
+		err := secrets.CheckManifestProvider(manifestProvider, "vault")
+		if expectError {
+			Expect(err).To(HaveOccurred())
+		} else {
+			Expect(err).NotTo(HaveOccurred())
+		}
+	},
+	Entry("unset", "", false),
+	Entry("the provider", "vault", false),
+	Entry("another provider", "aws", true),
+)
+
+var _ = DescribeTable("Validating manifest templates",
+	func(templates []*secrets.TemplateSpec, expectError bool) {
+		err := secrets.ValidateTemplates(templates)
+		if expectError {
+			Expect(err).To(HaveOccurred())
+		} else {
+			Expect(err).NotTo(HaveOccurred())
+		}
+	},
+	Entry("none", nil, false),
+	Entry("valid", []*secrets.TemplateSpec{{Source: "a.tmpl", Destination: "a", FileMode: "0400"}}, false),
+	Entry("missing source", []*secrets.TemplateSpec{{Destination: "a"}}, true),
+	Entry("missing destination", []*secrets.TemplateSpec{{Source: "a.tmpl"}}, true),
+	Entry("absolute destination", []*secrets.TemplateSpec{{Source: "a.tmpl", Destination: "/etc/a"}}, true),
+	Entry("invalid file mode", []*secrets.TemplateSpec{{Source: "a.tmpl", Destination: "a", FileMode: "0999"}}, true),
+)
+
+var _ = DescribeTable("Checking manifest output names",
+	func(outputs []secrets.ObjectOutput, expectError bool) {
+		err := secrets.CheckOutputNames(outputs)
+		if expectError {
+			Expect(err).To(HaveOccurred())
+		} else {
+			Expect(err).NotTo(HaveOccurred())
+		}
+	},
+	Entry("unique", []secrets.ObjectOutput{{ObjectName: "a", OutputName: "a"}, {ObjectName: "b", OutputName: "b"}}, false),
+	Entry("missing object name", []secrets.ObjectOutput{{OutputName: "a"}}, true),
+	Entry("the same output name", []secrets.ObjectOutput{{ObjectName: "a", OutputName: "x"}, {ObjectName: "b", OutputName: "x"}}, true),
+)
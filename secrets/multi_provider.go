@@ -0,0 +1,301 @@
+package secrets
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/go-multierror"
+	"go.uber.org/zap"
+)
+
+// ManifestGroup - the objects of a manifest fetched by one provider (in one region and role), as a manifest of their own
+type ManifestGroup struct {
+	Provider string
+	Region   string
+	RoleArn  string
+
+	// the manifest's settings, with only the group's objects and its provider, region and role
+	Manifest map[string]interface{}
+}
+
+// Name - the provider, region and role of the group. E.g: aws/us-east-1
+func (g *ManifestGroup) Name() string {
+	name := g.Provider
+	if g.Region != "" {
+		name += "/" + g.Region
+	}
+	if g.RoleArn != "" {
+		name += "/" + g.RoleArn
+	}
+	return name
+}
+
+// SplitManifest - groups the manifest's objects by their provider, region and roleArn fields (defaulting to the manifest's, and to the defaultProvider).
+// Groups are in the order of their first object. The templates are only kept in the first group, as they're rendered once with all the secrets
+func SplitManifest(settings map[string]interface{}, defaultProvider string) ([]*ManifestGroup, error) {
+	manifestProvider := stringSetting(settings, "provider")
+	if manifestProvider == "" {
+		manifestProvider = defaultProvider
+	}
+	manifestRegion := stringSetting(settings, "region")
+	manifestRoleArn := stringSetting(settings, "roleArn")
+
+	var objects []interface{}
+	if v, ok := lookupSetting(settings, "secretObjects"); ok && v != nil {
+		if objects, ok = v.([]interface{}); !ok {
+			return nil, fmt.Errorf("secretObjects must be a list")
+		}
+	}
+
+	var groups []*ManifestGroup
+	groupObjects := map[*ManifestGroup][]interface{}{}
+	for i, obj := range objects {
+		group := &ManifestGroup{Provider: manifestProvider, Region: manifestRegion, RoleArn: manifestRoleArn}
+		if obj != nil {
+			if !isSettingsMap(obj) {
+				return nil, fmt.Errorf("secretObjects[%d] must be a map", i)
+			}
+			if v := stringSetting(obj, "provider"); v != "" {
+				group.Provider = v
+			}
+			if v := stringSetting(obj, "region"); v != "" {
+				group.Region = v
+			}
+			if v := stringSetting(obj, "roleArn"); v != "" {
+				group.RoleArn = v
+			}
+		}
+
+		var existing *ManifestGroup
+		for _, g := range groups {
+			if g.Name() == group.Name() {
+				existing = g
+				break
+			}
+		}
+		if existing == nil {
+			groups = append(groups, group)
+			existing = group
+		}
+		groupObjects[existing] = append(groupObjects[existing], obj)
+	}
+
+	// A manifest without objects (E.g: only templates) is still fetched by its provider:
+	if len(groups) == 0 {
+		groups = append(groups, &ManifestGroup{Provider: manifestProvider, Region: manifestRegion, RoleArn: manifestRoleArn})
+	}
+
+	for i, g := range groups {
+		g.Manifest = map[string]interface{}{}
+		for k, v := range settings {
+			switch strings.ToLower(k) {
+			case "provider", "region", "rolearn", "secretobjects":
+				continue
+			case "templates":
+				if i > 0 {
+					continue
+				}
+			}
+			g.Manifest[k] = v
+		}
+
+		g.Manifest["provider"] = g.Provider
+		if g.Region != "" {
+			g.Manifest["region"] = g.Region
+		}
+		if g.RoleArn != "" {
+			g.Manifest["rolearn"] = g.RoleArn
+		}
+		g.Manifest["secretobjects"] = groupObjects[g]
+	}
+
+	return groups, nil
+}
+
+// lookupSetting - a key of a decoded yaml or json map, case insensitive like the manifest fields
+func lookupSetting(m interface{}, key string) (interface{}, bool) {
+	switch m := m.(type) {
+	case map[string]interface{}:
+		for k, v := range m {
+			if strings.EqualFold(k, key) {
+				return v, true
+			}
+		}
+	case map[interface{}]interface{}:
+		for k, v := range m {
+			if s, ok := k.(string); ok && strings.EqualFold(s, key) {
+				return v, true
+			}
+		}
+	}
+	return nil, false
+}
+
+func stringSetting(m interface{}, key string) string {
+	v, _ := lookupSetting(m, key)
+	s, _ := v.(string)
+	return s
+}
+
+func isSettingsMap(m interface{}) bool {
+	switch m.(type) {
+	case map[string]interface{}, map[interface{}]interface{}:
+		return true
+	}
+	return false
+}
+
+// CombineProviderFetches - a single fetch of all the named fetches (E.g: the groups of a manifest), writing their secrets together
+func CombineProviderFetches(names []string, fetches []*ProviderFetch, zl *zap.Logger) (*ProviderFetch, error) {
+	if len(fetches) == 1 {
+		return fetches[0], nil
+	}
+
+	combined := &ProviderFetch{PathTranslation: fetches[0].PathTranslation}
+	fetchers := make([]SecretsFetcher, 0, len(fetches))
+	for i, f := range fetches {
+		// the secrets are written by a single writer, so their output names must be derived the same way:
+		if f.PathTranslation != combined.PathTranslation {
+			return nil, fmt.Errorf("%s and %s have different path translations (%q and %q). Set the manifest's pathTranslation",
+				names[0], names[i], combined.PathTranslation, f.PathTranslation)
+		}
+
+		fetchers = append(fetchers, f.Fetcher)
+		combined.Templates = append(combined.Templates, f.Templates...)
+		combined.Required = append(combined.Required, f.Required...)
+
+		// 0 disables the timeout, so it wins over any other timeout:
+		if i == 0 || (combined.Timeout != 0 && (f.Timeout == 0 || f.Timeout > combined.Timeout)) {
+			combined.Timeout = f.Timeout
+		}
+	}
+
+	if err := checkGroupOutputs(names, fetches); err != nil {
+		return nil, err
+	}
+
+	combined.Fetcher = NewMultiFetcher(names, fetchers, combined.PathTranslation, zl)
+	combined.Done = func() {
+		for _, f := range fetches {
+			if f.Done != nil {
+				f.Done()
+			}
+		}
+	}
+	return combined, nil
+}
+
+// checkGroupOutputs - no two objects of different fetches can be written to the same output.
+// Outputs which are only known once fetched are checked by the MultiFetcher
+func checkGroupOutputs(names []string, fetches []*ProviderFetch) error {
+	type groupObject struct {
+		group  int
+		object string
+	}
+
+	outputs := map[string]groupObject{}
+	for i, f := range fetches {
+		for _, o := range f.Outputs {
+			other, ok := outputs[o.OutputName]
+			if !ok {
+				outputs[o.OutputName] = groupObject{group: i, object: o.ObjectName}
+				continue
+			}
+			if other.group != i {
+				return fmt.Errorf("objects %s (%s) and %s (%s) are both written as %q",
+					other.object, names[other.group], o.ObjectName, names[i], o.OutputName)
+			}
+		}
+	}
+	return nil
+}
+
+// MultiFetcher - fetches from several fetchers concurrently, and combines their secrets as long as no two of them have the same output name
+type MultiFetcher struct {
+	// implements ChangesFetcher
+	zl       *zap.Logger
+	names    []string
+	fetchers []SecretsFetcher
+
+	// the writer's substitution char for slashes, to detect secrets written to the same output:
+	slashConversionChar string
+}
+
+func NewMultiFetcher(
+	names []string,
+	fetchers []SecretsFetcher,
+	slashConversionChar string,
+	zl *zap.Logger) *MultiFetcher {
+	return &MultiFetcher{
+		zl:                  zl,
+		names:               names,
+		fetchers:            fetchers,
+		slashConversionChar: slashConversionChar,
+	}
+}
+
+func (mf *MultiFetcher) Fetch(ctx context.Context) ([]*Secret, error) {
+	return mf.fetch(ctx, false)
+}
+
+// FetchChanges - uses FetchChanges of the fetchers supporting it, and Fetch of the others
+func (mf *MultiFetcher) FetchChanges(ctx context.Context) ([]*Secret, error) {
+	return mf.fetch(ctx, true)
+}
+
+func (mf *MultiFetcher) fetch(ctx context.Context, changesOnly bool) ([]*Secret, error) {
+	res := make([][]*Secret, len(mf.fetchers))
+	errs := make([]error, len(mf.fetchers))
+
+	var wg sync.WaitGroup
+	for i, f := range mf.fetchers {
+		wg.Add(1)
+		go func(i int, f SecretsFetcher) {
+			defer wg.Done()
+
+			start := time.Now()
+			if cf, ok := f.(ChangesFetcher); ok && changesOnly {
+				res[i], errs[i] = cf.FetchChanges(ctx)
+			} else {
+				res[i], errs[i] = f.Fetch(ctx)
+			}
+			mf.zl.Debug("fetched secrets", zap.String("fetcher", mf.names[i]), zap.Int("count", len(res[i])), zap.Duration("duration", time.Since(start)))
+		}(i, f)
+	}
+	wg.Wait()
+
+	var result *multierror.Error
+	for i, err := range errs {
+		if err != nil {
+			mf.zl.Error("failed to fetch secrets", zap.String("fetcher", mf.names[i]), zap.Error(err))
+			result = multierror.Append(result, fmt.Errorf("%s: %w", mf.names[i], err))
+		}
+	}
+	if err := result.ErrorOrNil(); err != nil {
+		return nil, err
+	}
+
+	// Secrets from different fetchers can't be written to the same output:
+	var secretRes []*Secret
+	outputs := map[string]string{}
+	for i, secrets := range res {
+		for _, s := range secrets {
+			outputName := OutputName(s, mf.slashConversionChar)
+			if other, ok := outputs[outputName]; ok {
+				result = multierror.Append(result, fmt.Errorf("secrets %s and %s (%s) are both written to %s", other, s.Name, mf.names[i], outputName))
+				continue
+			}
+			outputs[outputName] = fmt.Sprintf("%s (%s)", s.Name, mf.names[i])
+			secretRes = append(secretRes, s)
+		}
+	}
+	if err := result.ErrorOrNil(); err != nil {
+		mf.zl.Error("conflicting output names", zap.Error(err))
+		return nil, err
+	}
+
+	return secretRes, nil
+}
@@ -0,0 +1,204 @@
+package secrets_test
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"go.uber.org/zap/zaptest"
+
+	"github.com/daniel-cohen/secretsfetcher/secrets"
+)
+
+// fakeFetcher - a fetcher without FetchChanges
+type fakeFetcher struct {
+	secrets []*secrets.Secret
+	err     error
+}
+
+func (f *fakeFetcher) Fetch(ctx context.Context) ([]*secrets.Secret, error) {
+	return f.secrets, f.err
+}
+
+var _ = Describe("Splitting a manifest by provider", func() {
+	It("keeps a single provider manifest as is", func() {
+		settings := map[string]interface{}{
+			"provider":        "vault",
+			"mount":           "kv",
+			"pathtranslation": "-",
+			"secretobjects": []interface{}{
+				map[interface{}]interface{}{"objectName": "a"},
+				map[interface{}]interface{}{"objectName": "b"},
+			},
+		}
+
+		groups, err := secrets.SplitManifest(settings, "aws")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(groups).To(HaveLen(1))
+		Expect(groups[0].Name()).To(Equal("vault"))
+		Expect(groups[0].Manifest).To(Equal(map[string]interface{}{
+			"provider":        "vault",
+			"mount":           "kv",
+			"pathtranslation": "-",
+			"secretobjects":   settings["secretobjects"],
+		}))
+	})
+
+	It("uses the default provider for manifests without a provider field", func() {
+		groups, err := secrets.SplitManifest(map[string]interface{}{}, "aws")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(groups).To(HaveLen(1))
+		Expect(groups[0].Provider).To(Equal("aws"))
+		Expect(groups[0].Manifest["secretobjects"]).To(BeEmpty())
+	})
+
+	It("groups the objects by their provider, region and role in the order of their first object", func() {
+		db := map[interface{}]interface{}{"objectName": "prod/db"}
+		token := map[interface{}]interface{}{"objectName": "my-app/api", "Provider": "vault", "objectKey": "token"}
+		license := map[interface{}]interface{}{"objectName": "/my-app/license", "objectType": "ssmparameter", "region": "eu-west-1"}
+		shared := map[string]interface{}{"objectName": "shared", "roleArn": "arn:aws:iam::123456789012:role/reader"}
+		api := map[interface{}]interface{}{"objectName": "prod/api"}
+		templates := []interface{}{map[interface{}]interface{}{"source": "a.tmpl", "destination": "a"}}
+
+		groups, err := secrets.SplitManifest(map[string]interface{}{
+			"region":          "us-east-1",
+			"pathtranslation": "-",
+			"templates":       templates,
+			"secretobjects":   []interface{}{db, token, license, shared, api},
+		}, "aws")
+		Expect(err).NotTo(HaveOccurred())
+
+		var names []string
+		for _, g := range groups {
+			names = append(names, g.Name())
+		}
+		Expect(names).To(Equal([]string{
+			"aws/us-east-1",
+			"vault/us-east-1",
+			"aws/eu-west-1",
+			"aws/us-east-1/arn:aws:iam::123456789012:role/reader",
+		}))
+
+		Expect(groups[0].Manifest).To(Equal(map[string]interface{}{
+			"provider":        "aws",
+			"region":          "us-east-1",
+			"pathtranslation": "-",
+			"templates":       templates,
+			"secretobjects":   []interface{}{db, api},
+		}))
+		Expect(groups[1].Manifest).To(Equal(map[string]interface{}{
+			"provider":        "vault",
+			"region":          "us-east-1",
+			"pathtranslation": "-",
+			"secretobjects":   []interface{}{token},
+		}))
+		Expect(groups[2].Manifest["region"]).To(Equal("eu-west-1"))
+		Expect(groups[2].Manifest["secretobjects"]).To(Equal([]interface{}{license}))
+		Expect(groups[3].Manifest["rolearn"]).To(Equal("arn:aws:iam::123456789012:role/reader"))
+		Expect(groups[3].Manifest["secretobjects"]).To(Equal([]interface{}{shared}))
+	})
+
+	It("fails on objects which aren't maps", func() {
+		_, err := secrets.SplitManifest(map[string]interface{}{"secretobjects": []interface{}{"a"}}, "aws")
+		Expect(err).To(HaveOccurred())
+	})
+})
+
+var _ = Describe("Combining provider fetches", func() {
+	var (
+		aws   *fakeChangesFetcher
+		vault *fakeFetcher
+	)
+
+	BeforeEach(func() {
+		aws = &fakeChangesFetcher{secrets: []*secrets.Secret{{Name: "prod/db", Content: "db"}}}
+		vault = &fakeFetcher{secrets: []*secrets.Secret{{Name: "my-app/api", Alias: "api-token", Content: "token"}}}
+	})
+
+	combine := func(fetches ...*secrets.ProviderFetch) (*secrets.ProviderFetch, error) {
+		return secrets.CombineProviderFetches([]string{"aws", "vault"}[:len(fetches)], fetches, zaptest.NewLogger(GinkgoT()))
+	}
+
+	It("returns a single fetch as is", func() {
+		fetch := &secrets.ProviderFetch{Fetcher: aws}
+		combined, err := combine(fetch)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(combined).To(BeIdenticalTo(fetch))
+	})
+
+	It("fetches the secrets of all the fetchers", func() {
+		done := 0
+		combined, err := combine(
+			&secrets.ProviderFetch{Fetcher: aws, PathTranslation: "_", Required: []string{"prod/db"}, Timeout: time.Minute, Done: func() { done++ }},
+			&secrets.ProviderFetch{Fetcher: vault, PathTranslation: "_", Required: []string{"api-token"}, Timeout: 2 * time.Minute},
+		)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(combined.Required).To(Equal([]string{"prod/db", "api-token"}))
+		Expect(combined.Timeout).To(Equal(2 * time.Minute))
+
+		res, err := combined.Fetcher.Fetch(context.Background())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(res).To(Equal(append(aws.secrets, vault.secrets...)))
+
+		cf, ok := combined.Fetcher.(secrets.ChangesFetcher)
+		Expect(ok).To(BeTrue())
+		_, err = cf.FetchChanges(context.Background())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(aws.changeCalls).To(BeEquivalentTo(1))
+		Expect(aws.fetches).To(BeEquivalentTo(1))
+
+		combined.Done()
+		Expect(done).To(Equal(1))
+	})
+
+	It("disables the timeout if any fetch has none", func() {
+		combined, err := combine(
+			&secrets.ProviderFetch{Fetcher: aws, Timeout: time.Minute},
+			&secrets.ProviderFetch{Fetcher: vault},
+		)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(combined.Timeout).To(BeZero())
+	})
+
+	It("fails on different path translations", func() {
+		_, err := combine(
+			&secrets.ProviderFetch{Fetcher: aws, PathTranslation: "_"},
+			&secrets.ProviderFetch{Fetcher: vault, PathTranslation: "-"},
+		)
+		Expect(err).To(MatchError(ContainSubstring("different path translations")))
+	})
+
+	It("fails with the errors of each failed fetcher", func() {
+		aws.err = errors.New("access denied")
+		vault.err = errors.New("permission denied")
+		combined, err := combine(&secrets.ProviderFetch{Fetcher: aws}, &secrets.ProviderFetch{Fetcher: vault})
+		Expect(err).NotTo(HaveOccurred())
+
+		_, err = combined.Fetcher.Fetch(context.Background())
+		Expect(err).To(MatchError(ContainSubstring("aws: access denied")))
+		Expect(err).To(MatchError(ContainSubstring("vault: permission denied")))
+	})
+
+	It("fails on objects of different fetches written to the same output before fetching", func() {
+		_, err := combine(
+			&secrets.ProviderFetch{Fetcher: aws, PathTranslation: "_", Outputs: []secrets.ObjectOutput{{ObjectName: "prod/db", OutputName: "prod_db"}}},
+			&secrets.ProviderFetch{Fetcher: vault, PathTranslation: "_", Outputs: []secrets.ObjectOutput{{ObjectName: "shared/db", OutputName: "prod_db"}}},
+		)
+		Expect(err).To(MatchError(`objects prod/db (aws) and shared/db (vault) are both written as "prod_db"`))
+		Expect(aws.fetches).To(BeZero())
+	})
+
+	It("fails on secrets of different fetchers written to the same output", func() {
+		vault.secrets = []*secrets.Secret{{Name: "shared/db", Alias: "prod_db"}}
+		combined, err := combine(
+			&secrets.ProviderFetch{Fetcher: aws, PathTranslation: "_"},
+			&secrets.ProviderFetch{Fetcher: vault, PathTranslation: "_"},
+		)
+		Expect(err).NotTo(HaveOccurred())
+
+		_, err = combined.Fetcher.Fetch(context.Background())
+		Expect(err).To(MatchError(ContainSubstring("secrets prod/db (aws) and shared/db (vault) are both written to prod_db")))
+	})
+})
@@ -0,0 +1,133 @@
+package secrets
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/pflag"
+	"go.uber.org/zap"
+)
+
+const (
+	// DefaultPathTranslation - the default substitution char for slashes in secret names
+	DefaultPathTranslation = "_"
+
+	// PathTranslationFalse - disables the slash substitution
+	PathTranslationFalse = "False"
+)
+
+// PathTranslationChar - the substitution char for slashes in secret names. Empty defaults to "_", "False" disables it
+func PathTranslationChar(pathTranslation string) string {
+	switch pathTranslation {
+	case "":
+		return DefaultPathTranslation
+	case PathTranslationFalse:
+		return ""
+	default:
+		return pathTranslation
+	}
+}
+
+// Decoder - decodes a provider's config section, or a manifest, into the provider's struct
+type Decoder func(out interface{}) error
+
+// ProviderUsage - the help of a provider's command
+type ProviderUsage struct {
+	Short   string
+	Long    string
+	Example string
+}
+
+// ProviderFetch - a provider's fetcher, and how its secrets are written
+type ProviderFetch struct {
+	Fetcher SecretsFetcher
+
+	// the substitution char for slashes in secret names ("" disables it)
+	PathTranslation string
+
+	// the manifest's templates, rendered by the template output format
+	Templates []*TemplateSpec
+
+	// the names of the secrets the readiness probe waits for (all of them if empty)
+	Required []string
+
+	// the output names of the manifest objects which are known before fetching (E.g: not of unaliased objects requested by arn),
+	// to detect objects of different providers written to the same output before fetching them
+	Outputs []ObjectOutput
+
+	// the overall timeout for fetching and writing the secrets. 0 disables it
+	Timeout time.Duration
+
+	// an optional callback once the fetcher is no longer used. E.g: logging its retries
+	Done func()
+}
+
+// Provider - a secrets backend, selected by the manifest's provider field
+type Provider interface {
+	// Name - the manifest's provider field, and the provider's config section (E.g: vault reads Vault: and the APP_VAULT_* env vars)
+	Name() string
+
+	Usage() ProviderUsage
+
+	// ConfigDefaults - the defaults of the provider's config fields. Only fields with defaults are read from env vars
+	ConfigDefaults() map[string]interface{}
+
+	// AddFlags - adds the provider's flags, returning the config fields they set (by flag name)
+	AddFlags(flags *pflag.FlagSet) map[string]string
+
+	// NewFetch - the fetcher of the manifest, or of the provider's manifestless mode (E.g: listing by tags) if manifest is nil
+	NewFetch(ctx context.Context, config Decoder, manifest Decoder, zl *zap.Logger) (*ProviderFetch, error)
+}
+
+var (
+	providersMu sync.RWMutex
+	providers   = map[string]Provider{}
+)
+
+// RegisterProvider - makes a provider available by its name. Provider packages register themselves in their init, like database/sql drivers
+func RegisterProvider(p Provider) {
+	providersMu.Lock()
+	defer providersMu.Unlock()
+
+	if _, ok := providers[p.Name()]; ok {
+		panic(fmt.Sprintf("secrets: provider %q registered twice", p.Name()))
+	}
+	providers[p.Name()] = p
+}
+
+// LookupProvider - the registered provider with the name
+func LookupProvider(name string) (Provider, error) {
+	providersMu.RLock()
+	defer providersMu.RUnlock()
+
+	p, ok := providers[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown provider %q (expected one of: %s)", name, strings.Join(providerNames(), ", "))
+	}
+	return p, nil
+}
+
+// Providers - the registered providers, by name
+func Providers() []Provider {
+	providersMu.RLock()
+	defer providersMu.RUnlock()
+
+	res := make([]Provider, 0, len(providers))
+	for _, name := range providerNames() {
+		res = append(res, providers[name])
+	}
+	return res
+}
+
+func providerNames() []string {
+	names := make([]string, 0, len(providers))
+	for name := range providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,74 @@
+package secrets_test
+
+import (
+	"context"
+	"sort"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+	"github.com/spf13/pflag"
+	"go.uber.org/zap"
+
+	"github.com/daniel-cohen/secretsfetcher/secrets"
+)
+
+type fakeProvider struct {
+	name string
+}
+
+func (p fakeProvider) Name() string { return p.name }
+
+func (p fakeProvider) Usage() secrets.ProviderUsage { return secrets.ProviderUsage{} }
+
+func (p fakeProvider) ConfigDefaults() map[string]interface{} { return nil }
+
+func (p fakeProvider) AddFlags(flags *pflag.FlagSet) map[string]string { return nil }
+
+func (p fakeProvider) NewFetch(ctx context.Context, config secrets.Decoder, manifest secrets.Decoder, zl *zap.Logger) (*secrets.ProviderFetch, error) {
+	return &secrets.ProviderFetch{}, nil
+}
+
+var _ = Describe("The provider registry", func() {
+	BeforeEach(func() {
+		for _, name := range []string{"registry-test-b", "registry-test-a"} {
+			if _, err := secrets.LookupProvider(name); err != nil {
+				secrets.RegisterProvider(fakeProvider{name: name})
+			}
+		}
+	})
+
+	It("looks up a registered provider by name", func() {
+		p, err := secrets.LookupProvider("registry-test-a")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(p.Name()).To(Equal("registry-test-a"))
+	})
+
+	It("fails on an unknown provider, listing the known ones", func() {
+		_, err := secrets.LookupProvider("nope")
+		Expect(err).To(MatchError(ContainSubstring(`unknown provider "nope"`)))
+		Expect(err).To(MatchError(ContainSubstring("registry-test-a, registry-test-b")))
+	})
+
+	It("lists the providers by name", func() {
+		var names []string
+		for _, p := range secrets.Providers() {
+			names = append(names, p.Name())
+		}
+		Expect(names).To(ContainElements("registry-test-a", "registry-test-b"))
+		Expect(sort.StringsAreSorted(names)).To(BeTrue())
+	})
+
+	It("panics when a provider is registered twice", func() {
+		Expect(func() { secrets.RegisterProvider(fakeProvider{name: "registry-test-a"}) }).To(Panic())
+	})
+})
+
+var _ = DescribeTable("Path translation chars",
+	func(pathTranslation string, expected string) {
+		Expect(secrets.PathTranslationChar(pathTranslation)).To(Equal(expected))
+	},
+	Entry("default", "", "_"),
+	Entry("disabled", "False", ""),
+	Entry("custom", "-", "-"),
+)
@@ -0,0 +1,9 @@
+// Package providers - registers all the secrets providers. New providers are added to the imports
+package providers
+
+import (
+	_ "github.com/daniel-cohen/secretsfetcher/secrets/aws"
+	_ "github.com/daniel-cohen/secretsfetcher/secrets/azure"
+	_ "github.com/daniel-cohen/secretsfetcher/secrets/gcp"
+	_ "github.com/daniel-cohen/secretsfetcher/secrets/vault"
+)
@@ -0,0 +1,43 @@
+package secrets
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// reservedPrefix - names starting with ".." are reserved for the writers' own bookkeeping (e.g: ..data)
+const reservedPrefix = ".."
+
+// SafeOutputPath - joins the output file name to the output folder.
+// Names which are empty, absolute, contain NUL bytes, are reserved or resolve outside of the output folder are rejected.
+func SafeOutputPath(outputFolder string, name string) (string, error) {
+	if name == "" {
+		return "", fmt.Errorf("empty output file name")
+	}
+
+	if strings.ContainsRune(name, 0) {
+		return "", fmt.Errorf("output file name %q contains a NUL byte", name)
+	}
+
+	if filepath.IsAbs(name) || strings.HasPrefix(name, "/") || strings.HasPrefix(name, `\`) {
+		return "", fmt.Errorf("output file name %q is an absolute path", name)
+	}
+
+	cleaned := filepath.Clean(name)
+	if cleaned == "." {
+		return "", fmt.Errorf("output file name %q resolves to the output folder itself", name)
+	}
+
+	if !filepath.IsLocal(cleaned) {
+		return "", fmt.Errorf("output file name %q resolves outside of the output folder", name)
+	}
+
+	for _, segment := range strings.Split(filepath.ToSlash(cleaned), "/") {
+		if strings.HasPrefix(segment, reservedPrefix) {
+			return "", fmt.Errorf("output file name %q uses a reserved name", name)
+		}
+	}
+
+	return filepath.Join(outputFolder, cleaned), nil
+}
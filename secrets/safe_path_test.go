@@ -0,0 +1,37 @@
+package secrets_test
+
+import (
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+
+	"github.com/daniel-cohen/secretsfetcher/secrets"
+)
+
+var _ = DescribeTable("Resolving safe output paths",
+	func(name string, expected string, expectError bool) {
+		p, err := secrets.SafeOutputPath("/out", name)
+		if expectError {
+			Expect(err).To(HaveOccurred())
+			return
+		}
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(p).To(Equal(filepath.FromSlash(expected)))
+	},
+	Entry("plain name", "secret", "/out/secret", false),
+	Entry("translated name", "my_secret_name", "/out/my_secret_name", false),
+	Entry("nested name", "my/secret", "/out/my/secret", false),
+	Entry("dot segments inside the folder", "a/../b", "/out/b", false),
+	Entry("single dot prefix", ".hidden", "/out/.hidden", false),
+	Entry("empty", "", "", true),
+	Entry("parent folder", "..", "", true),
+	Entry("traversal", "../../etc/cron.d/x", "", true),
+	Entry("traversal after a folder", "a/../../x", "", true),
+	Entry("absolute", "/etc/passwd", "", true),
+	Entry("NUL byte", "secret\x00.txt", "", true),
+	Entry("current folder", ".", "", true),
+	Entry("reserved name", "..data", "", true),
+	Entry("reserved nested name", "a/..data", "", true),
+)
@@ -3,4 +3,17 @@ package secrets
 type Secret struct {
 	Name    string
 	Content string
+
+	// Alias - an optional explicit output name. When set, writers use it as is instead of deriving one from the Name
+	Alias string
+
+	// Version - the version of the content (e.g: the secrets manager VersionId) if known
+	Version string
+
+	// Permissions - optional overrides of the writer's file mode and ownership for this secret
+	Permissions *FilePermissions
+
+	// Object - the manifest object name the secret was fetched for, if known. The readiness is tracked by it,
+	// as the fetched name can differ from it (E.g: objects requested by arn)
+	Object string
 }
@@ -1,9 +1,11 @@
 package secrets
 
 import (
-	"io/ioutil"
+	"bytes"
+	"context"
+	"fmt"
 	"os"
-	"path"
+	"path/filepath"
 	"strings"
 
 	"github.com/hashicorp/go-multierror"
@@ -12,15 +14,20 @@ import (
 
 // We can have secrets written to different outputs. E.g: to std out, files, etc..
 type SecretWriter interface {
-	// slashConversionChar- if the secret name contains slashes, we'll convert them to this char:
-	WriteSecrets(secretRes []*Secret)
+	WriteSecrets(ctx context.Context, secretRes []*Secret) error
 }
 
 type FileSecretWriter struct {
+	// implements SecretWriter
 	zl                  *zap.Logger
 	outputFolder        string
 	stopOnWriteError    bool
 	slashConversionChar string
+
+	fileMode os.FileMode
+	dirMode  os.FileMode
+	uid      int
+	gid      int
 }
 
 // StopOnError - the the stopOnWriteError flag which will cause the writer to stop write errors.
@@ -29,6 +36,25 @@ func (sfw *FileSecretWriter) StopOnError() *FileSecretWriter {
 	return sfw
 }
 
+// WithFileMode - the mode of the written secret files (regardless of the umask)
+func (sfw *FileSecretWriter) WithFileMode(mode os.FileMode) *FileSecretWriter {
+	sfw.fileMode = mode
+	return sfw
+}
+
+// WithDirMode - the mode of the output folder (if created) and the folders inside it
+func (sfw *FileSecretWriter) WithDirMode(mode os.FileMode) *FileSecretWriter {
+	sfw.dirMode = mode
+	return sfw
+}
+
+// WithOwner - the uid and gid of the written files and folders. KeepOwner leaves them as the user running the process
+func (sfw *FileSecretWriter) WithOwner(uid int, gid int) *FileSecretWriter {
+	sfw.uid = uid
+	sfw.gid = gid
+	return sfw
+}
+
 func NewFileSecretWriter(
 	outputFolder string,
 	slashConversionChar string,
@@ -37,21 +63,125 @@ func NewFileSecretWriter(
 		zl:                  zl,
 		outputFolder:        outputFolder,
 		slashConversionChar: slashConversionChar,
+		fileMode:            DefaultFileMode,
+		dirMode:             DefaultDirMode,
+		uid:                 KeepOwner,
+		gid:                 KeepOwner,
+	}
+}
+
+// filePermissions - the writer's file mode and owner with the secret's overrides applied
+func (sw *FileSecretWriter) filePermissions(s *Secret) FilePermissions {
+	perms := FilePermissions{Mode: sw.fileMode, UID: sw.uid, GID: sw.gid}
+	if s.Permissions == nil {
+		return perms
+	}
+
+	if s.Permissions.Mode != 0 {
+		perms.Mode = s.Permissions.Mode
 	}
+	if s.Permissions.UID != KeepOwner {
+		perms.UID = s.Permissions.UID
+	}
+	if s.Permissions.GID != KeepOwner {
+		perms.GID = s.Permissions.GID
+	}
+	return perms
+}
+
+// dirPermissions - the mode and owner of the folders created by the writer
+func (sw *FileSecretWriter) dirPermissions() FilePermissions {
+	return FilePermissions{Mode: sw.dirMode, UID: sw.uid, GID: sw.gid}
+}
+
+// outputFileName - the secret alias if set, otherwise the secret name with its slashes converted
+func (sw *FileSecretWriter) outputFileName(s *Secret) string {
+	return OutputName(s, sw.slashConversionChar)
+}
+
+// OutputName - the secret alias if set, otherwise the secret name with its slashes replaced with the slashConversionChar (if set)
+func OutputName(s *Secret, slashConversionChar string) string {
+	if s.Alias != "" {
+		return s.Alias
+	}
+
+	outputName := s.Name
+	if slashConversionChar != "" {
+		outputName = strings.ReplaceAll(outputName, "/", slashConversionChar)
+	}
+	return outputName
+}
+
+// checkOutputNames - makes sure every secret has a safe output file name and no two secrets are written to the same file
+func (sw *FileSecretWriter) checkOutputNames(secretRes []*Secret) error {
+	var result *multierror.Error
+	names := map[string]string{}
+	for _, v := range secretRes {
+		outputFileName := sw.outputFileName(v)
+		if _, err := SafeOutputPath(sw.outputFolder, outputFileName); err != nil {
+			result = multierror.Append(result, fmt.Errorf("secret %s: %w", v.Name, err))
+			continue
+		}
+
+		if other, ok := names[outputFileName]; ok {
+			result = multierror.Append(result, fmt.Errorf("secrets %s and %s are both written to %s", other, v.Name, outputFileName))
+			continue
+		}
+		names[outputFileName] = v.Name
+	}
+
+	return result.ErrorOrNil()
 }
 
 // writeSecrets - writes the secrets to disk. If outputFolder is empty we'll output to the current folder
 // slashConversionChar - all slashes win the secret name will be replaced with this char.
 // If empty, no replacement will occur (can fail on wrie to the disk)
-func (sw *FileSecretWriter) WriteSecrets(secretRes []*Secret) error {
+func (sw *FileSecretWriter) WriteSecrets(ctx context.Context, secretRes []*Secret) error {
 
 	// pathTranslationChar := sw.awsCfg.PathTranslation
 	// if slashConversionChar != "" {
 	// 	pathTranslationChar = slashConversionChar
 	// }
 
+	// Make sure every file is written inside the output folder, and no two secrets are written to the same file before writing anything:
+	if err := sw.checkOutputNames(secretRes); err != nil {
+		sw.zl.Error("invalid output file names", zap.Error(err))
+		return err
+	}
+
+	outputFolder := sw.outputFolder
+	if outputFolder == "" {
+		outputFolder = "."
+	}
+
+	if err := sw.createOutputFolder(outputFolder); err != nil {
+		sw.zl.Error("failed to create the output folder", zap.String("output_folder", outputFolder), zap.Error(err))
+		return err
+	}
+
+	// Unchanged files are linked from the current generation instead of being written again:
+	previousGenerationDir := currentGeneration(outputFolder)
+
+	// Everything is written to a new generation first, and only made visible once all the files are written:
+	generationDir, err := newGeneration(outputFolder)
+	if err == nil {
+		err = setPermissions(generationDir, sw.dirPermissions())
+	}
+	if err != nil {
+		sw.zl.Error("failed to create a new secrets folder", zap.String("output_folder", outputFolder), zap.Error(err))
+		os.RemoveAll(generationDir)
+		return err
+	}
+
 	var result *multierror.Error
+	names := map[string]bool{}
 	for _, v := range secretRes {
+		// Don't start writing more files if we've been cancelled:
+		if err := ctx.Err(); err != nil {
+			os.RemoveAll(generationDir)
+			return multierror.Append(result, err)
+		}
+
 		// outputFileName := v.Name
 		// if pathTranslationChar != "" {
 		// 	if pathTranslationChar != pathTranslationFalse {
@@ -62,20 +192,21 @@ func (sw *FileSecretWriter) WriteSecrets(secretRes []*Secret) error {
 
 		// }
 
-		outputFileName := v.Name
-		if sw.slashConversionChar != "" {
-			outputFileName = strings.ReplaceAll(outputFileName, "/", sw.slashConversionChar)
+		outputFileName := sw.outputFileName(v)
+		outputFilePath, err := SafeOutputPath(generationDir, outputFileName)
+		if err != nil {
+			os.RemoveAll(generationDir)
+			return err
 		}
 
-		outputFilePath := path.Join(sw.outputFolder, outputFileName)
-
 		sw.zl.Info("writing secret to file",
-			zap.String("file_path", outputFilePath),
+			zap.String("file_path", filepath.Join(outputFolder, outputFileName)),
 			zap.String("secret_name", v.Name),
 		)
-		if err := ioutil.WriteFile(outputFilePath, []byte(v.Content), os.ModePerm); err != nil {
+		if err := sw.writeFile(generationDir, previousGenerationDir, outputFileName, []byte(v.Content), sw.filePermissions(v)); err != nil {
 			sw.zl.Error("failed to write file", zap.String("file_path", outputFilePath), zap.Error(err))
 			if sw.stopOnWriteError {
+				os.RemoveAll(generationDir)
 				return err
 			}
 
@@ -84,9 +215,120 @@ func (sw *FileSecretWriter) WriteSecrets(secretRes []*Secret) error {
 			result = multierror.Append(result, err)
 			continue
 		}
+		names[topLevelName(outputFileName)] = true
+	}
+
+	// Swap ..data to the new generation, so readers see all the new secrets at once:
+	if err := swapDataDir(outputFolder, generationDir); err != nil {
+		sw.zl.Error("failed to swap the secrets folder", zap.String("output_folder", outputFolder), zap.Error(err))
+		os.RemoveAll(generationDir)
+		return multierror.Append(result, err)
+	}
+
+	if err := updateVisiblePaths(outputFolder, names); err != nil {
+		sw.zl.Error("failed to link the secret files", zap.String("output_folder", outputFolder), zap.Error(err))
+		result = multierror.Append(result, err)
+	}
 
+	if err := cleanupGenerations(outputFolder, generationDir); err != nil {
+		// The new secrets are already in place, nothing to fail on:
+		sw.zl.Warn("failed to remove old secrets folders", zap.String("output_folder", outputFolder), zap.Error(err))
 	}
 
 	// Returning a multierror only if there are errors
 	return result.ErrorOrNil()
 }
+
+// createOutputFolder - creates the output folder with the configured mode and owner if it's missing
+func (sw *FileSecretWriter) createOutputFolder(outputFolder string) error {
+	if _, err := os.Stat(outputFolder); !os.IsNotExist(err) {
+		return err
+	}
+
+	sw.zl.Info("creating the output folder", zap.String("output_folder", outputFolder))
+	if err := os.MkdirAll(outputFolder, sw.dirMode); err != nil {
+		return err
+	}
+	return setPermissions(outputFolder, sw.dirPermissions())
+}
+
+// writeFile - writes a single secret file (and the folders in its name) inside the generation folder.
+// If the previous generation has the same content the file is hard linked from it instead.
+func (sw *FileSecretWriter) writeFile(generationDir string, previousGenerationDir string, name string, content []byte, perms FilePermissions) error {
+	dir := generationDir
+	for _, part := range strings.Split(filepath.ToSlash(filepath.Dir(name)), "/") {
+		if part == "." {
+			continue
+		}
+
+		dir = filepath.Join(dir, part)
+		if err := os.Mkdir(dir, 0700); err != nil {
+			if os.IsExist(err) {
+				continue
+			}
+			return err
+		}
+		if err := setPermissions(dir, sw.dirPermissions()); err != nil {
+			return err
+		}
+	}
+
+	path := filepath.Join(generationDir, name)
+	if previousGenerationDir != "" {
+		previousPath := filepath.Join(previousGenerationDir, name)
+		if previous, err := os.ReadFile(previousPath); err == nil && bytes.Equal(previous, content) {
+			if err := os.Link(previousPath, path); err == nil {
+				sw.zl.Debug("secret file unchanged", zap.String("file_path", path))
+				return setPermissions(path, perms)
+			}
+		}
+	}
+
+	// Only the process user can read the file until its permissions are set:
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(content); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	return setPermissions(path, perms)
+}
+
+// setPermissions - sets the exact mode (regardless of the umask) and owner of the path, and verifies them
+func setPermissions(path string, perms FilePermissions) error {
+	if err := os.Chmod(path, perms.Mode); err != nil {
+		return err
+	}
+
+	if perms.UID != KeepOwner || perms.GID != KeepOwner {
+		if err := os.Chown(path, perms.UID, perms.GID); err != nil {
+			return err
+		}
+	}
+
+	fi, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+
+	if fi.Mode().Perm() != perms.Mode.Perm() {
+		return fmt.Errorf("%s has mode %04o instead of %04o", path, fi.Mode().Perm(), perms.Mode.Perm())
+	}
+
+	if uid, gid, ok := fileOwner(fi); ok {
+		if perms.UID != KeepOwner && uid != perms.UID {
+			return fmt.Errorf("%s is owned by uid %d instead of %d", path, uid, perms.UID)
+		}
+		if perms.GID != KeepOwner && gid != perms.GID {
+			return fmt.Errorf("%s is owned by gid %d instead of %d", path, gid, perms.GID)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,242 @@
+package secrets_test
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"syscall"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"go.uber.org/zap/zaptest"
+
+	"github.com/daniel-cohen/secretsfetcher/secrets"
+)
+
+var _ = Describe("Writing secrets to files", func() {
+	var (
+		outputFolder string
+		writer       *secrets.FileSecretWriter
+	)
+
+	BeforeEach(func() {
+		var err error
+		outputFolder, err = ioutil.TempDir("", "secretsfetcher")
+		Expect(err).NotTo(HaveOccurred())
+
+		writer = secrets.NewFileSecretWriter(outputFolder, "_", zaptest.NewLogger(GinkgoT()))
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(outputFolder)
+	})
+
+	readFile := func(name string) string {
+		b, err := ioutil.ReadFile(filepath.Join(outputFolder, name))
+		Expect(err).NotTo(HaveOccurred())
+		return string(b)
+	}
+
+	It("translates slashes in secret names", func() {
+		Expect(writer.WriteSecrets(context.Background(), []*secrets.Secret{{Name: "my/secret/name", Content: "value"}})).To(Succeed())
+		Expect(readFile("my_secret_name")).To(Equal("value"))
+	})
+
+	It("uses the alias as the file name", func() {
+		Expect(writer.WriteSecrets(context.Background(), []*secrets.Secret{
+			{Name: "arn:aws:secretsmanager:us-west-2:111122223333:secret:aes128-1a2b3c", Content: "value", Alias: "aes128"},
+		})).To(Succeed())
+		Expect(readFile("aes128")).To(Equal("value"))
+	})
+
+	It("fails on duplicate output names without writing anything", func() {
+		err := writer.WriteSecrets(context.Background(), []*secrets.Secret{
+			{Name: "secret1", Content: "value1"},
+			{Name: "secret2", Content: "value2", Alias: "secret1"},
+		})
+		Expect(err).To(HaveOccurred())
+
+		files, err := ioutil.ReadDir(outputFolder)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(files).To(BeEmpty())
+	})
+
+	It("rejects secrets which would be written outside of the output folder", func() {
+		writer = secrets.NewFileSecretWriter(filepath.Join(outputFolder, "nested"), "", zaptest.NewLogger(GinkgoT()))
+		Expect(os.Mkdir(filepath.Join(outputFolder, "nested"), 0700)).To(Succeed())
+
+		err := writer.WriteSecrets(context.Background(), []*secrets.Secret{
+			{Name: "fine", Content: "value"},
+			{Name: "../escaped", Content: "value"},
+			{Name: "/etc/absolute", Content: "value"},
+		})
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("../escaped"))
+		Expect(err.Error()).To(ContainSubstring("/etc/absolute"))
+
+		// nothing was written:
+		_, err = os.Stat(filepath.Join(outputFolder, "escaped"))
+		Expect(os.IsNotExist(err)).To(BeTrue())
+		_, err = os.Stat(filepath.Join(outputFolder, "nested", "fine"))
+		Expect(os.IsNotExist(err)).To(BeTrue())
+	})
+
+	Describe("atomic writes", func() {
+		generations := func() []string {
+			matches, err := filepath.Glob(filepath.Join(outputFolder, "..[0-9]*"))
+			Expect(err).NotTo(HaveOccurred())
+			return matches
+		}
+
+		It("links the secret files through the ..data symlink", func() {
+			Expect(writer.WriteSecrets(context.Background(), []*secrets.Secret{
+				{Name: "secret1", Content: "value1"},
+				{Name: "secret2", Content: "value2", Alias: "nested/secret2"},
+			})).To(Succeed())
+
+			dataDir, err := os.Readlink(filepath.Join(outputFolder, "..data"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(generations()).To(ConsistOf(filepath.Join(outputFolder, dataDir)))
+
+			link, err := os.Readlink(filepath.Join(outputFolder, "secret1"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(link).To(Equal(filepath.Join("..data", "secret1")))
+
+			Expect(readFile("secret1")).To(Equal("value1"))
+			Expect(readFile("nested/secret2")).To(Equal("value2"))
+		})
+
+		It("replaces the previous set of secrets and removes old generations", func() {
+			Expect(writer.WriteSecrets(context.Background(), []*secrets.Secret{
+				{Name: "secret1", Content: "old1"},
+				{Name: "secret2", Content: "old2"},
+			})).To(Succeed())
+
+			Expect(writer.WriteSecrets(context.Background(), []*secrets.Secret{
+				{Name: "secret1", Content: "new1"},
+				{Name: "secret3", Content: "new3"},
+			})).To(Succeed())
+
+			Expect(readFile("secret1")).To(Equal("new1"))
+			Expect(readFile("secret3")).To(Equal("new3"))
+			_, err := os.Lstat(filepath.Join(outputFolder, "secret2"))
+			Expect(os.IsNotExist(err)).To(BeTrue())
+
+			Expect(generations()).To(HaveLen(1))
+		})
+
+		It("only rewrites the changed files", func() {
+			Expect(writer.WriteSecrets(context.Background(), []*secrets.Secret{
+				{Name: "secret1", Content: "value1"},
+				{Name: "secret2", Content: "value2"},
+			})).To(Succeed())
+
+			before1, err := os.Stat(filepath.Join(outputFolder, "secret1"))
+			Expect(err).NotTo(HaveOccurred())
+			before2, err := os.Stat(filepath.Join(outputFolder, "secret2"))
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(writer.WriteSecrets(context.Background(), []*secrets.Secret{
+				{Name: "secret1", Content: "value1"},
+				{Name: "secret2", Content: "rotated"},
+			})).To(Succeed())
+
+			after1, err := os.Stat(filepath.Join(outputFolder, "secret1"))
+			Expect(err).NotTo(HaveOccurred())
+			after2, err := os.Stat(filepath.Join(outputFolder, "secret2"))
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(os.SameFile(before1, after1)).To(BeTrue())
+			Expect(os.SameFile(before2, after2)).To(BeFalse())
+			Expect(readFile("secret2")).To(Equal("rotated"))
+		})
+
+		It("keeps the previous secrets when cancelled", func() {
+			Expect(writer.WriteSecrets(context.Background(), []*secrets.Secret{{Name: "secret1", Content: "old1"}})).To(Succeed())
+
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			Expect(writer.WriteSecrets(ctx, []*secrets.Secret{{Name: "secret1", Content: "new1"}})).NotTo(Succeed())
+
+			Expect(readFile("secret1")).To(Equal("old1"))
+			Expect(generations()).To(HaveLen(1))
+		})
+
+		It("replaces regular files left by a previous version", func() {
+			Expect(ioutil.WriteFile(filepath.Join(outputFolder, "secret1"), []byte("old1"), 0600)).To(Succeed())
+			Expect(ioutil.WriteFile(filepath.Join(outputFolder, "unrelated"), []byte("keep"), 0600)).To(Succeed())
+
+			Expect(writer.WriteSecrets(context.Background(), []*secrets.Secret{{Name: "secret1", Content: "new1"}})).To(Succeed())
+
+			Expect(readFile("secret1")).To(Equal("new1"))
+			Expect(readFile("unrelated")).To(Equal("keep"))
+		})
+	})
+
+	Describe("permissions", func() {
+		mode := func(name string) os.FileMode {
+			fi, err := os.Stat(filepath.Join(outputFolder, name))
+			Expect(err).NotTo(HaveOccurred())
+			return fi.Mode().Perm()
+		}
+
+		It("writes files with the default mode", func() {
+			Expect(writer.WriteSecrets(context.Background(), []*secrets.Secret{{Name: "secret1", Content: "value1"}})).To(Succeed())
+			Expect(mode("secret1")).To(Equal(secrets.DefaultFileMode))
+			Expect(mode("..data")).To(Equal(secrets.DefaultDirMode))
+		})
+
+		It("writes files with the configured modes regardless of the umask", func() {
+			writer.WithFileMode(0666).WithDirMode(0750)
+			Expect(writer.WriteSecrets(context.Background(), []*secrets.Secret{
+				{Name: "secret1", Content: "value1", Alias: "nested/secret1"},
+			})).To(Succeed())
+
+			Expect(mode("nested/secret1")).To(Equal(os.FileMode(0666)))
+			Expect(mode("nested")).To(Equal(os.FileMode(0750)))
+		})
+
+		It("applies the secret's overrides", func() {
+			Expect(writer.WriteSecrets(context.Background(), []*secrets.Secret{
+				{Name: "secret1", Content: "value1"},
+				{Name: "secret2", Content: "value2", Permissions: &secrets.FilePermissions{Mode: 0400, UID: secrets.KeepOwner, GID: secrets.KeepOwner}},
+			})).To(Succeed())
+
+			Expect(mode("secret1")).To(Equal(secrets.DefaultFileMode))
+			Expect(mode("secret2")).To(Equal(os.FileMode(0400)))
+		})
+
+		It("creates a missing output folder with the dir mode", func() {
+			missingFolder := filepath.Join(outputFolder, "missing", "secrets")
+			writer = secrets.NewFileSecretWriter(missingFolder, "_", zaptest.NewLogger(GinkgoT())).WithDirMode(0700)
+
+			Expect(writer.WriteSecrets(context.Background(), []*secrets.Secret{{Name: "secret1", Content: "value1"}})).To(Succeed())
+			Expect(readFile("missing/secrets/secret1")).To(Equal("value1"))
+			Expect(mode("missing/secrets")).To(Equal(os.FileMode(0700)))
+		})
+
+		It("sets the configured owner", func() {
+			if os.Geteuid() != 0 {
+				Skip("only root can change the owner of files")
+			}
+
+			writer.WithOwner(1234, 5678)
+			Expect(writer.WriteSecrets(context.Background(), []*secrets.Secret{{Name: "secret1", Content: "value1"}})).To(Succeed())
+
+			fi, err := os.Stat(filepath.Join(outputFolder, "secret1"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(fi.Sys().(*syscall.Stat_t).Uid).To(BeEquivalentTo(1234))
+			Expect(fi.Sys().(*syscall.Stat_t).Gid).To(BeEquivalentTo(5678))
+		})
+
+		It("fails when the owner cannot be set", func() {
+			if os.Geteuid() == 0 {
+				Skip("root can change the owner of any file")
+			}
+
+			writer.WithOwner(0, 0)
+			Expect(writer.WriteSecrets(context.Background(), []*secrets.Secret{{Name: "secret1", Content: "value1"}})).NotTo(Succeed())
+		})
+	})
+})
@@ -1,5 +1,16 @@
 package secrets
 
+import "context"
+
 type SecretsFetcher interface {
-	Fetch() ([]*Secret, error)
+	Fetch(ctx context.Context) ([]*Secret, error)
+}
+
+// ChangesFetcher - fetchers which can skip downloading the secrets whose version didn't change since their previous fetch
+type ChangesFetcher interface {
+	SecretsFetcher
+
+	// FetchChanges - returns all the secrets, reusing the previously fetched content of the unchanged ones.
+	// The first call fetches all the secrets.
+	FetchChanges(ctx context.Context) ([]*Secret, error)
 }
@@ -0,0 +1,217 @@
+package secrets
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	unixSocketPrefix = "unix://"
+
+	secretsPath = "/v1/secrets"
+	refreshPath = "/v1/refresh"
+)
+
+// SecretsServer - serves the cached secrets as json. Requests must have an "Authorization: Bearer <token>" header when a token is set
+type SecretsServer struct {
+	zl    *zap.Logger
+	cache *SecretsCache
+	token string
+	mux   *http.ServeMux
+}
+
+// secretResponse - a secret and its version metadata. The content is left out when listing the secrets
+type secretResponse struct {
+	Name      string    `json:"name"`
+	Alias     string    `json:"alias,omitempty"`
+	Version   string    `json:"version,omitempty"`
+	Content   *string   `json:"content,omitempty"`
+	FetchedAt time.Time `json:"fetchedAt"`
+}
+
+type listResponse struct {
+	Secrets   []secretResponse `json:"secrets"`
+	FetchedAt time.Time        `json:"fetchedAt"`
+}
+
+type refreshResponse struct {
+	Changed   []string  `json:"changed"`
+	FetchedAt time.Time `json:"fetchedAt"`
+}
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+func NewSecretsServer(
+	cache *SecretsCache,
+	token string,
+	zl *zap.Logger) *SecretsServer {
+	s := &SecretsServer{
+		zl:    zl,
+		cache: cache,
+		token: token,
+		mux:   http.NewServeMux(),
+	}
+
+	s.mux.HandleFunc(secretsPath, s.handleList)
+	s.mux.HandleFunc(secretsPath+"/", s.handleGet)
+	s.mux.HandleFunc(refreshPath, s.handleRefresh)
+	return s
+}
+
+func (s *SecretsServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !s.authorized(r) {
+		s.writeJSON(w, http.StatusUnauthorized, errorResponse{Error: "missing or invalid token"})
+		return
+	}
+	s.mux.ServeHTTP(w, r)
+}
+
+// authorized - checks the bearer token in constant time. The Bearer scheme is required
+func (s *SecretsServer) authorized(r *http.Request) bool {
+	if s.token == "" {
+		return true
+	}
+	const scheme = "Bearer "
+	header := r.Header.Get("Authorization")
+	if !strings.HasPrefix(header, scheme) {
+		return false
+	}
+	token := header[len(scheme):]
+	return subtle.ConstantTimeCompare([]byte(token), []byte(s.token)) == 1
+}
+
+func (s *SecretsServer) writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		s.zl.Warn("failed to write response", zap.Error(err))
+	}
+}
+
+func (s *SecretsServer) writeError(w http.ResponseWriter, err error) {
+	if errors.Is(err, ErrSecretNotFound) {
+		s.writeJSON(w, http.StatusNotFound, errorResponse{Error: err.Error()})
+		return
+	}
+	s.zl.Error("failed to fetch secrets", zap.Error(err))
+	s.writeJSON(w, http.StatusBadGateway, errorResponse{Error: "failed to fetch secrets"})
+}
+
+func allowMethod(w http.ResponseWriter, r *http.Request, method string) bool {
+	if r.Method == method {
+		return true
+	}
+	w.Header().Set("Allow", method)
+	http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+	return false
+}
+
+// handleList - GET /v1/secrets: the names and versions of all the secrets
+func (s *SecretsServer) handleList(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, http.MethodGet) {
+		return
+	}
+
+	secretRes, fetchedAt, err := s.cache.List(r.Context())
+	if err != nil {
+		s.writeError(w, err)
+		return
+	}
+
+	resp := listResponse{Secrets: make([]secretResponse, 0, len(secretRes)), FetchedAt: fetchedAt}
+	for _, secret := range secretRes {
+		resp.Secrets = append(resp.Secrets, secretResponse{Name: secret.Name, Alias: secret.Alias, Version: secret.Version, FetchedAt: fetchedAt})
+	}
+	s.writeJSON(w, http.StatusOK, resp)
+}
+
+// handleGet - GET /v1/secrets/<alias or name>: the secret's content and version
+func (s *SecretsServer) handleGet(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, http.MethodGet) {
+		return
+	}
+
+	name := strings.TrimPrefix(r.URL.Path, secretsPath+"/")
+	secret, fetchedAt, err := s.cache.Get(r.Context(), name)
+	if err != nil {
+		s.writeError(w, err)
+		return
+	}
+
+	s.zl.Debug("served secret", zap.String("secret", name))
+	s.writeJSON(w, http.StatusOK, secretResponse{
+		Name:      secret.Name,
+		Alias:     secret.Alias,
+		Version:   secret.Version,
+		Content:   &secret.Content,
+		FetchedAt: fetchedAt,
+	})
+}
+
+// handleRefresh - POST /v1/refresh: fetches the secrets now, returning the changed ones
+func (s *SecretsServer) handleRefresh(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, http.MethodPost) {
+		return
+	}
+
+	changed, fetchedAt, err := s.cache.Refresh(r.Context())
+	if err != nil {
+		s.writeError(w, err)
+		return
+	}
+
+	if changed == nil {
+		changed = []string{}
+	}
+	s.zl.Info("refreshed secrets", zap.Strings("changed", changed))
+	s.writeJSON(w, http.StatusOK, refreshResponse{Changed: changed, FetchedAt: fetchedAt})
+}
+
+// IsUnixSocket - whether the listen address is a unix domain socket (unix:///path/to/socket)
+func IsUnixSocket(address string) bool {
+	return strings.HasPrefix(address, unixSocketPrefix)
+}
+
+// ListenLocal - listens on a unix domain socket (unix:///path/to/socket), created with the mode, or on a loopback tcp address.
+// A stale socket left by a previous run is replaced
+func ListenLocal(address string, socketMode os.FileMode) (net.Listener, error) {
+	if IsUnixSocket(address) {
+		path := strings.TrimPrefix(address, unixSocketPrefix)
+		if fi, err := os.Lstat(path); err == nil && fi.Mode()&os.ModeSocket != 0 {
+			if err := os.Remove(path); err != nil {
+				return nil, err
+			}
+		}
+
+		l, err := net.Listen("unix", path)
+		if err != nil {
+			return nil, err
+		}
+		if err := os.Chmod(path, socketMode); err != nil {
+			l.Close()
+			return nil, err
+		}
+		return l, nil
+	}
+
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, err
+	}
+	if ip := net.ParseIP(host); host != "localhost" && (ip == nil || !ip.IsLoopback()) {
+		return nil, fmt.Errorf("%s is not a loopback address", address)
+	}
+	return net.Listen("tcp", address)
+}
@@ -0,0 +1,252 @@
+package secrets_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"go.uber.org/zap/zaptest"
+
+	"github.com/daniel-cohen/secretsfetcher/secrets"
+)
+
+var _ = Describe("Serving secrets", func() {
+	const token = "s3cr3t"
+
+	var (
+		fetcher *fakeChangesFetcher
+		cache   *secrets.SecretsCache
+		server  *httptest.Server
+	)
+
+	BeforeEach(func() {
+		fetcher = &fakeChangesFetcher{secrets: []*secrets.Secret{
+			{Name: "secret1", Content: "value1", Version: "v1"},
+			{Name: "arn:secret2", Alias: "secret2", Content: "value2", Version: "v2"},
+		}}
+		cache = secrets.NewSecretsCache(fetcher, time.Hour, zaptest.NewLogger(GinkgoT()))
+		server = httptest.NewServer(secrets.NewSecretsServer(cache, token, zaptest.NewLogger(GinkgoT())))
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	request := func(method string, path string, response interface{}) int {
+		req, err := http.NewRequest(method, server.URL+path, nil)
+		Expect(err).NotTo(HaveOccurred())
+		req.Header.Set("Authorization", "Bearer "+token)
+
+		resp, err := http.DefaultClient.Do(req)
+		Expect(err).NotTo(HaveOccurred())
+		defer resp.Body.Close()
+
+		if response != nil {
+			Expect(json.NewDecoder(resp.Body).Decode(response)).To(Succeed())
+		}
+		return resp.StatusCode
+	}
+
+	type secretResponse struct {
+		Name      string
+		Alias     string
+		Version   string
+		Content   *string
+		FetchedAt time.Time
+	}
+
+	It("serves a secret by its alias or name with its version", func() {
+		var secret secretResponse
+		Expect(request(http.MethodGet, "/v1/secrets/secret2", &secret)).To(Equal(http.StatusOK))
+		Expect(secret.Name).To(Equal("arn:secret2"))
+		Expect(secret.Alias).To(Equal("secret2"))
+		Expect(secret.Version).To(Equal("v2"))
+		Expect(*secret.Content).To(Equal("value2"))
+		Expect(secret.FetchedAt).NotTo(BeZero())
+
+		Expect(request(http.MethodGet, "/v1/secrets/arn:secret2", &secret)).To(Equal(http.StatusOK))
+		Expect(*secret.Content).To(Equal("value2"))
+
+		// fetched once:
+		Expect(atomic.LoadInt32(&fetcher.changeCalls)).To(BeEquivalentTo(1))
+	})
+
+	It("lists the secrets without their content", func() {
+		var list struct{ Secrets []secretResponse }
+		Expect(request(http.MethodGet, "/v1/secrets", &list)).To(Equal(http.StatusOK))
+		Expect(list.Secrets).To(HaveLen(2))
+		Expect(list.Secrets[0].Name).To(Equal("secret1"))
+		Expect(list.Secrets[0].Version).To(Equal("v1"))
+		Expect(list.Secrets[0].Content).To(BeNil())
+	})
+
+	It("returns 404 for unknown secrets", func() {
+		var errResp struct{ Error string }
+		Expect(request(http.MethodGet, "/v1/secrets/missing", &errResp)).To(Equal(http.StatusNotFound))
+		Expect(errResp.Error).To(ContainSubstring("not found"))
+	})
+
+	It("rejects requests without the token", func() {
+		resp, err := http.Get(server.URL + "/v1/secrets/secret1")
+		Expect(err).NotTo(HaveOccurred())
+		resp.Body.Close()
+		Expect(resp.StatusCode).To(Equal(http.StatusUnauthorized))
+	})
+
+	It("rejects a token without the Bearer scheme", func() {
+		req, err := http.NewRequest(http.MethodGet, server.URL+"/v1/secrets/secret1", nil)
+		Expect(err).NotTo(HaveOccurred())
+		req.Header.Set("Authorization", token)
+
+		resp, err := http.DefaultClient.Do(req)
+		Expect(err).NotTo(HaveOccurred())
+		resp.Body.Close()
+		Expect(resp.StatusCode).To(Equal(http.StatusUnauthorized))
+	})
+
+	It("refreshes the secrets on demand, returning the changed ones", func() {
+		Expect(request(http.MethodGet, "/v1/secrets/secret1", nil)).To(Equal(http.StatusOK))
+		fetcher.secrets = []*secrets.Secret{{Name: "secret1", Content: "rotated", Version: "v3"}}
+
+		Expect(request(http.MethodGet, "/v1/refresh", nil)).To(Equal(http.StatusMethodNotAllowed))
+
+		var refresh struct{ Changed []string }
+		Expect(request(http.MethodPost, "/v1/refresh", &refresh)).To(Equal(http.StatusOK))
+		Expect(refresh.Changed).To(Equal([]string{"secret1", "secret2"}))
+
+		var secret secretResponse
+		Expect(request(http.MethodGet, "/v1/secrets/secret1", &secret)).To(Equal(http.StatusOK))
+		Expect(*secret.Content).To(Equal("rotated"))
+		Expect(secret.Version).To(Equal("v3"))
+	})
+
+	It("fails when the secrets were never fetched", func() {
+		fetcher.err = errors.New("access denied")
+		Expect(request(http.MethodGet, "/v1/secrets/secret1", nil)).To(Equal(http.StatusBadGateway))
+	})
+
+	Describe("the cache", func() {
+		It("fetches the secrets again once they expire", func() {
+			cache = secrets.NewSecretsCache(fetcher, time.Millisecond, zaptest.NewLogger(GinkgoT()))
+			_, _, err := cache.Get(context.Background(), "secret1")
+			Expect(err).NotTo(HaveOccurred())
+
+			time.Sleep(5 * time.Millisecond)
+			_, _, err = cache.Get(context.Background(), "secret1")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(atomic.LoadInt32(&fetcher.changeCalls)).To(BeEquivalentTo(2))
+		})
+
+		It("fetches once for concurrent lookups, regardless of their context", func() {
+			blocking := &blockingFetcher{fakeChangesFetcher: fetcher, release: make(chan struct{})}
+			cache = secrets.NewSecretsCache(blocking, time.Hour, zaptest.NewLogger(GinkgoT()))
+
+			// the first lookup gives up while the fetch is in flight:
+			ctx, cancel := context.WithCancel(context.Background())
+			cancelled := make(chan error, 1)
+			go func() {
+				_, _, err := cache.Get(ctx, "secret1")
+				cancelled <- err
+			}()
+			Eventually(func() int32 { return atomic.LoadInt32(&fetcher.changeCalls) }).Should(BeEquivalentTo(1))
+			cancel()
+			Eventually(cancelled).Should(Receive(MatchError(context.Canceled)))
+
+			done := make(chan error, 1)
+			go func() {
+				_, _, err := cache.Get(context.Background(), "secret2")
+				done <- err
+			}()
+			Consistently(done, 20*time.Millisecond).ShouldNot(Receive())
+
+			close(blocking.release)
+			Eventually(done).Should(Receive(BeNil()))
+			Expect(atomic.LoadInt32(&fetcher.changeCalls)).To(BeEquivalentTo(1))
+		})
+
+		It("serves the expired secrets when fetching them again fails", func() {
+			cache = secrets.NewSecretsCache(fetcher, time.Millisecond, zaptest.NewLogger(GinkgoT()))
+			_, fetchedAt, err := cache.Get(context.Background(), "secret1")
+			Expect(err).NotTo(HaveOccurred())
+
+			fetcher.err = errors.New("throttled")
+			time.Sleep(5 * time.Millisecond)
+			secret, staleFetchedAt, err := cache.Get(context.Background(), "secret1")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(secret.Content).To(Equal("value1"))
+			Expect(staleFetchedAt).To(Equal(fetchedAt))
+		})
+	})
+
+	Describe("listening", func() {
+		It("only listens on loopback addresses", func() {
+			_, err := secrets.ListenLocal("0.0.0.0:0", 0660)
+			Expect(err).To(HaveOccurred())
+
+			l, err := secrets.ListenLocal("127.0.0.1:0", 0660)
+			Expect(err).NotTo(HaveOccurred())
+			l.Close()
+		})
+
+		It("listens on a unix socket with the mode, replacing a stale one", func() {
+			tmpFolder, err := ioutil.TempDir("", "secretsfetcher")
+			Expect(err).NotTo(HaveOccurred())
+			defer os.RemoveAll(tmpFolder)
+
+			// a short path, as unix socket paths are limited:
+			socketPath := filepath.Join(tmpFolder, "s.sock")
+			stale, err := net.Listen("unix", socketPath)
+			Expect(err).NotTo(HaveOccurred())
+			stale.(*net.UnixListener).SetUnlinkOnClose(false)
+			stale.Close()
+
+			l, err := secrets.ListenLocal("unix://"+socketPath, 0600)
+			Expect(err).NotTo(HaveOccurred())
+			defer l.Close()
+
+			fi, err := os.Stat(socketPath)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(fi.Mode().Perm()).To(Equal(os.FileMode(0600)))
+
+			go http.Serve(l, secrets.NewSecretsServer(cache, "", zaptest.NewLogger(GinkgoT())))
+			client := &http.Client{Transport: &http.Transport{
+				DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+					return (&net.Dialer{}).DialContext(ctx, "unix", socketPath)
+				},
+			}}
+			resp, err := client.Get("http://unix/v1/secrets/secret1")
+			Expect(err).NotTo(HaveOccurred())
+			b, err := ioutil.ReadAll(resp.Body)
+			resp.Body.Close()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(strings.Contains(string(b), `"content":"value1"`)).To(BeTrue())
+		})
+	})
+})
+
+// blockingFetcher - fetches once released
+type blockingFetcher struct {
+	*fakeChangesFetcher
+	release chan struct{}
+}
+
+func (f *blockingFetcher) FetchChanges(ctx context.Context) ([]*secrets.Secret, error) {
+	res, err := f.fakeChangesFetcher.FetchChanges(ctx)
+	select {
+	case <-f.release:
+		return res, err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
@@ -0,0 +1,141 @@
+package secrets
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Status - tracks the written secrets and the refreshes, for the liveness and readiness probes
+type Status struct {
+	required  []string
+	staleness time.Duration
+	started   time.Time
+	now       func() time.Time
+
+	mu                    sync.Mutex
+	written               map[string]bool // by alias, name and manifest object
+	lastRefresh           time.Time
+	lastSuccessfulRefresh time.Time
+	lastError             string
+}
+
+// StatusReport - the readiness details
+type StatusReport struct {
+	Ready                 bool       `json:"ready"`
+	Reason                string     `json:"reason,omitempty"`
+	LastRefresh           *time.Time `json:"lastRefresh,omitempty"`
+	LastSuccessfulRefresh *time.Time `json:"lastSuccessfulRefresh,omitempty"`
+	LastError             string     `json:"lastError,omitempty"`
+	Staleness             string     `json:"staleness"`
+	RequiredSecrets       int        `json:"requiredSecrets"`
+	MissingSecrets        []string   `json:"missingSecrets,omitempty"`
+}
+
+type livenessReport struct {
+	Status string `json:"status"`
+	Uptime string `json:"uptime"`
+}
+
+// NewStatus - required are the names (alias, name or manifest object name) of the secrets which must be written before being ready.
+// Without required secrets, a single successful write is enough.
+// A staleness of 0 disables the last refresh check
+func NewStatus(required []string, staleness time.Duration) *Status {
+	return &Status{
+		required:  required,
+		staleness: staleness,
+		started:   time.Now(),
+		now:       time.Now,
+		written:   map[string]bool{},
+	}
+}
+
+// RecordRefresh - records a refresh, with the secrets it wrote (none if nothing changed) or its error
+func (s *Status) RecordRefresh(written []*Secret, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.lastRefresh = s.now()
+	if err != nil {
+		s.lastError = err.Error()
+		return
+	}
+
+	s.lastError = ""
+	s.lastSuccessfulRefresh = s.lastRefresh
+	for _, secret := range written {
+		s.written[secretKey(secret)] = true
+		s.written[secret.Name] = true
+		if secret.Object != "" {
+			s.written[secret.Object] = true
+		}
+	}
+}
+
+// Report - whether every required secret was written at least once, and the last successful refresh isn't stale
+func (s *Status) Report() StatusReport {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	report := StatusReport{
+		LastRefresh:           optionalTime(s.lastRefresh),
+		LastSuccessfulRefresh: optionalTime(s.lastSuccessfulRefresh),
+		LastError:             s.lastError,
+		Staleness:             s.staleness.String(),
+		RequiredSecrets:       len(s.required),
+	}
+
+	for _, name := range s.required {
+		if !s.written[name] {
+			report.MissingSecrets = append(report.MissingSecrets, name)
+		}
+	}
+	sort.Strings(report.MissingSecrets)
+
+	switch {
+	case len(s.written) == 0:
+		report.Reason = "no secrets were written yet"
+	case len(report.MissingSecrets) > 0:
+		report.Reason = "required secrets were not written yet"
+	case s.staleness > 0 && s.now().Sub(s.lastSuccessfulRefresh) > s.staleness:
+		report.Reason = "the last successful refresh is stale"
+	default:
+		report.Ready = true
+	}
+	return report
+}
+
+// optionalTime - nil for the zero time, so it's left out of the json
+func optionalTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
+
+func writeStatusJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+// ServeLiveness - /healthz: the process is up
+func (s *Status) ServeLiveness(w http.ResponseWriter, r *http.Request) {
+	writeStatusJSON(w, http.StatusOK, livenessReport{
+		Status: "ok",
+		Uptime: s.now().Sub(s.started).Round(time.Second).String(),
+	})
+}
+
+// ServeReadiness - /readyz: the status report, with 503 when not ready
+func (s *Status) ServeReadiness(w http.ResponseWriter, r *http.Request) {
+	report := s.Report()
+	status := http.StatusOK
+	if !report.Ready {
+		status = http.StatusServiceUnavailable
+	}
+	writeStatusJSON(w, status, report)
+}
@@ -0,0 +1,97 @@
+package secrets_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"go.uber.org/zap/zaptest"
+
+	"github.com/daniel-cohen/secretsfetcher/secrets"
+)
+
+var _ = Describe("Secrets status", func() {
+	readyz := func(status *secrets.Status) (int, secrets.StatusReport) {
+		rec := httptest.NewRecorder()
+		status.ServeReadiness(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+
+		var report secrets.StatusReport
+		Expect(json.Unmarshal(rec.Body.Bytes(), &report)).To(Succeed())
+		return rec.Code, report
+	}
+
+	It("is live right away", func() {
+		rec := httptest.NewRecorder()
+		secrets.NewStatus(nil, time.Minute).ServeLiveness(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+		Expect(rec.Code).To(Equal(http.StatusOK))
+		Expect(rec.Body.String()).To(ContainSubstring(`"status":"ok"`))
+	})
+
+	It("is ready once every required secret was written", func() {
+		status := secrets.NewStatus([]string{"secret1", "secret2"}, time.Minute)
+
+		code, report := readyz(status)
+		Expect(code).To(Equal(http.StatusServiceUnavailable))
+		Expect(report.Ready).To(BeFalse())
+		Expect(report.LastRefresh).To(BeNil())
+
+		status.RecordRefresh([]*secrets.Secret{{Name: "secret1", Content: "value1"}}, nil)
+		code, report = readyz(status)
+		Expect(code).To(Equal(http.StatusServiceUnavailable))
+		Expect(report.MissingSecrets).To(Equal([]string{"secret2"}))
+
+		status.RecordRefresh([]*secrets.Secret{{Name: "arn:secret2", Alias: "secret2", Content: "value2"}}, nil)
+		code, report = readyz(status)
+		Expect(code).To(Equal(http.StatusOK))
+		Expect(report.Ready).To(BeTrue())
+		Expect(report.RequiredSecrets).To(Equal(2))
+	})
+
+	It("tracks the secrets by the manifest object they were fetched for", func() {
+		const objectName = "arn:aws:secretsmanager:us-west-2:111122223333:secret:db-prod12"
+		status := secrets.NewStatus([]string{objectName}, time.Minute)
+
+		// the secret is returned under its name:
+		status.RecordRefresh([]*secrets.Secret{{Name: "db-prod", Object: objectName, Content: "value"}}, nil)
+		code, report := readyz(status)
+		Expect(code).To(Equal(http.StatusOK))
+		Expect(report.Ready).To(BeTrue())
+	})
+
+	It("stays ready after a failed refresh, reporting its error", func() {
+		status := secrets.NewStatus(nil, time.Minute)
+		status.RecordRefresh([]*secrets.Secret{{Name: "secret1", Content: "value1"}}, nil)
+		status.RecordRefresh(nil, errors.New("throttled"))
+
+		code, report := readyz(status)
+		Expect(code).To(Equal(http.StatusOK))
+		Expect(report.LastError).To(Equal("throttled"))
+		Expect(*report.LastRefresh).To(BeTemporally(">", *report.LastSuccessfulRefresh))
+	})
+
+	It("is not ready once the last successful refresh is stale", func() {
+		status := secrets.NewStatus(nil, 10*time.Millisecond)
+		status.RecordRefresh([]*secrets.Secret{{Name: "secret1", Content: "value1"}}, nil)
+		Expect(status.Report().Ready).To(BeTrue())
+
+		time.Sleep(20 * time.Millisecond)
+		report := status.Report()
+		Expect(report.Ready).To(BeFalse())
+		Expect(report.Reason).To(ContainSubstring("stale"))
+	})
+
+	It("is updated by the watcher", func() {
+		fetcher := &fakeChangesFetcher{secrets: []*secrets.Secret{{Name: "secret1", Content: "value1"}}}
+		status := secrets.NewStatus([]string{"secret1"}, time.Minute)
+		watcher := secrets.NewWatcher(fetcher, &recordingWriter{}, time.Minute, zaptest.NewLogger(GinkgoT())).WithStatus(status)
+
+		_, err := watcher.Refresh(context.Background())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(status.Report().Ready).To(BeTrue())
+	})
+})
@@ -0,0 +1,239 @@
+package secrets
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"text/template"
+
+	"github.com/jmespath/go-jmespath"
+	"go.uber.org/zap"
+)
+
+// TemplateSpec - a template file rendered with the fetched secrets
+type TemplateSpec struct {
+	Source      string // the template file
+	Destination string // the rendered file, relative to (and inside) the output folder
+	FileMode    string // optional octal mode overriding the writer's file mode. E.g: "0400"
+}
+
+type TemplateSecretWriter struct {
+	// implements SecretWriter
+	zl           *zap.Logger
+	outputFolder string
+	templates    []*TemplateSpec
+
+	fileMode os.FileMode
+	dirMode  os.FileMode
+	uid      int
+	gid      int
+}
+
+// WithFileMode - the mode of the rendered files (regardless of the umask)
+func (tw *TemplateSecretWriter) WithFileMode(mode os.FileMode) *TemplateSecretWriter {
+	tw.fileMode = mode
+	return tw
+}
+
+// WithDirMode - the mode of the destination folders created for the rendered files
+func (tw *TemplateSecretWriter) WithDirMode(mode os.FileMode) *TemplateSecretWriter {
+	tw.dirMode = mode
+	return tw
+}
+
+// WithOwner - the uid and gid of the rendered files. KeepOwner leaves them as the user running the process
+func (tw *TemplateSecretWriter) WithOwner(uid int, gid int) *TemplateSecretWriter {
+	tw.uid = uid
+	tw.gid = gid
+	return tw
+}
+
+func NewTemplateSecretWriter(
+	outputFolder string,
+	templates []*TemplateSpec,
+	zl *zap.Logger) *TemplateSecretWriter {
+	return &TemplateSecretWriter{
+		zl:           zl,
+		outputFolder: outputFolder,
+		templates:    templates,
+		fileMode:     DefaultFileMode,
+		dirMode:      DefaultDirMode,
+		uid:          KeepOwner,
+		gid:          KeepOwner,
+	}
+}
+
+// templateFuncs - the helpers templates use to access the secrets by alias or name:
+//
+//	{{ secret "db" }}                          - the secret content, fails if missing
+//	{{ optionalSecret "db" | default "none" }} - empty if missing
+//	{{ hasSecret "db" }}
+//	{{ jsonField "db" "password" }}            - a jmespath expression on a json secret, fails if missing
+//	{{ secret "cert" | base64Encode }} / base64Decode
+func templateFuncs(secretsByName map[string]string) template.FuncMap {
+	secret := func(name string) (string, error) {
+		content, ok := secretsByName[name]
+		if !ok {
+			return "", fmt.Errorf("secret %q not found", name)
+		}
+		return content, nil
+	}
+
+	return template.FuncMap{
+		"secret": secret,
+		"optionalSecret": func(name string) string {
+			return secretsByName[name]
+		},
+		"hasSecret": func(name string) bool {
+			_, ok := secretsByName[name]
+			return ok
+		},
+		"jsonField": func(name string, path string) (string, error) {
+			content, err := secret(name)
+			if err != nil {
+				return "", err
+			}
+
+			var data interface{}
+			if err := json.Unmarshal([]byte(content), &data); err != nil {
+				return "", fmt.Errorf("secret %q is not valid json: %w", name, err)
+			}
+
+			value, err := jmespath.Search(path, data)
+			if err != nil {
+				return "", fmt.Errorf("invalid json field %q for secret %q: %w", path, name, err)
+			}
+
+			switch v := value.(type) {
+			case nil:
+				return "", fmt.Errorf("json field %q not found in secret %q", path, name)
+			case string:
+				return v, nil
+			default:
+				b, err := json.Marshal(v)
+				return string(b), err
+			}
+		},
+		"base64Encode": func(s string) string {
+			return base64.StdEncoding.EncodeToString([]byte(s))
+		},
+		"base64Decode": func(s string) (string, error) {
+			b, err := base64.StdEncoding.DecodeString(s)
+			return string(b), err
+		},
+		"default": func(def string, value string) string {
+			if value == "" {
+				return def
+			}
+			return value
+		},
+	}
+}
+
+// destination - the rendered file path. Destinations must be relative, and stay inside the output folder
+func (tw *TemplateSecretWriter) destination(spec *TemplateSpec) (string, error) {
+	return SafeOutputPath(tw.outputFolder, spec.Destination)
+}
+
+// createDestinationFolder - creates the rendered file's folder with the dir mode if it's missing
+func (tw *TemplateSecretWriter) createDestinationFolder(folder string) error {
+	if _, err := os.Stat(folder); !os.IsNotExist(err) {
+		return err
+	}
+
+	tw.zl.Info("creating the destination folder", zap.String("folder", folder))
+	if err := os.MkdirAll(folder, tw.dirMode); err != nil {
+		return err
+	}
+	return setPermissions(folder, FilePermissions{Mode: tw.dirMode, UID: tw.uid, GID: tw.gid})
+}
+
+// render - renders a single template. Missing secrets, fields and map keys fail the rendering
+func (tw *TemplateSecretWriter) render(spec *TemplateSpec, funcs template.FuncMap, data map[string]string) ([]byte, error) {
+	text, err := os.ReadFile(spec.Source)
+	if err != nil {
+		return nil, err
+	}
+
+	tmpl, err := template.New(filepath.Base(spec.Source)).
+		Funcs(funcs).
+		Option("missingkey=error").
+		Parse(string(text))
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// WriteSecrets - renders all the templates, and only then writes each of them atomically.
+// Secrets are available to the templates by alias and by name (also as the template data: {{ .name }})
+func (tw *TemplateSecretWriter) WriteSecrets(ctx context.Context, secretRes []*Secret) error {
+	secretsByName := map[string]string{}
+	for _, s := range secretRes {
+		if s.Alias != "" {
+			secretsByName[s.Alias] = s.Content
+		}
+	}
+	for _, s := range secretRes {
+		if _, ok := secretsByName[s.Name]; !ok {
+			secretsByName[s.Name] = s.Content
+		}
+	}
+	funcs := templateFuncs(secretsByName)
+
+	type rendered struct {
+		path    string
+		content []byte
+		perms   FilePermissions
+	}
+
+	var files []rendered
+	for _, spec := range tw.templates {
+		path, err := tw.destination(spec)
+		if err != nil {
+			return fmt.Errorf("template %s: %w", spec.Source, err)
+		}
+
+		perms := FilePermissions{Mode: tw.fileMode, UID: tw.uid, GID: tw.gid}
+		if spec.FileMode != "" {
+			if perms.Mode, err = ParseFileMode(spec.FileMode); err != nil {
+				return fmt.Errorf("template %s: %w", spec.Source, err)
+			}
+		}
+
+		content, err := tw.render(spec, funcs, secretsByName)
+		if err != nil {
+			tw.zl.Error("failed to render template", zap.String("template", spec.Source), zap.Error(err))
+			return fmt.Errorf("template %s: %w", spec.Source, err)
+		}
+		files = append(files, rendered{path: path, content: content, perms: perms})
+	}
+
+	for _, f := range files {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		if err := tw.createDestinationFolder(filepath.Dir(f.path)); err != nil {
+			tw.zl.Error("failed to create the destination folder", zap.String("file_path", f.path), zap.Error(err))
+			return err
+		}
+
+		tw.zl.Info("writing rendered template", zap.String("file_path", f.path))
+		if err := writeFileAtomic(f.path, f.content, f.perms); err != nil {
+			tw.zl.Error("failed to write rendered template", zap.String("file_path", f.path), zap.Error(err))
+			return err
+		}
+	}
+
+	return nil
+}
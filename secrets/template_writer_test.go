@@ -0,0 +1,142 @@
+package secrets_test
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+	"go.uber.org/zap/zaptest"
+
+	"github.com/daniel-cohen/secretsfetcher/secrets"
+)
+
+var _ = Describe("Rendering secrets into templates", func() {
+	var (
+		outputFolder string
+		secretRes    []*secrets.Secret
+	)
+
+	BeforeEach(func() {
+		var err error
+		outputFolder, err = ioutil.TempDir("", "secretsfetcher")
+		Expect(err).NotTo(HaveOccurred())
+
+		secretRes = []*secrets.Secret{
+			{Name: "arn:aws:secretsmanager:us-west-2:111122223333:secret:db-1a2b3c", Alias: "db", Content: `{"user": "admin", "password": "pa$$", "port": 5432}`},
+			{Name: "my-app/api-key", Content: "key-1"},
+		}
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(outputFolder)
+	})
+
+	writeTemplate := func(name string, text string) string {
+		path := filepath.Join(outputFolder, name)
+		Expect(ioutil.WriteFile(path, []byte(text), 0600)).To(Succeed())
+		return path
+	}
+
+	render := func(text string) (string, error) {
+		writer := secrets.NewTemplateSecretWriter(outputFolder, []*secrets.TemplateSpec{
+			{Source: writeTemplate("app.tmpl", text), Destination: "app.conf"},
+		}, zaptest.NewLogger(GinkgoT()))
+
+		if err := writer.WriteSecrets(context.Background(), secretRes); err != nil {
+			return "", err
+		}
+
+		b, err := ioutil.ReadFile(filepath.Join(outputFolder, "app.conf"))
+		Expect(err).NotTo(HaveOccurred())
+		return string(b), nil
+	}
+
+	It("renders secrets by alias and name with the helpers", func() {
+		out, err := render(`user={{ jsonField "db" "user" }}
+port={{ jsonField "db" "port" }}
+key={{ secret "my-app/api-key" | base64Encode }}
+decoded={{ "a2V5LTE=" | base64Decode }}
+data={{ index . "my-app/api-key" }}
+missing={{ optionalSecret "nope" | default "fallback" }}
+{{ if hasSecret "db" }}has db{{ end }}`)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(out).To(Equal(`user=admin
+port=5432
+key=a2V5LTE=
+decoded=key-1
+data=key-1
+missing=fallback
+has db`))
+	})
+
+	DescribeTable("fails instead of rendering empty values",
+		func(text string) {
+			_, err := render(text)
+			Expect(err).To(HaveOccurred())
+
+			_, err = os.Stat(filepath.Join(outputFolder, "app.conf"))
+			Expect(os.IsNotExist(err)).To(BeTrue())
+		},
+		Entry("missing secret", `{{ secret "nope" }}`),
+		Entry("missing json field", `{{ jsonField "db" "host" }}`),
+		Entry("not json", `{{ jsonField "my-app/api-key" "host" }}`),
+		Entry("missing data key", `{{ .nope }}`),
+	)
+
+	It("does not write any template when one fails", func() {
+		writer := secrets.NewTemplateSecretWriter(outputFolder, []*secrets.TemplateSpec{
+			{Source: writeTemplate("good.tmpl", `{{ secret "db" }}`), Destination: "good.conf"},
+			{Source: writeTemplate("bad.tmpl", `{{ secret "nope" }}`), Destination: "bad.conf"},
+		}, zaptest.NewLogger(GinkgoT()))
+
+		Expect(writer.WriteSecrets(context.Background(), secretRes)).NotTo(Succeed())
+		_, err := os.Stat(filepath.Join(outputFolder, "good.conf"))
+		Expect(os.IsNotExist(err)).To(BeTrue())
+	})
+
+	It("writes the destination with the template's file mode", func() {
+		writer := secrets.NewTemplateSecretWriter(outputFolder, []*secrets.TemplateSpec{
+			{Source: writeTemplate("app.tmpl", `{{ secret "db" }}`), Destination: "app.conf", FileMode: "0400"},
+		}, zaptest.NewLogger(GinkgoT()))
+		Expect(writer.WriteSecrets(context.Background(), secretRes)).To(Succeed())
+
+		fi, err := os.Stat(filepath.Join(outputFolder, "app.conf"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(fi.Mode().Perm()).To(Equal(os.FileMode(0400)))
+	})
+
+	It("rejects relative destinations outside of the output folder", func() {
+		writer := secrets.NewTemplateSecretWriter(outputFolder, []*secrets.TemplateSpec{
+			{Source: writeTemplate("app.tmpl", `{{ secret "db" }}`), Destination: "../app.conf"},
+		}, zaptest.NewLogger(GinkgoT()))
+		Expect(writer.WriteSecrets(context.Background(), secretRes)).NotTo(Succeed())
+	})
+
+	It("rejects absolute destinations", func() {
+		destination := filepath.Join(outputFolder, "app.conf")
+		writer := secrets.NewTemplateSecretWriter(outputFolder, []*secrets.TemplateSpec{
+			{Source: writeTemplate("app.tmpl", `{{ secret "db" }}`), Destination: destination},
+		}, zaptest.NewLogger(GinkgoT()))
+		Expect(writer.WriteSecrets(context.Background(), secretRes)).NotTo(Succeed())
+		Expect(destination).NotTo(BeAnExistingFile())
+	})
+
+	It("creates the folders of nested destinations with the dir mode", func() {
+		writer := secrets.NewTemplateSecretWriter(outputFolder, []*secrets.TemplateSpec{
+			{Source: writeTemplate("app.tmpl", `{{ secret "my-app/api-key" }}`), Destination: "nginx/conf.d/app.conf"},
+		}, zaptest.NewLogger(GinkgoT())).WithDirMode(0750)
+		Expect(writer.WriteSecrets(context.Background(), secretRes)).To(Succeed())
+
+		b, err := ioutil.ReadFile(filepath.Join(outputFolder, "nginx", "conf.d", "app.conf"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(b)).To(Equal("key-1"))
+
+		fi, err := os.Stat(filepath.Join(outputFolder, "nginx", "conf.d"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(fi.Mode().Perm()).To(Equal(os.FileMode(0750)))
+	})
+})
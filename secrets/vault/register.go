@@ -0,0 +1,161 @@
+package vault
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/daniel-cohen/secretsfetcher/secrets"
+	"github.com/spf13/pflag"
+	"go.uber.org/zap"
+)
+
+// registration - the vault provider of the secrets registry
+type registration struct {
+	// implements secrets.Provider
+}
+
+func init() {
+	secrets.RegisterProvider(registration{})
+}
+
+func (registration) Name() string {
+	return ProviderName
+}
+
+func (registration) Usage() secrets.ProviderUsage {
+	return secrets.ProviderUsage{
+		Short: "fetches secrets from a hashicorp vault kv v2 secrets engine",
+		Long: `Fetches the secrets in a vault manifest, or all the secrets under a kv path (--path), and writes them like the aws command.
+The vault address, token and namespace default to the VAULT_ADDR, VAULT_TOKEN and VAULT_NAMESPACE environment variables.`,
+		Example: "  secretsfetcher vault -m manifest.yaml --address https://vault:8200 --auth kubernetes --role my-app -o /secrets",
+	}
+}
+
+func (registration) ConfigDefaults() map[string]interface{} {
+	return map[string]interface{}{
+		"Address":         "",
+		"Namespace":       "",
+		"AuthMethod":      AuthMethodToken,
+		"AuthMount":       "",
+		"Token":           "",
+		"TokenPath":       "",
+		"RoleID":          "",
+		"SecretID":        "",
+		"SecretIDPath":    "",
+		"Role":            "",
+		"JWTPath":         DefaultKubernetesTokenPath,
+		"Mount":           DefaultMount,
+		"Path":            "",
+		"PathTranslation": secrets.DefaultPathTranslation,
+		"FailurePolicy":   "",
+		"Concurrency":     DefaultConcurrency,
+		"Timeout":         DefaultTimeout,
+		"RequestTimeout":  DefaultRequestTimeout,
+	}
+}
+
+func (registration) AddFlags(flags *pflag.FlagSet) map[string]string {
+	flags.String("address", "", "the vault address (defaults to VAULT_ADDR). Example: --address=https://vault:8200")
+	flags.String("namespace", "", "the vault enterprise namespace (defaults to VAULT_NAMESPACE)")
+	flags.String("auth", AuthMethodToken, "the vault auth method: token, approle or kubernetes")
+	flags.String("authmount", "", "the path the auth method is mounted at (defaults to the auth method name)")
+	flags.String("tokenpath", "", "a file with the vault token for --auth=token (or set APP_VAULT_TOKEN or VAULT_TOKEN)")
+	flags.String("roleid", "", "the approle role id")
+	flags.String("secretidpath", "", "a file with the approle secret id (or set APP_VAULT_SECRETID)")
+	flags.String("role", "", "the vault role for --auth=kubernetes")
+	flags.String("jwtpath", DefaultKubernetesTokenPath, "the service account token for --auth=kubernetes")
+	flags.String("mount", DefaultMount, "the kv v2 secrets engine mount")
+	flags.String("path", "", "fetch all the secrets under this kv path (recursively) instead of a manifest. Example: --path=my-app/")
+	flags.Int("concurrency", DefaultConcurrency, "the maximum number of secrets fetched in parallel")
+	flags.Duration("timeout", DefaultTimeout, "the overall timeout for fetching and writing the secrets. 0 disables the timeout")
+	flags.Duration("requesttimeout", DefaultRequestTimeout, "the timeout for each vault request. 0 disables the timeout")
+
+	return map[string]string{
+		"address":        "Address",
+		"namespace":      "Namespace",
+		"auth":           "AuthMethod",
+		"authmount":      "AuthMount",
+		"tokenpath":      "TokenPath",
+		"roleid":         "RoleID",
+		"secretidpath":   "SecretIDPath",
+		"role":           "Role",
+		"jwtpath":        "JWTPath",
+		"mount":          "Mount",
+		"path":           "Path",
+		"concurrency":    "Concurrency",
+		"timeout":        "Timeout",
+		"requesttimeout": "RequestTimeout",
+	}
+}
+
+// NewFetch - the fetcher for the manifest, or all the secrets under the kv path
+func (registration) NewFetch(ctx context.Context, config secrets.Decoder, manifest secrets.Decoder, zl *zap.Logger) (*secrets.ProviderFetch, error) {
+	cfg := &VaultConfig{}
+	if err := config(cfg); err != nil {
+		return nil, fmt.Errorf("invalid vault config: %w", err)
+	}
+
+	if cfg.Address == "" {
+		cfg.Address = os.Getenv("VAULT_ADDR")
+	}
+	if cfg.Token == "" && cfg.TokenPath == "" {
+		cfg.Token = os.Getenv("VAULT_TOKEN")
+	}
+	if cfg.Namespace == "" {
+		cfg.Namespace = os.Getenv("VAULT_NAMESPACE")
+	}
+
+	pathTranslation := cfg.PathTranslation
+
+	var manifestCfg *SecretManifest
+	if manifest != nil {
+		manifestCfg = &SecretManifest{}
+		if err := manifest(manifestCfg); err != nil {
+			return nil, fmt.Errorf("unable to decode the manifest: %w", err)
+		}
+		if err := manifestCfg.Validate(); err != nil {
+			return nil, fmt.Errorf("invalid manifest: %w", err)
+		}
+		zl.Info("Loaded manifest config", zap.Any("manifestCfg", manifestCfg))
+
+		// the manifest takes precedence over the main config:
+		if manifestCfg.Address != "" {
+			cfg.Address = manifestCfg.Address
+		}
+		if manifestCfg.Mount != "" {
+			cfg.Mount = manifestCfg.Mount
+		}
+		if manifestCfg.PathTranslation != "" {
+			pathTranslation = manifestCfg.PathTranslation
+		}
+	} else if cfg.Path == "" {
+		return nil, fmt.Errorf("no manifest and no vault path set")
+	}
+
+	failurePolicy, err := secrets.ParseFailurePolicy(cfg.FailurePolicy)
+	if err != nil {
+		return nil, fmt.Errorf("invalid failure policy: %w", err)
+	}
+
+	provider, err := NewVaultSecretsProvider(cfg, zl)
+	if err != nil {
+		return nil, fmt.Errorf("failed to setup vault secrets provider: %w", err)
+	}
+	provider.WithConcurrency(cfg.Concurrency).WithRequestTimeout(cfg.RequestTimeout).WithFailurePolicy(failurePolicy)
+
+	fetch := &secrets.ProviderFetch{
+		PathTranslation: secrets.PathTranslationChar(pathTranslation),
+		Timeout:         cfg.Timeout,
+	}
+	if manifestCfg != nil {
+		fetch.Fetcher = NewManifestSecretFetcher(provider, manifestCfg, zl)
+		fetch.Templates = manifestCfg.Templates
+		fetch.Required = manifestCfg.RequiredSecrets()
+		fetch.Outputs = manifestCfg.Outputs(fetch.PathTranslation)
+	} else {
+		fetch.Fetcher = NewPathSecretFetcher(provider, cfg.Path, zl)
+	}
+
+	return fetch, nil
+}
@@ -0,0 +1,28 @@
+package vault
+
+import (
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+var _ = DescribeTable("Validating a vault manifest",
+	func(manifest *SecretManifest, expectError bool) {
+		err := manifest.Validate()
+		if expectError {
+			Expect(err).To(HaveOccurred())
+		} else {
+			Expect(err).NotTo(HaveOccurred())
+		}
+	},
+	Entry("valid", &SecretManifest{Provider: "vault", SecretObjects: []*VaultSecretObject{{ObjectName: "a", ObjectVersion: "3"}, {ObjectName: "b"}}}, false),
+	Entry("invalid version", &SecretManifest{SecretObjects: []*VaultSecretObject{{ObjectName: "a", ObjectVersion: "latest"}}}, true),
+	Entry("invalid file mode", &SecretManifest{SecretObjects: []*VaultSecretObject{{ObjectName: "a", FileMode: "0999"}}}, true),
+	Entry("keys of the same secret without aliases", &SecretManifest{SecretObjects: []*VaultSecretObject{
+		{ObjectName: "a", ObjectKey: "user"},
+		{ObjectName: "a", ObjectKey: "password"},
+	}}, true),
+	Entry("keys of the same secret with aliases", &SecretManifest{SecretObjects: []*VaultSecretObject{
+		{ObjectName: "a", ObjectKey: "user", ObjectAlias: "user"},
+		{ObjectName: "a", ObjectKey: "password", ObjectAlias: "password"},
+	}}, false),
+)
@@ -0,0 +1,83 @@
+package vault
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+type loginResponse struct {
+	Auth *struct {
+		ClientToken   string `json:"client_token"`
+		LeaseDuration int    `json:"lease_duration"` // seconds
+	} `json:"auth"`
+}
+
+// readValue - the value, or the trimmed content of the file if the value isn't set
+func readValue(value string, path string) (string, error) {
+	if value != "" || path == "" {
+		return value, nil
+	}
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(b)), nil
+}
+
+// login - gets a token with the configured auth method. The lease duration is 0 for tokens which don't need renewing
+func (c *vaultClient) login(ctx context.Context) (string, time.Duration, error) {
+	var body map[string]string
+
+	switch c.cfg.AuthMethod {
+	case "", AuthMethodToken:
+		token, err := readValue(c.cfg.Token, c.cfg.TokenPath)
+		if err != nil {
+			return "", 0, fmt.Errorf("failed to read the vault token: %w", err)
+		}
+		if token == "" {
+			return "", 0, fmt.Errorf("no vault token set")
+		}
+		return token, 0, nil
+
+	case AuthMethodAppRole:
+		secretID, err := readValue(c.cfg.SecretID, c.cfg.SecretIDPath)
+		if err != nil {
+			return "", 0, fmt.Errorf("failed to read the approle secret id: %w", err)
+		}
+		body = map[string]string{"role_id": c.cfg.RoleID, "secret_id": secretID}
+
+	case AuthMethodKubernetes:
+		jwtPath := c.cfg.JWTPath
+		if jwtPath == "" {
+			jwtPath = DefaultKubernetesTokenPath
+		}
+		jwt, err := readValue("", jwtPath)
+		if err != nil {
+			return "", 0, fmt.Errorf("failed to read the kubernetes service account token: %w", err)
+		}
+		body = map[string]string{"role": c.cfg.Role, "jwt": jwt}
+
+	default:
+		return "", 0, fmt.Errorf("unsupported vault auth method %q: expected %s, %s or %s",
+			c.cfg.AuthMethod, AuthMethodToken, AuthMethodAppRole, AuthMethodKubernetes)
+	}
+
+	var resp loginResponse
+	if err := c.do(ctx, "Login", http.MethodPost, "auth/"+c.cfg.authMount()+"/login", nil, "", body, &resp); err != nil {
+		c.zl.Error("vault login failed", zap.String("authMethod", c.cfg.AuthMethod), zap.String("authMount", c.cfg.authMount()), zap.Error(err))
+		return "", 0, err
+	}
+	if resp.Auth == nil || resp.Auth.ClientToken == "" {
+		return "", 0, fmt.Errorf("vault %s login returned no token", c.cfg.AuthMethod)
+	}
+
+	c.zl.Info("logged in to vault", zap.String("authMethod", c.cfg.AuthMethod), zap.Int("leaseDurationSeconds", resp.Auth.LeaseDuration))
+	return resp.Auth.ClientToken, time.Duration(resp.Auth.LeaseDuration) * time.Second, nil
+}
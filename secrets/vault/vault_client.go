@@ -0,0 +1,178 @@
+package vault
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/daniel-cohen/secretsfetcher/metrics"
+	"go.uber.org/zap"
+)
+
+const (
+	providerName = "vault"
+
+	methodList = "LIST"
+
+	// tokens are renewed by logging in again once less than this is left of their lease:
+	tokenRenewMargin = 30 * time.Second
+)
+
+// VaultError - a failed vault request
+type VaultError struct {
+	StatusCode int
+	Errors     []string
+}
+
+func (e *VaultError) Error() string {
+	if len(e.Errors) == 0 {
+		return fmt.Sprintf("vault request failed: %d %s", e.StatusCode, http.StatusText(e.StatusCode))
+	}
+	return fmt.Sprintf("vault request failed: %d %s", e.StatusCode, strings.Join(e.Errors, ", "))
+}
+
+// IsNotFound - true if the vault path (or secret version) doesn't exist
+func IsNotFound(err error) bool {
+	var ve *VaultError
+	return errors.As(err, &ve) && ve.StatusCode == http.StatusNotFound
+}
+
+// vaultClient - a minimal vault http api client, logging in (again) with the configured auth method as needed
+type vaultClient struct {
+	zl         *zap.Logger
+	cfg        *VaultConfig
+	httpClient *http.Client
+
+	// a timeout for each request. 0 disables it
+	requestTimeout time.Duration
+
+	mu          sync.Mutex
+	token       string
+	tokenExpiry time.Time // zero for tokens which aren't renewed
+}
+
+func newVaultClient(cfg *VaultConfig, zl *zap.Logger) *vaultClient {
+	return &vaultClient{
+		zl:         zl,
+		cfg:        cfg,
+		httpClient: &http.Client{},
+	}
+}
+
+// errorCode - the http status code of a failed request for the metrics. Empty if the request succeeded
+func errorCode(err error) string {
+	var ve *VaultError
+	switch {
+	case err == nil:
+		return ""
+	case errors.As(err, &ve):
+		return strconv.Itoa(ve.StatusCode)
+	case errors.Is(err, context.DeadlineExceeded):
+		return metrics.ErrorCodeTimeout
+	case errors.Is(err, context.Canceled):
+		return metrics.ErrorCodeCanceled
+	default:
+		return metrics.ErrorCodeUnknown
+	}
+}
+
+// do - sends a request to the vault api path (without /v1), decoding the json response into out (if not nil)
+func (c *vaultClient) do(ctx context.Context, operation string, method string, path string, query url.Values, token string, body interface{}, out interface{}) (err error) {
+	start := time.Now()
+	defer func() {
+		metrics.ObserveProviderCall(providerName, operation, time.Since(start), errorCode(err))
+	}()
+
+	if c.requestTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.requestTimeout)
+		defer cancel()
+	}
+
+	u := strings.TrimSuffix(c.cfg.Address, "/") + "/v1/" + strings.TrimPrefix(path, "/")
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+
+	var reqBody bytes.Buffer
+	if body != nil {
+		if err := json.NewEncoder(&reqBody).Encode(body); err != nil {
+			return err
+		}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, u, &reqBody)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if token != "" {
+		req.Header.Set("X-Vault-Token", token)
+	}
+	if c.cfg.Namespace != "" {
+		req.Header.Set("X-Vault-Namespace", c.cfg.Namespace)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		ve := &VaultError{StatusCode: resp.StatusCode}
+		var errResp struct {
+			Errors []string `json:"errors"`
+		}
+		if json.NewDecoder(resp.Body).Decode(&errResp) == nil {
+			ve.Errors = errResp.Errors
+		}
+		return ve
+	}
+
+	if out == nil || resp.StatusCode == http.StatusNoContent {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// authenticated - sends a request with the vault token, logging in first if needed
+func (c *vaultClient) authenticated(ctx context.Context, operation string, method string, path string, query url.Values, out interface{}) error {
+	token, err := c.currentToken(ctx)
+	if err != nil {
+		return err
+	}
+	return c.do(ctx, operation, method, path, query, token, nil, out)
+}
+
+// currentToken - the vault token, logging in if there's none or its lease is about to expire
+func (c *vaultClient) currentToken(ctx context.Context) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.token != "" && (c.tokenExpiry.IsZero() || time.Until(c.tokenExpiry) > tokenRenewMargin) {
+		return c.token, nil
+	}
+
+	token, leaseDuration, err := c.login(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	c.token = token
+	c.tokenExpiry = time.Time{}
+	if leaseDuration > 0 {
+		c.tokenExpiry = time.Now().Add(leaseDuration)
+	}
+	return c.token, nil
+}
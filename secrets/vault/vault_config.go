@@ -0,0 +1,68 @@
+package vault
+
+import "time"
+
+const (
+	AuthMethodToken      = "token"
+	AuthMethodAppRole    = "approle"
+	AuthMethodKubernetes = "kubernetes"
+
+	DefaultMount               = "secret"
+	DefaultKubernetesTokenPath = "/var/run/secrets/kubernetes.io/serviceaccount/token"
+	DefaultConcurrency         = 10
+	DefaultTimeout             = 5 * time.Minute
+	DefaultRequestTimeout      = 30 * time.Second
+)
+
+type VaultConfig struct {
+	// the vault server. E.g: https://vault.example.com:8200
+	Address string
+
+	// an optional vault enterprise namespace
+	Namespace string
+
+	// token (default), approle or kubernetes
+	AuthMethod string
+
+	// the path the auth method is mounted at. Defaults to the auth method name
+	AuthMount string
+
+	// token auth:
+	Token     string
+	TokenPath string // a file to read the token from
+
+	// approle auth:
+	RoleID       string
+	SecretID     string
+	SecretIDPath string // a file to read the secret id from
+
+	// kubernetes auth:
+	Role    string
+	JWTPath string // the service account token
+
+	// the kv v2 secrets engine mount
+	Mount string
+
+	// prefix mode: fetch all the secrets under the path (recursively)
+	Path string
+
+	PathTranslation string
+
+	// failfast, failatend or besteffort. Empty uses the shared failure policy
+	FailurePolicy string
+
+	// the maximum number of secrets fetched in parallel
+	Concurrency int
+
+	// the overall timeout for fetching and writing the secrets, and the timeout for each vault request. 0 disables them
+	Timeout        time.Duration
+	RequestTimeout time.Duration
+}
+
+// authMount - the path the auth method is mounted at
+func (c *VaultConfig) authMount() string {
+	if c.AuthMount != "" {
+		return c.AuthMount
+	}
+	return c.AuthMethod
+}
@@ -0,0 +1,64 @@
+package vault
+
+import (
+	"context"
+
+	"github.com/daniel-cohen/secretsfetcher/secrets"
+	"go.uber.org/zap"
+)
+
+type ManifestSecretsFetcher struct {
+	// implements secrets.SecretsFetcher
+	zl       *zap.Logger
+	provider *VaultSecretsProvider
+	manifest *SecretManifest
+}
+
+func NewManifestSecretFetcher(
+	provider *VaultSecretsProvider,
+	manifest *SecretManifest,
+	zl *zap.Logger) *ManifestSecretsFetcher {
+	return &ManifestSecretsFetcher{
+		zl:       zl,
+		provider: provider,
+		manifest: manifest,
+	}
+}
+
+func (msf *ManifestSecretsFetcher) Fetch(ctx context.Context) ([]*secrets.Secret, error) {
+	if err := msf.manifest.Validate(); err != nil {
+		msf.zl.Error("invalid manifest", zap.Error(err))
+		return nil, err
+	}
+
+	secretRes, err := msf.provider.FetchSecrets(ctx, msf.manifest.SecretObjects)
+	if err != nil {
+		return nil, err
+	}
+
+	msf.zl.Info("fetched vault secrets", zap.Int("secrets", len(secretRes)))
+	return secretRes, nil
+}
+
+// PathSecretFetcher - fetches all the secrets under a kv path
+type PathSecretFetcher struct {
+	// implements secrets.SecretsFetcher
+	zl       *zap.Logger
+	provider *VaultSecretsProvider
+	path     string
+}
+
+func NewPathSecretFetcher(
+	provider *VaultSecretsProvider,
+	path string,
+	zl *zap.Logger) *PathSecretFetcher {
+	return &PathSecretFetcher{
+		zl:       zl,
+		provider: provider,
+		path:     path,
+	}
+}
+
+func (psf *PathSecretFetcher) Fetch(ctx context.Context) ([]*secrets.Secret, error) {
+	return psf.provider.FetchAllSecrets(ctx, psf.path)
+}
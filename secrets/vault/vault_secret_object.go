@@ -0,0 +1,44 @@
+package vault
+
+import (
+	"fmt"
+
+	"github.com/daniel-cohen/secretsfetcher/secrets"
+)
+
+type VaultSecretObject struct {
+	ObjectName    string // the secret path in the kv mount. E.g: my-app/db
+	ObjectVersion string // an optional kv version number to pin, defaults to the latest
+	ObjectAlias   string // optional output file name, defaults to the secret path
+
+	// an optional key of the secret data to write on its own. By default all the keys are written as a json object
+	ObjectKey string
+
+	// an optional kv v2 mount, overriding the manifest's
+	Mount string
+
+	// optional objects which fail to be fetched are skipped
+	Optional bool
+
+	// optional overrides of the writer's file mode (octal. E.g: "0400") and owner
+	FileMode string
+	UID      *int
+	GID      *int
+}
+
+// FilePermissions - the object's file mode and owner overrides, nil if none are set
+func (o *VaultSecretObject) FilePermissions() (*secrets.FilePermissions, error) {
+	perms, err := secrets.ObjectFilePermissions(o.FileMode, o.UID, o.GID)
+	if err != nil {
+		return nil, fmt.Errorf("object %s: %w", o.ObjectName, err)
+	}
+	return perms, nil
+}
+
+// outputName - the alias or name of the secret written for the object
+func (o *VaultSecretObject) outputName() string {
+	if o.ObjectAlias != "" {
+		return o.ObjectAlias
+	}
+	return o.ObjectName
+}
@@ -0,0 +1,224 @@
+package vault
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/daniel-cohen/secretsfetcher/secrets"
+	"go.uber.org/zap"
+)
+
+// kvReadResponse - a kv v2 secret version
+type kvReadResponse struct {
+	Data struct {
+		Data     map[string]interface{} `json:"data"`
+		Metadata struct {
+			Version int `json:"version"`
+		} `json:"metadata"`
+	} `json:"data"`
+}
+
+type kvListResponse struct {
+	Data struct {
+		Keys []string `json:"keys"`
+	} `json:"data"`
+}
+
+type VaultSecretsProvider struct {
+	zl     *zap.Logger
+	client *vaultClient
+	mount  string
+
+	// the maximum number of secrets fetched in parallel
+	concurrency int
+
+	failurePolicy secrets.FailurePolicy
+}
+
+// WithFailurePolicy - sets how secrets which failed to be fetched are handled
+func (p *VaultSecretsProvider) WithFailurePolicy(policy secrets.FailurePolicy) *VaultSecretsProvider {
+	p.failurePolicy = policy
+	return p
+}
+
+// WithConcurrency - the maximum number of secrets fetched in parallel
+func (p *VaultSecretsProvider) WithConcurrency(concurrency int) *VaultSecretsProvider {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	p.concurrency = concurrency
+	return p
+}
+
+// WithRequestTimeout - the timeout for each vault request. 0 disables it
+func (p *VaultSecretsProvider) WithRequestTimeout(timeout time.Duration) *VaultSecretsProvider {
+	p.client.requestTimeout = timeout
+	return p
+}
+
+// WithMount - the default kv v2 mount of the secrets
+func (p *VaultSecretsProvider) WithMount(mount string) *VaultSecretsProvider {
+	p.mount = strings.Trim(mount, "/")
+	return p
+}
+
+// NewVaultSecretsProvider - a provider for the vault server in the config. Logs in on the first request
+func NewVaultSecretsProvider(cfg *VaultConfig, zl *zap.Logger) (*VaultSecretsProvider, error) {
+	if cfg.Address == "" {
+		return nil, fmt.Errorf("no vault address set")
+	}
+	if _, err := url.ParseRequestURI(cfg.Address); err != nil {
+		return nil, fmt.Errorf("invalid vault address %q: %w", cfg.Address, err)
+	}
+
+	mount := cfg.Mount
+	if mount == "" {
+		mount = DefaultMount
+	}
+
+	p := &VaultSecretsProvider{
+		zl:            zl,
+		client:        newVaultClient(cfg, zl),
+		concurrency:   DefaultConcurrency,
+		failurePolicy: secrets.DefaultFailurePolicy,
+	}
+	return p.WithMount(mount), nil
+}
+
+// kvPath - the api path of the kv v2 endpoint (data or metadata) for the secret path
+func kvPath(mount string, endpoint string, secretPath string) string {
+	return mount + "/" + endpoint + "/" + strings.Trim(secretPath, "/")
+}
+
+// secretContent - the value of the key, or all the secret data as json
+func secretContent(data map[string]interface{}, key string) (string, error) {
+	if key == "" {
+		b, err := json.Marshal(data)
+		return string(b), err
+	}
+
+	value, ok := data[key]
+	if !ok {
+		return "", fmt.Errorf("key %q not found", key)
+	}
+	if s, ok := value.(string); ok {
+		return s, nil
+	}
+	b, err := json.Marshal(value)
+	return string(b), err
+}
+
+// readSecret - reads the kv v2 secret (the latest version if version is empty)
+func (p *VaultSecretsProvider) readSecret(ctx context.Context, mount string, secretPath string, version string) (*kvReadResponse, error) {
+	var query url.Values
+	if version != "" {
+		query = url.Values{"version": {version}}
+	}
+
+	var resp kvReadResponse
+	if err := p.client.authenticated(ctx, "ReadSecret", http.MethodGet, kvPath(mount, "data", secretPath), query, &resp); err != nil {
+		return nil, err
+	}
+	// deleted versions have no data:
+	if resp.Data.Data == nil {
+		return nil, &VaultError{StatusCode: http.StatusNotFound, Errors: []string{"the secret version was deleted"}}
+	}
+	return &resp, nil
+}
+
+// fetchSecret - reads the object's secret, with its output options set
+func (p *VaultSecretsProvider) fetchSecret(ctx context.Context, obj *VaultSecretObject) (*secrets.Secret, error) {
+	mount := p.mount
+	if obj.Mount != "" {
+		mount = strings.Trim(obj.Mount, "/")
+	}
+
+	resp, err := p.readSecret(ctx, mount, obj.ObjectName, obj.ObjectVersion)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", obj.ObjectName, err)
+	}
+
+	content, err := secretContent(resp.Data.Data, obj.ObjectKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", obj.ObjectName, err)
+	}
+
+	perms, err := obj.FilePermissions()
+	if err != nil {
+		return nil, err
+	}
+
+	return &secrets.Secret{
+		Name:        obj.ObjectName,
+		Content:     content,
+		Alias:       obj.ObjectAlias,
+		Permissions: perms,
+		Version:     strconv.Itoa(resp.Data.Metadata.Version),
+	}, nil
+}
+
+// FetchSecrets - fetches the objects' secrets in parallel, handling the failed ones by the failure policy
+func (p *VaultSecretsProvider) FetchSecrets(ctx context.Context, objs []*VaultSecretObject) ([]*secrets.Secret, error) {
+	refs := make([]secrets.ObjectRef, len(objs))
+	for i, obj := range objs {
+		refs[i] = secrets.ObjectRef{Name: obj.ObjectName, Optional: obj.Optional}
+	}
+
+	return secrets.FetchObjects(ctx, refs, p.concurrency, p.failurePolicy, func(ctx context.Context, i int) (*secrets.Secret, error) {
+		return p.fetchSecret(ctx, objs[i])
+	}, p.zl)
+}
+
+// listSecrets - the paths of all the secrets under the path, listed recursively (sorted)
+func (p *VaultSecretsProvider) listSecrets(ctx context.Context, secretPath string) ([]string, error) {
+	var resp kvListResponse
+	if err := p.client.authenticated(ctx, "ListSecrets", methodList, kvPath(p.mount, "metadata", secretPath), nil, &resp); err != nil {
+		// listing an empty folder:
+		if IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var paths []string
+	for _, key := range resp.Data.Keys {
+		keyPath := path.Join(secretPath, key)
+		if !strings.HasSuffix(key, "/") {
+			paths = append(paths, keyPath)
+			continue
+		}
+
+		nested, err := p.listSecrets(ctx, keyPath)
+		if err != nil {
+			return nil, err
+		}
+		paths = append(paths, nested...)
+	}
+
+	sort.Strings(paths)
+	return paths, nil
+}
+
+// FetchAllSecrets - fetches the latest version of all the secrets under the path (recursively)
+func (p *VaultSecretsProvider) FetchAllSecrets(ctx context.Context, secretPath string) ([]*secrets.Secret, error) {
+	paths, err := p.listSecrets(ctx, strings.Trim(secretPath, "/"))
+	if err != nil {
+		p.zl.Error("failed to list vault secrets", zap.String("path", secretPath), zap.Error(err))
+		return nil, err
+	}
+	p.zl.Info("listed vault secrets", zap.String("path", secretPath), zap.Int("secrets", len(paths)))
+
+	objs := make([]*VaultSecretObject, 0, len(paths))
+	for _, secretPath := range paths {
+		objs = append(objs, &VaultSecretObject{ObjectName: secretPath})
+	}
+	return p.FetchSecrets(ctx, objs)
+}
@@ -0,0 +1,306 @@
+package vault
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"go.uber.org/zap/zaptest"
+
+	"github.com/daniel-cohen/secretsfetcher/secrets"
+)
+
+const fakeToken = "s.fake-token"
+
+// fakeVault - a kv v2 mount ("secret") with the approle and kubernetes auth methods
+type fakeVault struct {
+	mu sync.Mutex
+	// the versions of each secret path (nil versions were deleted)
+	secrets map[string][]map[string]interface{}
+
+	leaseDuration int
+	logins        int32
+	namespaces    []string
+}
+
+func (f *fakeVault) writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+func (f *fakeVault) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if ns := r.Header.Get("X-Vault-Namespace"); ns != "" {
+		f.namespaces = append(f.namespaces, ns)
+	}
+
+	switch {
+	case r.URL.Path == "/v1/auth/approle/login" || r.URL.Path == "/v1/auth/kubernetes/login":
+		var body map[string]string
+		json.NewDecoder(r.Body).Decode(&body)
+		if body["secret_id"] != "secret-id" && body["jwt"] != "service-account-jwt" {
+			f.writeJSON(w, http.StatusBadRequest, map[string]interface{}{"errors": []string{"invalid credentials"}})
+			return
+		}
+		atomic.AddInt32(&f.logins, 1)
+		f.writeJSON(w, http.StatusOK, map[string]interface{}{
+			"auth": map[string]interface{}{"client_token": fakeToken, "lease_duration": f.leaseDuration},
+		})
+		return
+	case r.Header.Get("X-Vault-Token") != fakeToken:
+		f.writeJSON(w, http.StatusForbidden, map[string]interface{}{"errors": []string{"permission denied"}})
+		return
+	}
+
+	if r.Method == "LIST" && strings.HasPrefix(r.URL.Path, "/v1/secret/metadata") {
+		f.list(w, strings.Trim(strings.TrimPrefix(r.URL.Path, "/v1/secret/metadata"), "/"))
+		return
+	}
+
+	if r.Method == http.MethodGet && strings.HasPrefix(r.URL.Path, "/v1/secret/data/") {
+		versions := f.secrets[strings.TrimPrefix(r.URL.Path, "/v1/secret/data/")]
+		version := len(versions)
+		if v := r.URL.Query().Get("version"); v != "" {
+			version, _ = strconv.Atoi(v)
+		}
+		if version < 1 || version > len(versions) {
+			f.writeJSON(w, http.StatusNotFound, map[string]interface{}{"errors": []string{}})
+			return
+		}
+
+		f.writeJSON(w, http.StatusOK, map[string]interface{}{
+			"data": map[string]interface{}{
+				"data":     versions[version-1],
+				"metadata": map[string]interface{}{"version": version},
+			},
+		})
+		return
+	}
+
+	f.writeJSON(w, http.StatusNotFound, map[string]interface{}{"errors": []string{}})
+}
+
+// list - the direct children of the folder, with a trailing slash for nested folders
+func (f *fakeVault) list(w http.ResponseWriter, folder string) {
+	keys := map[string]bool{}
+	for p := range f.secrets {
+		rel := p
+		if folder != "" {
+			if !strings.HasPrefix(p, folder+"/") {
+				continue
+			}
+			rel = strings.TrimPrefix(p, folder+"/")
+		}
+
+		if i := strings.Index(rel, "/"); i >= 0 {
+			keys[rel[:i+1]] = true
+		} else {
+			keys[rel] = true
+		}
+	}
+
+	if len(keys) == 0 {
+		f.writeJSON(w, http.StatusNotFound, map[string]interface{}{"errors": []string{}})
+		return
+	}
+
+	var res []string
+	for k := range keys {
+		res = append(res, k)
+	}
+	sort.Strings(res)
+	f.writeJSON(w, http.StatusOK, map[string]interface{}{"data": map[string]interface{}{"keys": res}})
+}
+
+var _ = Describe("Fetching vault secrets", func() {
+	var (
+		vault  *fakeVault
+		server *httptest.Server
+		cfg    *VaultConfig
+	)
+
+	BeforeEach(func() {
+		vault = &fakeVault{secrets: map[string][]map[string]interface{}{
+			"my-app/db": {
+				{"username": "app", "password": "old"},
+				{"username": "app", "password": "rotated"},
+			},
+			"my-app/api/key":   {{"value": "api-key"}},
+			"other-app/secret": {{"value": "other"}},
+			"my-app/deleted":   {nil},
+		}}
+		server = httptest.NewServer(vault)
+		cfg = &VaultConfig{Address: server.URL, Token: fakeToken}
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	newProvider := func() *VaultSecretsProvider {
+		provider, err := NewVaultSecretsProvider(cfg, zaptest.NewLogger(GinkgoT()))
+		Expect(err).NotTo(HaveOccurred())
+		return provider
+	}
+
+	It("fetches the latest version of a secret as json", func() {
+		res, err := newProvider().FetchSecrets(context.Background(), []*VaultSecretObject{{ObjectName: "my-app/db"}})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(res).To(HaveLen(1))
+		Expect(res[0].Name).To(Equal("my-app/db"))
+		Expect(res[0].Content).To(MatchJSON(`{"username": "app", "password": "rotated"}`))
+		Expect(res[0].Version).To(Equal("2"))
+	})
+
+	It("fetches a pinned version and a single key", func() {
+		res, err := newProvider().FetchSecrets(context.Background(), []*VaultSecretObject{
+			{ObjectName: "my-app/db", ObjectVersion: "1", ObjectKey: "password", ObjectAlias: "db-password", FileMode: "0400"},
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(res[0].Content).To(Equal("old"))
+		Expect(res[0].Alias).To(Equal("db-password"))
+		Expect(res[0].Version).To(Equal("1"))
+		Expect(res[0].Permissions.Mode).To(BeEquivalentTo(0400))
+	})
+
+	It("fails on missing secrets, keys and deleted versions unless optional", func() {
+		provider := newProvider()
+
+		_, err := provider.FetchSecrets(context.Background(), []*VaultSecretObject{
+			{ObjectName: "my-app/db"},
+			{ObjectName: "my-app/missing"},
+			{ObjectName: "my-app/db", ObjectKey: "missing", ObjectAlias: "missing-key"},
+			{ObjectName: "my-app/deleted"},
+		})
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("my-app/missing"))
+		Expect(err.Error()).To(ContainSubstring(`key "missing" not found`))
+		Expect(err.Error()).To(ContainSubstring("deleted"))
+
+		res, err := provider.FetchSecrets(context.Background(), []*VaultSecretObject{
+			{ObjectName: "my-app/db"},
+			{ObjectName: "my-app/missing", Optional: true},
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(res).To(HaveLen(1))
+	})
+
+	It("fails with an invalid token", func() {
+		cfg.Token = "s.invalid"
+		_, err := newProvider().FetchSecrets(context.Background(), []*VaultSecretObject{{ObjectName: "my-app/db"}})
+		Expect(err).To(MatchError(ContainSubstring("permission denied")))
+	})
+
+	It("lists and fetches all the secrets under a path recursively", func() {
+		res, err := newProvider().FetchAllSecrets(context.Background(), "my-app/")
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("my-app/deleted"))
+
+		delete(vault.secrets, "my-app/deleted")
+		res, err = newProvider().FetchAllSecrets(context.Background(), "my-app/")
+		Expect(err).NotTo(HaveOccurred())
+
+		var names []string
+		for _, s := range res {
+			names = append(names, s.Name)
+		}
+		Expect(names).To(Equal([]string{"my-app/api/key", "my-app/db"}))
+	})
+
+	It("returns no secrets for an empty path", func() {
+		res, err := newProvider().FetchAllSecrets(context.Background(), "no-such-app")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(res).To(BeEmpty())
+	})
+
+	It("sends the namespace header", func() {
+		cfg.Namespace = "team-a"
+		_, err := newProvider().FetchSecrets(context.Background(), []*VaultSecretObject{{ObjectName: "my-app/db"}})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(vault.namespaces).To(ConsistOf("team-a"))
+	})
+
+	Describe("auth methods", func() {
+		var tmpFolder string
+
+		BeforeEach(func() {
+			var err error
+			tmpFolder, err = ioutil.TempDir("", "secretsfetcher")
+			Expect(err).NotTo(HaveOccurred())
+			cfg.Token = ""
+		})
+
+		AfterEach(func() {
+			os.RemoveAll(tmpFolder)
+		})
+
+		It("logs in with approle once while the token is valid", func() {
+			vault.leaseDuration = 3600
+			cfg.AuthMethod = AuthMethodAppRole
+			cfg.RoleID = "role-id"
+			cfg.SecretIDPath = filepath.Join(tmpFolder, "secret-id")
+			Expect(ioutil.WriteFile(cfg.SecretIDPath, []byte("secret-id\n"), 0600)).To(Succeed())
+
+			provider := newProvider()
+			for i := 0; i < 2; i++ {
+				_, err := provider.FetchSecrets(context.Background(), []*VaultSecretObject{{ObjectName: "my-app/db"}})
+				Expect(err).NotTo(HaveOccurred())
+			}
+			Expect(atomic.LoadInt32(&vault.logins)).To(BeEquivalentTo(1))
+		})
+
+		It("logs in again once the token is about to expire", func() {
+			vault.leaseDuration = 10
+			cfg.AuthMethod = AuthMethodKubernetes
+			cfg.Role = "my-app"
+			cfg.JWTPath = filepath.Join(tmpFolder, "token")
+			Expect(ioutil.WriteFile(cfg.JWTPath, []byte("service-account-jwt"), 0600)).To(Succeed())
+
+			provider := newProvider()
+			for i := 0; i < 2; i++ {
+				_, err := provider.FetchSecrets(context.Background(), []*VaultSecretObject{{ObjectName: "my-app/db"}})
+				Expect(err).NotTo(HaveOccurred())
+			}
+			Expect(atomic.LoadInt32(&vault.logins)).To(BeEquivalentTo(2))
+		})
+
+		It("fails with invalid credentials", func() {
+			cfg.AuthMethod = AuthMethodAppRole
+			cfg.RoleID = "role-id"
+			cfg.SecretID = "wrong"
+
+			_, err := newProvider().FetchSecrets(context.Background(), []*VaultSecretObject{{ObjectName: "my-app/db"}})
+			Expect(err).To(MatchError(ContainSubstring("invalid credentials")))
+		})
+
+		It("fails without a token", func() {
+			_, err := newProvider().FetchSecrets(context.Background(), []*VaultSecretObject{{ObjectName: "my-app/db"}})
+			Expect(err).To(MatchError(ContainSubstring("no vault token")))
+		})
+	})
+
+	It("fetches the manifest secrets", func() {
+		fetcher := NewManifestSecretFetcher(newProvider(), &SecretManifest{
+			SecretObjects: []*VaultSecretObject{{ObjectName: "my-app/api/key", ObjectKey: "value", ObjectAlias: "api-key"}},
+		}, zaptest.NewLogger(GinkgoT()))
+
+		var _ secrets.SecretsFetcher = fetcher
+		res, err := fetcher.Fetch(context.Background())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(res[0].Content).To(Equal("api-key"))
+	})
+})
@@ -0,0 +1,13 @@
+package vault_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func TestVaultSecrets(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "vault secret Suite")
+}
@@ -0,0 +1,212 @@
+package secrets
+
+import (
+	"context"
+	"math/rand"
+	"sort"
+	"time"
+
+	"github.com/daniel-cohen/secretsfetcher/metrics"
+	"go.uber.org/zap"
+)
+
+const (
+	DefaultWatchInterval = 5 * time.Minute
+	DefaultWatchJitter   = 0.1
+)
+
+// Watcher - periodically fetches the secrets and rewrites them when they change
+type Watcher struct {
+	zl       *zap.Logger
+	fetcher  SecretsFetcher
+	writer   SecretWriter
+	interval time.Duration
+	jitter   float64
+
+	// the timeout of each refresh. 0 disables it
+	refreshTimeout time.Duration
+
+	// run after the changed secrets were rewritten
+	hooks []ChangeHook
+
+	// records the refreshes for the health probes. Optional
+	status *Status
+
+	// the secrets from the last successful write:
+	current map[string]*Secret
+	written bool
+}
+
+// WithJitter - randomizes each interval by up to +/- jitter (a fraction of the interval), so pods don't poll in sync
+func (w *Watcher) WithJitter(jitter float64) *Watcher {
+	if jitter < 0 {
+		jitter = 0
+	}
+	if jitter > 1 {
+		jitter = 1
+	}
+	w.jitter = jitter
+	return w
+}
+
+// WithRefreshTimeout - the timeout for fetching and writing the secrets in each refresh. 0 disables it
+func (w *Watcher) WithRefreshTimeout(timeout time.Duration) *Watcher {
+	w.refreshTimeout = timeout
+	return w
+}
+
+// WithHooks - hooks to run after changed secrets were rewritten. They don't run after the first write
+func (w *Watcher) WithHooks(hooks ...ChangeHook) *Watcher {
+	w.hooks = append(w.hooks, hooks...)
+	return w
+}
+
+// WithStatus - records the refreshes and written secrets in the status, for the health probes
+func (w *Watcher) WithStatus(status *Status) *Watcher {
+	w.status = status
+	return w
+}
+
+// recordRefresh - records the refresh in the status, if set
+func (w *Watcher) recordRefresh(written []*Secret, err error) {
+	if w.status != nil {
+		w.status.RecordRefresh(written, err)
+	}
+}
+
+func NewWatcher(
+	fetcher SecretsFetcher,
+	writer SecretWriter,
+	interval time.Duration,
+	zl *zap.Logger) *Watcher {
+	return &Watcher{
+		zl:       zl,
+		fetcher:  fetcher,
+		writer:   writer,
+		interval: interval,
+		jitter:   DefaultWatchJitter,
+	}
+}
+
+// nextInterval - the interval with the jitter applied
+func (w *Watcher) nextInterval() time.Duration {
+	if w.jitter == 0 {
+		return w.interval
+	}
+	delta := (rand.Float64()*2 - 1) * w.jitter * float64(w.interval)
+	return w.interval + time.Duration(delta)
+}
+
+// secretKey - the name secrets are identified by between refreshes
+func secretKey(s *Secret) string {
+	if s.Alias != "" {
+		return s.Alias
+	}
+	return s.Name
+}
+
+// changedSecrets - the sorted names (alias or name) of the secrets which were added, removed or whose content changed
+func changedSecrets(previous map[string]*Secret, current map[string]*Secret) []string {
+	var changed []string
+	for key, s := range current {
+		if prev, ok := previous[key]; !ok || prev.Content != s.Content {
+			changed = append(changed, key)
+		}
+	}
+	for key := range previous {
+		if _, ok := current[key]; !ok {
+			changed = append(changed, key)
+		}
+	}
+	sort.Strings(changed)
+	return changed
+}
+
+// Refresh - fetches the secrets (only the changed ones if the fetcher supports it) and writes them if any changed.
+// Returns the names (alias or name) of the changed secrets.
+// Hooks run after the changed secrets were rewritten; their failures are returned along with the changed names.
+// The refresh timeout only bounds the fetch and the write - each hook is bounded by its own timeout.
+func (w *Watcher) Refresh(ctx context.Context) ([]string, error) {
+	hooksCtx := ctx
+	if w.refreshTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, w.refreshTimeout)
+		defer cancel()
+	}
+
+	var (
+		secretRes []*Secret
+		err       error
+	)
+	if cf, ok := w.fetcher.(ChangesFetcher); ok {
+		secretRes, err = cf.FetchChanges(ctx)
+	} else {
+		secretRes, err = w.fetcher.Fetch(ctx)
+	}
+	if err != nil {
+		metrics.RefreshFailed()
+		w.recordRefresh(nil, err)
+		return nil, err
+	}
+
+	current := map[string]*Secret{}
+	for _, s := range secretRes {
+		current[secretKey(s)] = s
+	}
+
+	changed := changedSecrets(w.current, current)
+	if w.written && len(changed) == 0 {
+		w.zl.Debug("no secrets changed")
+		metrics.RefreshSucceeded(time.Now())
+		w.recordRefresh(nil, nil)
+		return nil, nil
+	}
+
+	if err := w.writer.WriteSecrets(ctx, secretRes); err != nil {
+		metrics.RefreshFailed()
+		w.recordRefresh(nil, err)
+		return nil, err
+	}
+	metrics.AddSecretsWritten(len(secretRes))
+	metrics.RefreshSucceeded(time.Now())
+	w.recordRefresh(secretRes, nil)
+
+	firstWrite := !w.written
+	w.current = current
+	w.written = true
+	w.zl.Info("secrets changed", zap.Strings("secrets", changed))
+
+	// the first write has nothing to reload:
+	if firstWrite || len(w.hooks) == 0 {
+		return changed, nil
+	}
+
+	if err := runHooks(hooksCtx, w.hooks, changed); err != nil {
+		w.zl.Error("post update hooks failed", zap.Strings("secrets", changed), zap.Error(err))
+		return changed, err
+	}
+	w.zl.Info("ran post update hooks", zap.Int("hooks", len(w.hooks)))
+	return changed, nil
+}
+
+// Run - refreshes the secrets every interval until the context is cancelled.
+// Failed refreshes are logged and retried at the next interval, keeping the previously written secrets.
+func (w *Watcher) Run(ctx context.Context) error {
+	for {
+		if changed, err := w.Refresh(ctx); err != nil && changed == nil && ctx.Err() == nil {
+			w.zl.Error("failed to refresh secrets", zap.Error(err))
+		}
+
+		interval := w.nextInterval()
+		w.zl.Debug("waiting for the next refresh", zap.Duration("interval", interval))
+
+		timer := time.NewTimer(interval)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			w.zl.Info("stopped watching secrets")
+			return nil
+		}
+	}
+}
@@ -0,0 +1,165 @@
+package secrets_test
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"go.uber.org/zap/zaptest"
+
+	"github.com/daniel-cohen/secretsfetcher/secrets"
+)
+
+type fakeChangesFetcher struct {
+	secrets     []*secrets.Secret
+	err         error
+	fetches     int32
+	changeCalls int32
+}
+
+func (f *fakeChangesFetcher) Fetch(ctx context.Context) ([]*secrets.Secret, error) {
+	atomic.AddInt32(&f.fetches, 1)
+	return f.secrets, f.err
+}
+
+func (f *fakeChangesFetcher) FetchChanges(ctx context.Context) ([]*secrets.Secret, error) {
+	atomic.AddInt32(&f.changeCalls, 1)
+	return f.secrets, f.err
+}
+
+type recordingHook struct {
+	calls       [][]string
+	err         error
+	hasDeadline bool
+}
+
+func (h *recordingHook) Name() string {
+	return "recording"
+}
+
+func (h *recordingHook) Run(ctx context.Context, changed []string) error {
+	h.calls = append(h.calls, changed)
+	_, h.hasDeadline = ctx.Deadline()
+	return h.err
+}
+
+type recordingWriter struct {
+	writes [][]*secrets.Secret
+}
+
+func (r *recordingWriter) WriteSecrets(ctx context.Context, secretRes []*secrets.Secret) error {
+	r.writes = append(r.writes, secretRes)
+	return nil
+}
+
+var _ = Describe("Watching secrets", func() {
+	var (
+		fetcher *fakeChangesFetcher
+		writer  *recordingWriter
+		watcher *secrets.Watcher
+	)
+
+	BeforeEach(func() {
+		fetcher = &fakeChangesFetcher{secrets: []*secrets.Secret{
+			{Name: "secret1", Content: "value1"},
+			{Name: "arn:secret2", Alias: "secret2", Content: "value2"},
+		}}
+		writer = &recordingWriter{}
+		watcher = secrets.NewWatcher(fetcher, writer, time.Millisecond, zaptest.NewLogger(GinkgoT()))
+	})
+
+	It("only rewrites the secrets when they change", func() {
+		changed, err := watcher.Refresh(context.Background())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(changed).To(Equal([]string{"secret1", "secret2"}))
+		Expect(writer.writes).To(HaveLen(1))
+
+		changed, err = watcher.Refresh(context.Background())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(changed).To(BeEmpty())
+		Expect(writer.writes).To(HaveLen(1))
+
+		fetcher.secrets = []*secrets.Secret{
+			{Name: "arn:secret2", Alias: "secret2", Content: "rotated"},
+			{Name: "secret3", Content: "value3"},
+		}
+		changed, err = watcher.Refresh(context.Background())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(changed).To(Equal([]string{"secret1", "secret2", "secret3"}))
+		Expect(writer.writes).To(HaveLen(2))
+
+		// only the changes were fetched:
+		Expect(atomic.LoadInt32(&fetcher.changeCalls)).To(BeEquivalentTo(3))
+		Expect(atomic.LoadInt32(&fetcher.fetches)).To(BeZero())
+	})
+
+	It("runs the hooks after changed secrets were rewritten", func() {
+		hook := &recordingHook{}
+		watcher.WithHooks(hook)
+
+		// nothing to reload after the first write:
+		_, err := watcher.Refresh(context.Background())
+		Expect(err).NotTo(HaveOccurred())
+		_, err = watcher.Refresh(context.Background())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(hook.calls).To(BeEmpty())
+
+		fetcher.secrets = []*secrets.Secret{
+			{Name: "secret1", Content: "rotated"},
+			{Name: "arn:secret2", Alias: "secret2", Content: "value2"},
+		}
+		_, err = watcher.Refresh(context.Background())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(hook.calls).To(Equal([][]string{{"secret1"}}))
+	})
+
+	It("doesn't bound the hooks by the refresh timeout", func() {
+		hook := &recordingHook{}
+		watcher.WithHooks(hook).WithRefreshTimeout(time.Minute)
+
+		_, err := watcher.Refresh(context.Background())
+		Expect(err).NotTo(HaveOccurred())
+
+		fetcher.secrets = []*secrets.Secret{{Name: "secret1", Content: "rotated"}}
+		_, err = watcher.Refresh(context.Background())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(hook.calls).To(HaveLen(1))
+		Expect(hook.hasDeadline).To(BeFalse())
+	})
+
+	It("reports failed hooks along with the changed secrets", func() {
+		failing := &recordingHook{err: errors.New("no such process")}
+		next := &recordingHook{}
+		watcher.WithHooks(failing, next)
+
+		_, err := watcher.Refresh(context.Background())
+		Expect(err).NotTo(HaveOccurred())
+
+		fetcher.secrets = []*secrets.Secret{{Name: "secret1", Content: "rotated"}}
+		changed, err := watcher.Refresh(context.Background())
+		Expect(err).To(MatchError(ContainSubstring("no such process")))
+		Expect(changed).To(Equal([]string{"secret1", "secret2"}))
+		Expect(writer.writes).To(HaveLen(2))
+
+		// the other hooks still ran:
+		Expect(next.calls).To(HaveLen(1))
+	})
+
+	It("keeps watching after failed refreshes until cancelled", func() {
+		fetcher.err = errors.New("throttled")
+		ctx, cancel := context.WithCancel(context.Background())
+
+		done := make(chan error)
+		go func() {
+			done <- watcher.WithJitter(0.5).Run(ctx)
+		}()
+
+		Eventually(func() int32 { return atomic.LoadInt32(&fetcher.changeCalls) }).Should(BeNumerically(">", 2))
+		cancel()
+		Eventually(done).Should(Receive(BeNil()))
+		Expect(writer.writes).To(BeEmpty())
+	})
+})